@@ -0,0 +1,96 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+覆盖率变更日志CLI
+给定发布区间，输出变更包的前后覆盖率对比（markdown表格，
+可直接贴进发布就绪评审），覆盖率下降的包用:warning:标记
+
+用法:
+    python changelog.py --repo-id 123 --from <commit> --to <commit> [--json]
+
+退出码:
+    0: 成功且无覆盖率下降的包
+    1: 计算失败
+    3: 成功但存在覆盖率下降的包（便于CI判断）
+"""
+
+import argparse
+import json
+import logging
+import sys
+
+import version
+
+logging.basicConfig(
+    level=logging.WARNING,
+    format='%(asctime)s - %(name)s - %(levelname)s - %(message)s'
+)
+logger = logging.getLogger(__name__)
+
+
+def parse_args():
+    parser = argparse.ArgumentParser(description='发布区间覆盖率变更日志')
+    parser.add_argument('--version', action=version.VersionAction)
+    parser.add_argument('--repo-id', required=True, help='仓库唯一标识')
+    parser.add_argument('--from', dest='from_commit', required=True,
+                        help='发布区间起点commit')
+    parser.add_argument('--to', dest='to_commit', required=True,
+                        help='发布区间终点commit')
+    parser.add_argument('--json', action='store_true',
+                        help='输出JSON而不是markdown表格')
+    return parser.parse_args()
+
+
+def format_rate(coverage):
+    """格式化覆盖率（无数据显示n/a）"""
+    if not coverage:
+        return 'n/a'
+    return f"{coverage['coverage_rate']:.2f}%"
+
+
+def print_markdown(result):
+    """输出markdown表格"""
+    print(f"## 覆盖率变更日志 `{result['from_commit'][:8]}..{result['to_commit'][:8]}`")
+    print()
+    print('| 包 | 变更文件数 | 发布前 | 发布后 | 变化 |')
+    print('| --- | ---: | ---: | ---: | ---: |')
+    for entry in result['packages']:
+        delta = entry['delta_rate']
+        if delta is None:
+            delta_text = 'n/a'
+        else:
+            delta_text = f'{delta:+.2f}%'
+            if entry['decreased']:
+                delta_text += ' :warning:'
+        print(
+            f"| {entry['package']} | {entry['files_changed']} "
+            f"| {format_rate(entry['before'])} "
+            f"| {format_rate(entry['after'])} | {delta_text} |"
+        )
+    print()
+    if result['decreased_count']:
+        print(f"> :warning: {result['decreased_count']} 个包覆盖率下降")
+
+
+def main():
+    args = parse_args()
+
+    from manager import changelog_manager
+    result = changelog_manager.build_release_changelog(
+        args.repo_id, args.from_commit, args.to_commit
+    )
+    if result is None:
+        logger.error('Failed to build release changelog')
+        return 1
+
+    if args.json:
+        print(json.dumps(result, ensure_ascii=False, indent=2))
+    else:
+        print_markdown(result)
+
+    return 3 if result['decreased_count'] else 0
+
+
+if __name__ == '__main__':
+    sys.exit(main())
@@ -5,23 +5,75 @@ import logging
 import sys
 import os
 
+import yaml
+
 # 添加父目录到路径，以便导入共享模块
 sys.path.insert(0, os.path.dirname(os.path.dirname(os.path.abspath(__file__))))
 
-from flask import Flask, jsonify, request
+from flask import Flask, jsonify, request, Response
 from flask_cors import CORS
 from sqlalchemy import func, text
 
 from models import (
     CoverageReport, CoverageFile, CoverageRange, CoverageConfig,
-    get_db_session, close_db_session, init_db
+    ReportNote, CoverageFileSnapshot, CoverageSnapshot,
+    get_db_session, close_db_session, init_db,
+    priority_lane
+)
+from manager.manager import (
+    get_report_statistics, get_file_summary, SUPPORTED_COVERAGE_FORMATS,
+    metadata_like_pattern
 )
-from manager.manager import get_report_statistics, get_file_summary
+from manager import publish_manager
 from manager.repo_manager import get_file_content
 from manager.diff_manager import (
     get_base_branch_for_repo, set_base_branch_for_repo,
-    calculate_diff_coverage, update_base_commit_for_report
+    calculate_diff_coverage, update_base_commit_for_report,
+    build_mr_uncovered_comment
 )
+from manager import badge_manager
+from manager import widget_manager
+from manager import ldap_sync
+from manager import apikey_manager
+from manager import risk_manager
+from manager import mutation_manager
+from manager import testresult_manager
+from manager import gate_manager
+from manager import gitlab_manager
+from manager import trend_manager
+from manager import function_manager
+from manager import upload_manager
+from manager import goal_manager
+from manager import benchmark_manager
+from manager import language_manager
+from manager import api_surface_manager
+from manager import saved_query_manager
+from manager import csv_export
+from manager import status_manager
+from manager import weighting_manager
+from manager import incident_manager
+from manager import retention_manager
+from manager import proto_export
+from manager import reconciliation_manager
+from manager import complexity_manager
+from manager import share_manager
+from manager import reviewer_manager
+from manager import preflight_manager
+from manager import quota_manager
+from manager import bulk_config_manager
+from manager import annotation_manager
+from manager import anonymize_manager
+from manager import webhook_manager
+from manager import lineage_manager
+from manager import changelog_manager
+from manager import diffview_manager
+from manager import freshness_manager
+from manager import profiling_manager
+from manager import rename_manager
+from manager import exception_manager
+from manager import eventlog_manager
+from manager import testgap_manager
+import version
 from manager.config_manager import (
     get_all_configs as get_all_configs_logic,
     get_config_by_repo_id as get_config_by_id_logic,
@@ -32,6 +84,7 @@ from manager.config_manager import (
     set_repo_config as set_repo_config_logic,
     get_repo_id_from_url as get_repo_id_from_url_logic
 )
+import json
 import time
 import re
 
@@ -42,6 +95,9 @@ logging.basicConfig(
 )
 logger = logging.getLogger(__name__)
 
+# 批量查询接口单次请求的条数上限
+BATCH_QUERY_MAX = int(os.environ.get('BATCH_QUERY_MAX', '100'))
+
 # 创建Flask应用
 app = Flask(__name__)
 CORS(app)
@@ -58,6 +114,42 @@ def close_db(error):
     close_db_session()
 
 
+# 判断客户端是否要求CSV（?format=csv 或 Accept: text/csv）
+def wants_csv():
+    if request.args.get('format') == 'csv':
+        return True
+    return request.accept_mimetypes.best_match(
+        ['text/csv', 'application/json']
+    ) == 'text/csv'
+
+
+# 把行数据包装成CSV下载响应
+def csv_response(rows, filename, fields=None):
+    return Response(
+        csv_export.rows_to_csv(rows, fields=fields),
+        mimetype='text/csv',
+        headers={'Content-Disposition': f'attachment; filename="{filename}"'}
+    )
+
+
+# 解析?fields=稀疏字段集（逗号分隔的字段名），未指定返回 None
+def requested_fields():
+    raw = request.args.get('fields')
+    if not raw:
+        return None
+    return {field.strip() for field in raw.split(',') if field.strip()}
+
+
+# 按稀疏字段集裁剪字典列表（monorepo下重接口可从几十MB降到几百KB）
+def apply_fields(rows, fields):
+    if not fields:
+        return rows
+    return [
+        {key: value for key, value in row.items() if key in fields}
+        for row in rows
+    ]
+
+
 
 
 # 健康检查端点
@@ -72,6 +164,13 @@ def health():
         return jsonify({'status': 'unhealthy', 'error': str(e)}), 503
 
 
+# 版本信息端点（支持排障/CLI更新检查用）
+@app.route('/api/v1/version', methods=['GET'])
+def get_version():
+    """获取服务端构建版本信息（版本号/commit/构建时间）"""
+    return jsonify(version.get_version_info()), 200
+
+
 # 获取覆盖率报告列表
 @app.route('/api/v1/coverage/reports', methods=['GET'])
 def get_coverage_reports():
@@ -98,7 +197,15 @@ def get_coverage_reports():
         if status := request.args.get('status'):
             query = query.filter(CoverageReport.status == status)
             count_query = count_query.filter(CoverageReport.status == status)
-        
+
+        # 支持按自定义metadata过滤（meta.<key>=<value>，可叠加多个，仅匹配字符串值）
+        for arg_key, arg_value in request.args.items():
+            if not arg_key.startswith('meta.'):
+                continue
+            pattern = metadata_like_pattern(arg_key[len('meta.'):], arg_value)
+            query = query.filter(CoverageReport.report_metadata.like(pattern))
+            count_query = count_query.filter(CoverageReport.report_metadata.like(pattern))
+
         # 支持创建时间范围搜索
         if created_at_start := request.args.get('created_at_start'):
             try:
@@ -174,9 +281,12 @@ def get_coverage_reports():
                 report_dict['base_branch'] = get_base_branch_for_repo(report.repo_id)
             
             report_dicts.append(report_dict)
-        
+
+        if wants_csv():
+            return csv_response(report_dicts, 'coverage_reports.csv')
+
         return jsonify({
-            'data': report_dicts,
+            'data': apply_fields(report_dicts, requested_fields()),
             'total': total,
             'page': page,
             'page_size': page_size
@@ -267,9 +377,9 @@ def get_coverage_files():
             summary = get_file_summary(file)
             if summary is not None:  # 排除被配置排除的文件
                 summaries.append(summary)
-        
+
         return jsonify({
-            'data': summaries,
+            'data': apply_fields(summaries, requested_fields()),
             'total': len(summaries)
         }), 200
     except Exception as e:
@@ -308,9 +418,12 @@ def get_coverage_file_detail(file_id):
             logger.debug(f"Found {len(ranges_22_26)} ranges covering lines 22-26: {[(r.start_line, r.end_line, r.hit) for r in ranges_22_26]}")
         
         # 返回符合前端要求的格式，包含statements统计信息
+        # ranges支持?fields=裁剪（如只要start_line,end_line,hit）
         return jsonify({
             'file': file.file_path,
-            'ranges': [r.to_dict() for r in ranges],
+            'ranges': apply_fields(
+                [r.to_dict() for r in ranges], requested_fields()
+            ),
             'total_statements': file_summary['total_statements'],
             'covered_statements': file_summary['covered_statements'],
             'coverage_rate': file_summary['coverage_rate']
@@ -379,10 +492,11 @@ def get_file_content_api():
 def get_diff_coverage(report_id):
     """
     获取增量覆盖率数据
-    
+
     参数:
         report_id: 报告 ID
         base_branch: 基准分支（可选，默认从配置获取）
+        fields: 稀疏字段集（可选，逗号分隔，裁剪files中每个条目的字段）
     """
     try:
         db = get_db()
@@ -424,12 +538,13 @@ def get_diff_coverage(report_id):
             report.updated_at = int(__import__('time').time() * 1000)
             db.commit()
         
+        # files支持?fields=裁剪（如只要file,summary,url，省掉逐行数据）
         return jsonify({
             'report_id': report_id,
             'target_commit': report.commit,
             'base_commit': diff_result['base_commit'],
             'base_branch': diff_result['base_branch'],
-            'files': diff_result['files'],
+            'files': apply_fields(diff_result['files'], requested_fields()),
             'summary': diff_result['summary']
         }), 200
     except Exception as e:
@@ -437,6 +552,48 @@ def get_diff_coverage(report_id):
         return jsonify({'error': str(e)}), 500
 
 
+# 获取带上下文与覆盖状态的diff阅读视图
+@app.route('/api/v1/coverage/reports/<int:report_id>/diff-view', methods=['GET'])
+def get_diff_view(report_id):
+    """
+    获取diff阅读视图：hunk+上下文行+每行覆盖状态一次拼好，
+    前端直接渲染（详见diffview_manager）
+
+    参数:
+        report_id: 报告 ID
+        base_branch: 基准分支（可选，默认从配置获取）
+        context: 每个hunk前后的上下文行数（可选，默认3）
+    """
+    try:
+        db = get_db()
+
+        report = db.query(CoverageReport).filter(CoverageReport.id == report_id).first()
+        if not report:
+            return jsonify({'error': 'Report not found'}), 404
+
+        config = db.query(CoverageConfig).filter(CoverageConfig.repo_id == report.repo_id).first()
+        if not config:
+            return jsonify({'error': 'Config not found for this repo'}), 404
+
+        result = diffview_manager.build_diff_view(
+            repo_url=config.repo_url,
+            repo_id=report.repo_id,
+            target_branch=report.branch,
+            target_commit=report.commit,
+            base_branch=request.args.get('base_branch'),
+            context=int(request.args.get('context', diffview_manager.DEFAULT_CONTEXT_LINES))
+        )
+        if result is None:
+            return jsonify({'error': 'Failed to build diff view'}), 500
+
+        result['report_id'] = report_id
+        result['target_commit'] = report.commit
+        return jsonify(result), 200
+    except Exception as e:
+        logger.error(f"Error building diff view: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
 # 获取 Monaco 编辑器数据
 @app.route('/api/v1/coverage/reports/<int:report_id>/monaco', methods=['GET'])
 def get_monaco_data(report_id):
@@ -504,6 +661,7 @@ def get_repo_config(repo_id):
 
 # 设置仓库配置
 @app.route('/api/v1/coverage/config/<repo_id>', methods=['POST'])
+@apikey_manager.require_scope(apikey_manager.SCOPE_ADMIN_CONFIG)
 def set_repo_config(repo_id):
     """
     设置仓库配置
@@ -540,6 +698,78 @@ def set_repo_config(repo_id):
         return jsonify({'error': str(e)}), 500
 
 
+# 导出全部仓库配置（YAML）
+@app.route('/api/v1/coverage/configs/export', methods=['GET'])
+@apikey_manager.require_scope(apikey_manager.SCOPE_ADMIN_CONFIG)
+def export_configs():
+    """
+    导出全部仓库配置为YAML
+    可人工修改后经 /configs/bulk-apply 批量套用
+    """
+    try:
+        content = bulk_config_manager.export_configs_yaml()
+        return Response(
+            content,
+            mimetype='application/yaml',
+            headers={'Content-Disposition': 'attachment; filename=orbit_configs.yaml'}
+        )
+    except Exception as e:
+        logger.error(f"Error exporting configs: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 匿名化数据集导出（供外部研究者/供应商使用）
+@app.route('/api/v1/coverage/export/anonymized', methods=['GET'])
+@apikey_manager.require_scope(apikey_manager.SCOPE_ADMIN_CONFIG)
+def export_anonymized_dataset():
+    """
+    导出匿名化覆盖率数据集
+
+    仓库/分支/commit/路径全部替换为加盐哈希，不含文件内容，
+    覆盖率百分比和语句数原样保留（详见anonymize_manager）
+
+    查询参数:
+        repo_id: 仓库唯一标识（可选，不填导出全部仓库）
+    """
+    try:
+        result = anonymize_manager.build_anonymized_export(
+            repo_id=request.args.get('repo_id')
+        )
+        return jsonify(result), 200
+    except Exception as e:
+        logger.error(f"Error exporting anonymized dataset: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 批量套用仓库配置修改
+@app.route('/api/v1/coverage/configs/bulk-apply', methods=['POST'])
+@apikey_manager.require_scope(apikey_manager.SCOPE_ADMIN_CONFIG)
+def bulk_apply_configs():
+    """
+    批量套用仓库配置修改（支持dry-run先看diff）
+    请求体为导出格式的YAML（或JSON），只认repo_id和可写字段
+
+    查询参数:
+        dry_run: 1=只计算diff不落库（默认），0=真正落库
+    """
+    try:
+        if request.is_json:
+            doc = request.get_json()
+        else:
+            doc = yaml.safe_load(request.get_data(as_text=True))
+        if not doc:
+            return jsonify({'error': 'Request body is required'}), 400
+
+        dry_run = request.args.get('dry_run', '1').lower() not in ('0', 'false')
+        result = bulk_config_manager.bulk_apply(doc, dry_run=dry_run)
+        return jsonify(result), 200
+    except (ValueError, yaml.YAMLError) as e:
+        return jsonify({'error': str(e)}), 400
+    except Exception as e:
+        logger.error(f"Error bulk-applying configs: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
 # 更新报告的基准 commit
 @app.route('/api/v1/coverage/reports/<int:report_id>/base-commit', methods=['POST'])
 def update_report_base_commit(report_id):
@@ -563,183 +793,2016 @@ def update_report_base_commit(report_id):
         return jsonify({'error': str(e)}), 500
 
 
-# ==================== Config Management APIs ====================
-
-# 获取所有仓库配置列表
-@app.route('/api/v1/coverage/configs', methods=['GET'])
-def get_all_configs():
+# 获取覆盖率徽章
+@app.route('/api/v1/coverage/badge/<repo_id>', methods=['GET'])
+def get_coverage_badge(repo_id):
     """
-    获取所有仓库配置列表
-    
+    获取覆盖率徽章（SVG）
+
+    参数:
+        repo_id: 仓库唯一标识
+
     查询参数:
-        repo_name: 仓库名称（可选，模糊搜索）
-        repo_type: 仓库类型（可选，1=go, 2=python, 3=java）
+        metric: 指标类型（可选，line=全量覆盖率（默认）, diff=增量覆盖率,
+                block=block覆盖率, trend=趋势箭头）
+        branch: 分支名（可选，默认取最近更新的报告）
+        style: 徽章样式（可选，flat（默认）/flat-square/for-the-badge）
+        label: 自定义标签文本（可选，支持中文）
     """
     try:
-        repo_name = request.args.get('repo_name')
-        repo_type = request.args.get('repo_type')
-        
-        # 转换 repo_type 为整数（如果提供）
-        repo_type_int = None
-        if repo_type is not None:
-            try:
-                repo_type_int = int(repo_type)
-            except ValueError:
-                return jsonify({'error': 'Invalid repo_type, must be an integer'}), 400
-        
-        result = get_all_configs_logic(repo_name=repo_name, repo_type=repo_type_int)
-        return jsonify(result), 200
+        metric = request.args.get('metric', badge_manager.BADGE_METRIC_LINE)
+        branch = request.args.get('branch')
+        style = request.args.get('style', badge_manager.BADGE_STYLE_FLAT)
+        label = request.args.get('label')
+
+        if metric not in badge_manager.SUPPORTED_METRICS:
+            return jsonify({
+                'error': f'Invalid metric, must be one of: {", ".join(badge_manager.SUPPORTED_METRICS)}'
+            }), 400
+        if style not in badge_manager.SUPPORTED_STYLES:
+            return jsonify({
+                'error': f'Invalid style, must be one of: {", ".join(badge_manager.SUPPORTED_STYLES)}'
+            }), 400
+
+        svg = badge_manager.generate_badge(
+            repo_id, metric=metric, branch=branch, style=style, label=label
+        )
+
+        response = Response(svg, mimetype='image/svg+xml')
+        # 徽章通常嵌在README里，避免被缓存太久
+        response.headers['Cache-Control'] = 'no-cache, max-age=300'
+        return response
     except Exception as e:
-        logger.error(f"Error getting configs: {e}")
+        logger.error(f"Error generating badge: {e}")
         return jsonify({'error': str(e)}), 500
 
 
-# 获取单个仓库配置
-@app.route('/api/v1/coverage/configs/<repo_id>', methods=['GET'])
-def get_config_by_repo_id(repo_id):
+# 获取覆盖率小组件
+@app.route('/api/v1/coverage/widget/<repo_id>', methods=['GET'])
+def get_coverage_widget(repo_id):
     """
-    获取单个仓库配置
-    
+    获取可嵌入的覆盖率摘要小组件
+
     参数:
         repo_id: 仓库唯一标识
+
+    查询参数:
+        format: 返回格式（可选，json=数据（默认）, html=可嵌入iframe的HTML片段）
+        branch: 分支名（可选，默认取最近更新的报告）
     """
     try:
-        result = get_config_by_id_logic(repo_id)
-        if not result:
-            return jsonify({'error': 'Config not found'}), 404
-        return jsonify(result), 200
+        fmt = request.args.get('format', 'json')
+        branch = request.args.get('branch')
+
+        if fmt not in ('json', 'html'):
+            return jsonify({'error': 'Invalid format, must be json or html'}), 400
+
+        data = widget_manager.get_widget_data(repo_id, branch=branch)
+        if not data:
+            return jsonify({'error': 'No coverage data for this repo'}), 404
+
+        if fmt == 'html':
+            html = widget_manager.render_widget_html(data)
+            response = Response(html, mimetype='text/html')
+            response.headers['Cache-Control'] = 'no-cache, max-age=300'
+            return response
+
+        return jsonify(data), 200
     except Exception as e:
-        logger.error(f"Error getting config: {e}")
+        logger.error(f"Error generating widget: {e}")
         return jsonify({'error': str(e)}), 500
 
 
-# 创建仓库配置
-@app.route('/api/v1/coverage/configs', methods=['POST'])
-def create_config():
+# 触发目录同步
+@app.route('/api/v1/admin/ldap-sync', methods=['POST'])
+def trigger_ldap_sync():
     """
-    创建仓库配置
-    
-    请求体:
-        {
-            "repo_url": "https://github.com/fujifei/tuna.git",
-            "repo_id": "xxxxx",
-            "repo_type": 1,
-            "base_branch": "master",
-            "exclude_dirs": "cmd/;config/",
-            "exclude_files": "*._test.go;active_test.go"
-        }
-    
-    注意: 
-        - repo_name 会自动从 repo_url 中提取
-        - repo_type: 1=go语言, 2=python语言, 3=java语言（默认1）
+    手动触发一次 LDAP/SCIM 目录同步
+    正常情况下由 manager/ldap_sync.py daemon 定期执行
     """
     try:
-        data = request.get_json()
-        if not data:
-            return jsonify({'error': 'Invalid request body'}), 400
-        
-        repo_url = data.get('repo_url', '')
-        repo_id = data.get('repo_id', '')
-        repo_type = data.get('repo_type', 1)  # 默认值为1（go语言）
-        base_branch = data.get('base_branch', 'master')
-        exclude_dirs = data.get('exclude_dirs', '')
-        exclude_files = data.get('exclude_files', '')
-        
-        # 验证 repo_type
-        if repo_type not in [1, 2, 3]:
-            return jsonify({'error': 'Invalid repo_type, must be 1 (go), 2 (python), or 3 (java)'}), 400
-        
-        result = create_config_logic(
-            repo_url=repo_url,
-            repo_id=repo_id,
-            base_branch=base_branch,
-            exclude_dirs=exclude_dirs,
-            exclude_files=exclude_files,
-            repo_type=repo_type
-        )
-        
+        result = ldap_sync.sync_directory()
+        if result is None:
+            return jsonify({'error': 'Directory sync failed or not configured'}), 500
+
         return jsonify({
             'success': True,
             'data': result
-        }), 201
-    except ValueError as e:
-        return jsonify({'error': str(e)}), 400
-    except RuntimeError as e:
-        return jsonify({'error': str(e)}), 400
+        }), 200
     except Exception as e:
-        logger.error(f"Error creating config: {e}")
+        logger.error(f"Error syncing directory: {e}")
         return jsonify({'error': str(e)}), 500
 
 
-# 更新仓库配置
-@app.route('/api/v1/coverage/configs/<repo_id>', methods=['PUT'])
-def update_config(repo_id):
+# 获取报告的风险评分
+@app.route('/api/v1/coverage/reports/<int:report_id>/risk-score', methods=['GET'])
+def get_report_risk_score(report_id):
     """
-    更新仓库配置
-    
+    获取报告的风险评分
+    综合增量覆盖率、变更规模和历史覆盖率波动（权重可通过环境变量配置）
+
     参数:
-        repo_id: 仓库唯一标识
-    
-    请求体:
-        {
-            "base_branch": "master",
-            "exclude_dirs": "cmd/;config/",
-            "exclude_files": "*._test.go;active_test.go"
-        }
-    
-    注意: repo_url 和 repo_name 不可修改
+        report_id: 报告 ID
     """
     try:
-        data = request.get_json()
-        if not data:
-            return jsonify({'error': 'Invalid request body'}), 400
-        
-        base_branch = data.get('base_branch')
-        exclude_dirs = data.get('exclude_dirs')
-        exclude_files = data.get('exclude_files')
-        
-        result = update_config_logic(
-            repo_id=repo_id,
-            base_branch=base_branch,
-            exclude_dirs=exclude_dirs,
-            exclude_files=exclude_files
-        )
-        
-        return jsonify({
-            'success': True,
-            'data': result
-        }), 200
-    except RuntimeError as e:
-        return jsonify({'error': str(e)}), 404
+        result = risk_manager.calculate_risk_score(report_id)
+        if not result:
+            return jsonify({'error': 'Failed to calculate risk score'}), 500
+
+        return jsonify(result), 200
     except Exception as e:
-        logger.error(f"Error updating config: {e}")
+        logger.error(f"Error calculating risk score: {e}")
         return jsonify({'error': str(e)}), 500
 
 
-# 删除仓库配置
-@app.route('/api/v1/coverage/configs/<repo_id>', methods=['DELETE'])
-def delete_config(repo_id):
+# 上传覆盖率报告包（multipart）
+@app.route('/api/v1/coverage/upload/bundle', methods=['POST'])
+def upload_coverage_bundle():
     """
-    删除仓库配置
-    
-    参数:
-        repo_id: 仓库唯一标识
+    一次请求上传多个覆盖率文件和一个 manifest，组装成一条消息原子发布
+    CI 不再需要 N 次串行上传和部分失败处理
+
+    multipart 表单:
+        manifest: JSON（{repo, repo_id, branch, commit, ci,
+                         files: [{field: 表单字段名, format: goc|pyca|jacoco}]}）
+        <field>: manifest.files 中每一项对应的覆盖率文件
+
+    校验全部通过后才发布；任何一项不合法则整个请求失败，不产生部分数据
     """
     try:
-        delete_config_logic(repo_id)
+        manifest_raw = None
+        if 'manifest' in request.files:
+            manifest_raw = request.files['manifest'].read().decode('utf-8')
+        elif 'manifest' in request.form:
+            manifest_raw = request.form['manifest']
+        if not manifest_raw:
+            return jsonify({'error': 'manifest is required'}), 400
+
+        try:
+            manifest = json.loads(manifest_raw)
+        except ValueError:
+            return jsonify({'error': 'manifest is not valid JSON'}), 400
+
+        repo_id = manifest.get('repo_id', '')
+        branch = manifest.get('branch', '')
+        commit = manifest.get('commit', '')
+        if not repo_id or not branch or not commit:
+            return jsonify({'error': 'repo_id, branch and commit are required in manifest'}), 400
+
+        file_entries = manifest.get('files', [])
+        if not file_entries:
+            return jsonify({'error': 'manifest.files must not be empty'}), 400
+
+        # 先做全量校验，保证不会发布半个 bundle
+        coverages = []
+        for entry in file_entries:
+            field = entry.get('field', '')
+            coverage_format = entry.get('format', '')
+            if coverage_format not in SUPPORTED_COVERAGE_FORMATS:
+                return jsonify({
+                    'error': f'unsupported coverage format: {coverage_format}'
+                }), 400
+            if field not in request.files:
+                return jsonify({'error': f'missing file part: {field}'}), 400
+            coverages.append({
+                'format': coverage_format,
+                'raw': request.files[field].read().decode('utf-8')
+            })
+
+        message = {
+            'repo': manifest.get('repo', ''),
+            'repo_id': repo_id,
+            'branch': branch,
+            'commit': commit,
+            'ci': manifest.get('ci', {}),
+            'coverages': coverages,
+            'timestamp': manifest.get('timestamp', 0)
+        }
+
+        if not publish_manager.publish_coverage_message(message):
+            status_manager.record_heartbeat(
+                'http-upload', status='error', detail='publish failed'
+            )
+            return jsonify({'error': 'Failed to publish coverage bundle'}), 500
+
+        status_manager.record_heartbeat('http-upload')
         return jsonify({
             'success': True,
-            'message': 'Config deleted successfully'
+            'repo_id': repo_id,
+            'branch': branch,
+            'commit': commit,
+            'payloads': len(coverages)
         }), 200
-    except RuntimeError as e:
-        return jsonify({'error': str(e)}), 404
     except Exception as e:
-        logger.error(f"Error deleting config: {e}")
+        logger.error(f"Error uploading coverage bundle: {e}")
         return jsonify({'error': str(e)}), 500
 
 
-# 根据仓库URL获取RepoID
-@app.route('/api/v1/coverage/configs/repo-id', methods=['POST'])
+# 创建断点续传上传会话
+@app.route('/api/v1/coverage/upload/resumable', methods=['POST'])
+def create_resumable_upload():
+    """
+    创建断点续传上传会话（适用于超大覆盖率文件）
+
+    请求体(JSON):
+        repo, repo_id, branch, commit, ci, format: 报告元信息
+        total_size: 完整载荷字节数（必填）
+    """
+    try:
+        data = request.get_json()
+        if not data:
+            return jsonify({'error': 'Request body is required'}), 400
+
+        total_size = data.get('total_size', 0)
+        if not isinstance(total_size, int) or total_size <= 0:
+            return jsonify({'error': 'total_size must be a positive integer'}), 400
+        if not data.get('repo_id') or not data.get('branch') or not data.get('commit'):
+            return jsonify({'error': 'repo_id, branch and commit are required'}), 400
+
+        manifest = {
+            'repo': data.get('repo', ''),
+            'repo_id': data['repo_id'],
+            'branch': data['branch'],
+            'commit': data['commit'],
+            'ci': data.get('ci', {}),
+            'format': data.get('format', 'goc'),
+            'timestamp': data.get('timestamp', 0)
+        }
+        if manifest['format'] not in SUPPORTED_COVERAGE_FORMATS:
+            return jsonify({'error': f'unsupported coverage format: {manifest["format"]}'}), 400
+
+        upload_id = upload_manager.create_upload(manifest, total_size)
+        return jsonify({'upload_id': upload_id, 'received': 0, 'total_size': total_size}), 201
+    except Exception as e:
+        logger.error(f"Error creating resumable upload: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 查询上传会话状态（断线后客户端据此决定续传偏移）
+@app.route('/api/v1/coverage/upload/resumable/<upload_id>', methods=['GET'])
+def get_resumable_upload_status(upload_id):
+    try:
+        status = upload_manager.get_upload_status(upload_id)
+        if not status:
+            return jsonify({'error': 'Upload session not found'}), 404
+        return jsonify(status), 200
+    except Exception as e:
+        logger.error(f"Error getting upload status: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 追加一个分块
+@app.route('/api/v1/coverage/upload/resumable/<upload_id>', methods=['PATCH'])
+def append_resumable_upload_chunk(upload_id):
+    """
+    追加分块，偏移通过 Content-Range 头指定（bytes start-end/total）
+    偏移与服务端已接收字节数不一致时返回 409 和当前偏移
+    """
+    try:
+        content_range = request.headers.get('Content-Range', '')
+        match = re.match(r'bytes\s+(\d+)-(\d+)/(\d+|\*)', content_range)
+        if not match:
+            return jsonify({'error': 'Content-Range header is required (bytes start-end/total)'}), 400
+        start = int(match.group(1))
+
+        chunk = request.get_data()
+        if not chunk:
+            return jsonify({'error': 'Request body is empty'}), 400
+
+        try:
+            status = upload_manager.append_chunk(upload_id, start, chunk)
+        except ValueError as e:
+            current = upload_manager.get_upload_status(upload_id)
+            return jsonify({
+                'error': str(e),
+                'received': current['received'] if current else 0
+            }), 409
+
+        if not status:
+            return jsonify({'error': 'Upload session not found'}), 404
+        return jsonify(status), 200
+    except Exception as e:
+        logger.error(f"Error appending upload chunk: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 完成上传会话并发布报告
+@app.route('/api/v1/coverage/upload/resumable/<upload_id>/complete', methods=['POST'])
+def complete_resumable_upload(upload_id):
+    try:
+        try:
+            message = upload_manager.complete_upload(upload_id)
+        except ValueError as e:
+            return jsonify({'error': str(e)}), 400
+
+        if not message:
+            return jsonify({'error': 'Upload session not found'}), 404
+
+        if not publish_manager.publish_coverage_message(message):
+            status_manager.record_heartbeat(
+                'http-upload', status='error', detail='publish failed'
+            )
+            return jsonify({'error': 'Failed to publish coverage report'}), 500
+
+        status_manager.record_heartbeat('http-upload')
+        return jsonify({
+            'success': True,
+            'repo_id': message['repo_id'],
+            'branch': message['branch'],
+            'commit': message['commit']
+        }), 200
+    except Exception as e:
+        logger.error(f"Error completing resumable upload: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 放弃上传会话
+@app.route('/api/v1/coverage/upload/resumable/<upload_id>', methods=['DELETE'])
+def discard_resumable_upload(upload_id):
+    try:
+        upload_manager.discard_upload(upload_id)
+        return jsonify({'success': True}), 200
+    except Exception as e:
+        logger.error(f"Error discarding upload: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 获取MR中被修改函数的覆盖率变化
+@app.route('/api/v1/coverage/reports/<int:report_id>/function-deltas', methods=['GET'])
+def get_report_function_deltas(report_id):
+    """
+    获取报告对应变更中每个被修改函数修改前后的覆盖率
+    供 reviewer 查看函数级的覆盖变化（如 Foo 从 85% 降到 40%）
+
+    参数:
+        report_id: 报告 ID
+    """
+    try:
+        result = function_manager.calculate_function_deltas(report_id)
+        if result is None:
+            return jsonify({'error': 'Failed to calculate function deltas'}), 500
+
+        return jsonify(result), 200
+    except Exception as e:
+        logger.error(f"Error calculating function deltas: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 获取MR的reviewer建议
+@app.route('/api/v1/coverage/reports/<int:report_id>/reviewer-suggestions', methods=['GET'])
+def get_report_reviewer_suggestions(report_id):
+    """
+    为报告对应的MR推荐"写过相关测试"的reviewer
+    按覆盖被改代码的测试文件的git blame贡献排序
+
+    查询参数:
+        limit: 返回人数上限（可选，默认5）
+    """
+    try:
+        limit = int(request.args.get('limit', 5))
+        result = reviewer_manager.suggest_reviewers(report_id, limit=limit)
+        if result is None:
+            return jsonify({'error': 'Failed to suggest reviewers'}), 500
+
+        return jsonify(result), 200
+    except Exception as e:
+        logger.error(f"Error suggesting reviewers: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 获取报告血缘（数字是怎么来的）
+@app.route('/api/v1/coverage/reports/<int:report_id>/lineage', methods=['GET'])
+def get_report_lineage(report_id):
+    """
+    获取报告血缘：哪些原始报告、合并、carry-forward参与了
+    该commit展示的数字（详见lineage_manager）
+    """
+    try:
+        result = lineage_manager.get_report_lineage(report_id)
+        if result is None:
+            return jsonify({'error': 'Report not found'}), 404
+
+        return jsonify(result), 200
+    except Exception as e:
+        logger.error(f"Error getting report lineage: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 获取发布区间的覆盖率变更日志
+@app.route('/api/v1/coverage/changelog', methods=['GET'])
+def get_release_changelog():
+    """
+    获取发布区间内变更包的前后覆盖率对比（发布就绪评审用）
+
+    查询参数:
+        repo_id: 仓库唯一标识（必填）
+        from: 发布区间起点commit（必填）
+        to: 发布区间终点commit（必填）
+    """
+    try:
+        repo_id = request.args.get('repo_id')
+        from_commit = request.args.get('from')
+        to_commit = request.args.get('to')
+        if not repo_id or not from_commit or not to_commit:
+            return jsonify({
+                'error': 'Missing required parameters: repo_id, from, to'
+            }), 400
+
+        result = changelog_manager.build_release_changelog(
+            repo_id, from_commit, to_commit
+        )
+        if result is None:
+            return jsonify({'error': 'Failed to build release changelog'}), 500
+
+        return jsonify(result), 200
+    except Exception as e:
+        logger.error(f"Error building release changelog: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 获取"已覆盖但未被变异杀死"的行
+@app.route('/api/v1/coverage/mutation/weak-spots', methods=['GET'])
+def get_mutation_weak_spots():
+    """
+    获取已被测试覆盖但存在存活变异体的行
+
+    查询参数:
+        repo_id: 仓库唯一标识（必填）
+        branch: 分支名（必填）
+    """
+    try:
+        repo_id = request.args.get('repo_id')
+        branch = request.args.get('branch')
+        if not repo_id or not branch:
+            return jsonify({'error': 'Missing required parameters: repo_id, branch'}), 400
+
+        result = mutation_manager.get_covered_unkilled_lines(repo_id, branch)
+        return jsonify({
+            'data': result,
+            'total': len(result)
+        }), 200
+    except Exception as e:
+        logger.error(f"Error getting mutation weak spots: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 获取测试结果历史
+@app.route('/api/v1/coverage/test-results', methods=['GET'])
+def get_test_results():
+    """
+    获取测试结果历史
+
+    查询参数:
+        repo_id: 仓库唯一标识（必填）
+        branch: 分支名（必填）
+        limit: 返回条数（可选，默认20）
+    """
+    try:
+        repo_id = request.args.get('repo_id')
+        branch = request.args.get('branch')
+        if not repo_id or not branch:
+            return jsonify({'error': 'Missing required parameters: repo_id, branch'}), 400
+
+        limit = int(request.args.get('limit', 20))
+        results = testresult_manager.get_test_results(repo_id, branch, limit=limit)
+        return jsonify({
+            'data': results,
+            'total': len(results)
+        }), 200
+    except Exception as e:
+        logger.error(f"Error getting test results: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 获取测试结果与覆盖率的关联分析
+@app.route('/api/v1/coverage/test-results/correlation', methods=['GET'])
+def get_test_result_correlation():
+    """
+    将指定 commit 的测试结果与覆盖率变化关联
+    覆盖率下降且有失败/跳过测试时给出提示
+
+    查询参数:
+        repo_id: 仓库唯一标识（必填）
+        branch: 分支名（必填）
+        commit: commit hash（必填）
+    """
+    try:
+        repo_id = request.args.get('repo_id')
+        branch = request.args.get('branch')
+        commit = request.args.get('commit')
+        if not repo_id or not branch or not commit:
+            return jsonify({'error': 'Missing required parameters: repo_id, branch, commit'}), 400
+
+        result = testresult_manager.correlate_with_coverage(repo_id, branch, commit)
+        if not result:
+            return jsonify({'error': 'No test results found for this commit'}), 404
+
+        return jsonify(result), 200
+    except Exception as e:
+        logger.error(f"Error correlating test results: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 评估报告的覆盖率门禁
+@app.route('/api/v1/coverage/reports/<int:report_id>/gate', methods=['GET'])
+def evaluate_report_gate(report_id):
+    """
+    评估报告的覆盖率门禁
+    根据仓库配置的 gate_threshold 判断增量覆盖率是否达标
+    commit message 含 [skip coverage] 标记时降级为 advisory（记录审计日志）
+
+    参数:
+        report_id: 报告 ID
+    """
+    try:
+        # 门禁是阻塞合并的路径，走优先通道连接池
+        with priority_lane():
+            result = gate_manager.evaluate_gate(report_id)
+        if not result:
+            return jsonify({'error': 'Failed to evaluate gate'}), 500
+
+        return jsonify(result), 200
+    except Exception as e:
+        logger.error(f"Error evaluating gate: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 将门禁结果同步到 GitLab
+@app.route('/api/v1/coverage/reports/<int:report_id>/gate/enforce', methods=['POST'])
+def enforce_report_gate(report_id):
+    """
+    评估门禁并将结果同步到 GitLab（commit status，可选 MR 审批规则）
+
+    参数:
+        report_id: 报告 ID
+
+    请求体:
+        {
+            "project_id": "123",      # GitLab 项目 ID（必填）
+            "mr_iid": 45              # MR iid（可选，提供时同步审批规则）
+        }
+    """
+    try:
+        data = request.get_json()
+        if not data or not data.get('project_id'):
+            return jsonify({'error': 'Missing project_id'}), 400
+
+        # 门禁评估和MR同步都是阻塞合并的路径，整体走优先通道连接池
+        with priority_lane():
+            gate_result = gate_manager.evaluate_gate(report_id)
+            if not gate_result:
+                return jsonify({'error': 'Failed to evaluate gate'}), 500
+
+            db = get_db()
+            report = db.query(CoverageReport).filter(CoverageReport.id == report_id).first()
+            if not report:
+                return jsonify({'error': 'Report not found'}), 404
+            report_repo_id = report.repo_id
+            # 仓库URL在配置表里（报告上没有存）
+            report_config = db.query(CoverageConfig).filter(
+                CoverageConfig.repo_id == report.repo_id
+            ).first()
+            report_repo_url = report_config.repo_url if report_config else ''
+            report_branch = report.branch
+            report_commit = report.commit
+
+            status_result = gitlab_manager.publish_commit_status(
+                str(data['project_id']), report.commit, gate_result
+            )
+
+            approval_result = None
+            if data.get('mr_iid'):
+                approval_result = gitlab_manager.ensure_approval_rule(
+                    str(data['project_id']), int(data['mr_iid']), gate_result
+                )
+
+        # 接近配额时在MR下追加软性提醒（失败只记日志，绝不影响门禁结果）
+        quota_notice = None
+        if data.get('mr_iid'):
+            try:
+                quota_notice = quota_manager.build_mr_notice(report_repo_id)
+                if quota_notice:
+                    gitlab_manager.post_mr_note(
+                        str(data['project_id']), int(data['mr_iid']), quota_notice
+                    )
+            except Exception as quota_err:
+                logger.warning(f"Failed to post quota notice: {quota_err}")
+
+        # 推送"门禁已评估"事件给外部编排系统（HMAC签名，
+        # 失败只记日志，绝不影响门禁结果）
+        try:
+            webhook_manager.notify_gate_evaluated(report_id, gate_result)
+        except Exception as webhook_err:
+            logger.warning(f"Failed to dispatch gate webhook: {webhook_err}")
+
+        # 新增未覆盖行提醒评论（带跳转到代码的深链接，默认关闭，
+        # 失败只记日志，绝不影响门禁结果）
+        diff_comment = None
+        if data.get('mr_iid'):
+            try:
+                diff_comment = build_mr_uncovered_comment(
+                    report_repo_url, report_repo_id,
+                    report_branch, report_commit
+                )
+                if diff_comment:
+                    gitlab_manager.post_mr_note(
+                        str(data['project_id']), int(data['mr_iid']), diff_comment
+                    )
+            except Exception as comment_err:
+                logger.warning(f"Failed to post diff coverage comment: {comment_err}")
+
+        return jsonify({
+            'success': True,
+            'gate': gate_result,
+            'commit_status': status_result,
+            'approval_rule': approval_result,
+            'quota_notice': quota_notice
+        }), 200
+    except RuntimeError as e:
+        return jsonify({'error': str(e)}), 502
+    except Exception as e:
+        logger.error(f"Error enforcing gate on GitLab: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 获取覆盖率趋势
+@app.route('/api/v1/coverage/trend', methods=['GET'])
+def get_coverage_trend():
+    """
+    获取覆盖率趋势（基于快照）
+
+    查询参数:
+        repo_id: 仓库唯一标识（必填）
+        branch: 分支名（必填）
+        order: 排序方式（可选，time=按时间（默认）, ancestry=按first-parent提交顺序）
+        clock: time模式的时间轴（可选，reported=报告声明时间（默认）, received=平台收到时间）
+        limit: 返回点数上限（可选，默认100）
+        meta.<key>: 按自定义metadata过滤（可选，可叠加多个，仅匹配字符串值）
+    """
+    try:
+        repo_id = request.args.get('repo_id')
+        branch = request.args.get('branch')
+        if not repo_id or not branch:
+            return jsonify({'error': 'Missing required parameters: repo_id, branch'}), 400
+
+        order = request.args.get('order', trend_manager.ORDER_TIME)
+        if order not in trend_manager.SUPPORTED_ORDERS:
+            return jsonify({
+                'error': f'Invalid order, must be one of: {", ".join(trend_manager.SUPPORTED_ORDERS)}'
+            }), 400
+
+        clock = request.args.get('clock', trend_manager.CLOCK_REPORTED)
+        if clock not in trend_manager.SUPPORTED_CLOCKS:
+            return jsonify({
+                'error': f'Invalid clock, must be one of: {", ".join(trend_manager.SUPPORTED_CLOCKS)}'
+            }), 400
+
+        limit = int(request.args.get('limit', 100))
+
+        # 自定义metadata过滤（meta.<key>=<value>，可叠加多个）
+        metadata_filters = {
+            arg_key[len('meta.'):]: arg_value
+            for arg_key, arg_value in request.args.items()
+            if arg_key.startswith('meta.')
+        }
+
+        points = trend_manager.get_coverage_trend(
+            repo_id, branch, order=order, limit=limit, clock=clock,
+            metadata_filters=metadata_filters
+        )
+        if points is None:
+            return jsonify({'error': 'Failed to compute ancestry-ordered trend'}), 500
+
+        if wants_csv():
+            return csv_response(points, 'coverage_trend.csv')
+
+        # 叠加趋势窗口内的外部事件标注（发布/故障等），方便解释突变
+        annotations = []
+        if points:
+            timestamps = [p['created_at'] for p in points if p.get('created_at')]
+            if timestamps:
+                annotations = annotation_manager.get_annotations_in_window(
+                    repo_id, min(timestamps), max(timestamps)
+                )
+
+        return jsonify({
+            'data': points,
+            'total': len(points),
+            'order': order,
+            'annotations': annotations
+        }), 200
+    except Exception as e:
+        logger.error(f"Error getting coverage trend: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 批量查询多个仓库/分支的最新覆盖率
+@app.route('/api/v1/coverage/batch', methods=['POST'])
+def batch_coverage_query():
+    """
+    一次查询多个仓库/分支的最新覆盖率快照
+    （门户渲染全组织概览用，避免成百上千个串行请求）
+
+    请求体:
+        {
+            "queries": [
+                {"repo_id": "123", "branch": "master"},  # branch可选，
+                ...                                       # 缺省用基准分支
+            ]
+        }
+        条数上限由BATCH_QUERY_MAX控制（默认100）
+
+    返回:
+        {'data': [{'repo_id', 'branch', 'found', 'summary'}], 'total'}
+        summary为最新快照（found为False时为None）
+    """
+    try:
+        data = request.get_json() or {}
+        queries = data.get('queries')
+        if not isinstance(queries, list) or not queries:
+            return jsonify({'error': "request body must contain a 'queries' list"}), 400
+        if len(queries) > BATCH_QUERY_MAX:
+            return jsonify({
+                'error': f'too many queries (max {BATCH_QUERY_MAX})'
+            }), 400
+
+        db = get_db()
+        results = []
+        for query in queries:
+            if not isinstance(query, dict) or not query.get('repo_id'):
+                return jsonify({'error': f'each query needs a repo_id: {query}'}), 400
+            repo_id = str(query['repo_id'])
+            branch = query.get('branch')
+
+            if not branch:
+                config = db.query(CoverageConfig).filter(
+                    CoverageConfig.repo_id == repo_id
+                ).first()
+                branch = (config.base_branch if config else None) or 'master'
+
+            snapshot = db.query(CoverageSnapshot).filter(
+                CoverageSnapshot.repo_id == repo_id,
+                CoverageSnapshot.branch == branch
+            ).order_by(CoverageSnapshot.created_at.desc()).first()
+
+            results.append({
+                'repo_id': repo_id,
+                'branch': branch,
+                'found': snapshot is not None,
+                'summary': snapshot.to_dict() if snapshot else None
+            })
+
+        return jsonify({'data': results, 'total': len(results)}), 200
+    except Exception as e:
+        logger.error(f"Error in batch coverage query: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# ==================== Event Annotation APIs ====================
+
+# 外部事件webhook（发布、故障、测试基础设施中断等）
+@app.route('/api/v1/events/webhook', methods=['POST'])
+def receive_event_webhook():
+    """
+    接收外部事件webhook，落库后叠加到趋势接口的annotations中
+
+    请求体:
+        {
+            "event_type": "deploy",        # deploy/incident/outage（必填）
+            "title": "v2.3.1上线",          # 事件标题（必填）
+            "repo_id": "123",              # 可选，不填为全局事件
+            "detail": "...",               # 可选
+            "source": "cd-pipeline",       # 可选，来源系统
+            "occurred_at": 1234567890000   # 可选，毫秒，默认当前时间
+        }
+    """
+    try:
+        data = request.get_json()
+        if not data:
+            return jsonify({'error': 'Missing request body'}), 400
+
+        result = annotation_manager.record_event(
+            event_type=data.get('event_type'),
+            title=data.get('title'),
+            repo_id=data.get('repo_id'),
+            detail=data.get('detail'),
+            source=data.get('source'),
+            occurred_at=data.get('occurred_at')
+        )
+        return jsonify({'success': True, 'data': result}), 201
+    except ValueError as e:
+        return jsonify({'error': str(e)}), 400
+    except Exception as e:
+        logger.error(f"Error recording event webhook: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 获取事件标注列表
+@app.route('/api/v1/events', methods=['GET'])
+def list_event_annotations():
+    """
+    获取事件标注列表
+
+    查询参数:
+        repo_id: 仓库唯一标识（可选，会包含全局事件）
+        event_type: 事件类型过滤（可选）
+        limit: 返回条数上限（可选，默认100）
+    """
+    try:
+        annotations = annotation_manager.list_annotations(
+            repo_id=request.args.get('repo_id'),
+            event_type=request.args.get('event_type'),
+            limit=int(request.args.get('limit', 100))
+        )
+        return jsonify({'data': annotations, 'total': len(annotations)}), 200
+    except Exception as e:
+        logger.error(f"Error listing event annotations: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 按序列号游标拉取领域事件
+@app.route('/api/v1/events/log', methods=['GET'])
+def read_domain_events():
+    """
+    按序列号游标拉取领域事件（回放/补数据用，详见eventlog_manager）
+
+    查询参数:
+        after_id: 只返回序列号大于该值的事件（默认0，即从头回放）
+        event_type: 事件类型过滤（可选）
+        repo_id: 仓库过滤（可选）
+        limit: 返回条数上限（默认100）
+    """
+    try:
+        result = eventlog_manager.read_events(
+            after_id=int(request.args.get('after_id', 0)),
+            event_type=request.args.get('event_type'),
+            repo_id=request.args.get('repo_id'),
+            limit=int(request.args.get('limit', 100))
+        )
+        return jsonify(result), 200
+    except Exception as e:
+        logger.error(f"Error reading domain events: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# ==================== Report Notes APIs ====================
+
+# 创建报告备注
+@app.route('/api/v1/coverage/notes', methods=['POST'])
+def create_report_note():
+    """
+    为某个commit的覆盖率结果添加备注
+
+    请求体:
+        {
+            "repo_id": "xxxxx",
+            "branch": "master",
+            "commit": "abc123...",
+            "author": "zhangsan",
+            "note": "覆盖率下降符合预期：删除了legacy模块"
+        }
+    """
+    try:
+        data = request.get_json()
+        if not data:
+            return jsonify({'error': 'Invalid request body'}), 400
+
+        repo_id = data.get('repo_id', '')
+        branch = data.get('branch', '')
+        commit = data.get('commit', '')
+        note_text = data.get('note', '')
+        if not repo_id or not branch or not commit:
+            return jsonify({'error': 'repo_id, branch and commit are required'}), 400
+        if not note_text:
+            return jsonify({'error': 'note is required'}), 400
+
+        db = get_db()
+        note = ReportNote(
+            repo_id=repo_id,
+            branch=branch,
+            commit=commit,
+            author=data.get('author', ''),
+            note=note_text,
+            created_at=int(time.time() * 1000)
+        )
+        db.add(note)
+        db.commit()
+
+        return jsonify({
+            'success': True,
+            'data': note.to_dict()
+        }), 201
+    except Exception as e:
+        logger.error(f"Error creating report note: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 查询报告备注
+@app.route('/api/v1/coverage/notes', methods=['GET'])
+def get_report_notes():
+    """
+    查询备注列表
+
+    查询参数:
+        repo_id: 仓库唯一标识（必填）
+        branch: 分支名（可选）
+        commit: commit hash（可选）
+    """
+    try:
+        repo_id = request.args.get('repo_id')
+        if not repo_id:
+            return jsonify({'error': 'repo_id is required'}), 400
+
+        db = get_db()
+        query = db.query(ReportNote).filter(ReportNote.repo_id == repo_id)
+        if branch := request.args.get('branch'):
+            query = query.filter(ReportNote.branch == branch)
+        if commit := request.args.get('commit'):
+            query = query.filter(ReportNote.commit == commit)
+
+        notes = query.order_by(ReportNote.created_at.desc()).all()
+        return jsonify({
+            'data': [n.to_dict() for n in notes],
+            'total': len(notes)
+        }), 200
+    except Exception as e:
+        logger.error(f"Error getting report notes: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 删除报告备注
+@app.route('/api/v1/coverage/notes/<int:note_id>', methods=['DELETE'])
+def delete_report_note(note_id):
+    try:
+        db = get_db()
+        note = db.query(ReportNote).filter(ReportNote.id == note_id).first()
+        if not note:
+            return jsonify({'error': 'Note not found'}), 404
+
+        db.delete(note)
+        db.commit()
+        return jsonify({'success': True}), 200
+    except Exception as e:
+        logger.error(f"Error deleting report note: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# ==================== Coverage Goal APIs ====================
+
+# 创建覆盖率目标
+@app.route('/api/v1/coverage/goals', methods=['POST'])
+def create_coverage_goal():
+    """
+    创建仓库级覆盖率目标
+
+    请求体:
+        {
+            "repo_id": "xxxxx",
+            "target_rate": 80.0,
+            "deadline": 1735660800000,
+            "branch": "master",
+            "description": "Q3 OKR",
+            "created_by": "zhangsan"
+        }
+    """
+    try:
+        data = request.get_json()
+        if not data:
+            return jsonify({'error': 'Invalid request body'}), 400
+
+        repo_id = data.get('repo_id', '')
+        target_rate = data.get('target_rate')
+        deadline = data.get('deadline')
+        if not repo_id or target_rate is None or not deadline:
+            return jsonify({'error': 'repo_id, target_rate and deadline are required'}), 400
+        if not (0 < float(target_rate) <= 100):
+            return jsonify({'error': 'target_rate must be in (0, 100]'}), 400
+
+        result = goal_manager.create_goal(
+            repo_id=repo_id,
+            target_rate=float(target_rate),
+            deadline=int(deadline),
+            branch=data.get('branch', 'master'),
+            description=data.get('description', ''),
+            created_by=data.get('created_by', '')
+        )
+        return jsonify({'success': True, 'data': result}), 201
+    except Exception as e:
+        logger.error(f"Error creating coverage goal: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 查询覆盖率目标（附带进度）
+@app.route('/api/v1/coverage/goals', methods=['GET'])
+def get_coverage_goals():
+    """
+    查询目标列表
+
+    查询参数:
+        repo_id: 仓库唯一标识（可选）
+        status: 目标状态过滤（active/achieved/missed，可选）
+    """
+    try:
+        goals = goal_manager.list_goals(
+            repo_id=request.args.get('repo_id'),
+            status=request.args.get('status')
+        )
+        return jsonify({'data': goals, 'total': len(goals)}), 200
+    except Exception as e:
+        logger.error(f"Error getting coverage goals: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 删除覆盖率目标
+@app.route('/api/v1/coverage/goals/<int:goal_id>', methods=['DELETE'])
+def delete_coverage_goal(goal_id):
+    try:
+        if not goal_manager.delete_goal(goal_id):
+            return jsonify({'error': 'Goal not found'}), 404
+        return jsonify({'success': True}), 200
+    except Exception as e:
+        logger.error(f"Error deleting coverage goal: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 获取仓库在组织内的覆盖率百分位
+@app.route('/api/v1/coverage/benchmark/<repo_id>', methods=['GET'])
+def get_coverage_benchmark(repo_id):
+    """
+    将仓库的覆盖率与同语言的全部接入仓库做百分位对比
+
+    参数:
+        repo_id: 仓库唯一标识
+    """
+    try:
+        result = benchmark_manager.get_repo_benchmark(repo_id)
+        if not result:
+            return jsonify({'error': 'Repo not found or has no coverage data'}), 404
+        return jsonify(result), 200
+    except Exception as e:
+        logger.error(f"Error getting coverage benchmark: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 获取分语言覆盖率
+@app.route('/api/v1/coverage/languages', methods=['GET'])
+def get_language_breakdown():
+    """
+    获取仓库分支的分语言覆盖率（混合仓库各语言分别计算）
+
+    查询参数:
+        repo_id: 仓库唯一标识（必填）
+        branch: 分支名（必填）
+    """
+    try:
+        repo_id = request.args.get('repo_id')
+        branch = request.args.get('branch')
+        if not repo_id or not branch:
+            return jsonify({'error': 'repo_id and branch are required'}), 400
+
+        result = language_manager.get_language_breakdown(repo_id, branch)
+        return jsonify(result), 200
+    except Exception as e:
+        logger.error(f"Error getting language breakdown: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 获取未测试的导出 API 报告
+@app.route('/api/v1/coverage/api-surface', methods=['GET'])
+def get_untested_api_surface():
+    """
+    列出 Go 仓库零覆盖的导出函数/方法
+    作为"公共 API 没有测试"的优先级清单
+
+    查询参数:
+        repo_id: 仓库唯一标识（必填）
+        branch: 分支名（必填）
+    """
+    try:
+        repo_id = request.args.get('repo_id')
+        branch = request.args.get('branch')
+        if not repo_id or not branch:
+            return jsonify({'error': 'repo_id and branch are required'}), 400
+
+        result = api_surface_manager.get_untested_api_report(repo_id, branch)
+        if result is None:
+            return jsonify({'error': 'Repo is not a Go repo or has no reports'}), 404
+
+        return jsonify(result), 200
+    except Exception as e:
+        logger.error(f"Error getting untested API surface: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 获取文件级覆盖率历史
+@app.route('/api/v1/coverage/files/history', methods=['GET'])
+def get_file_coverage_history():
+    """
+    获取单个文件在分支上跨commit的覆盖率历史
+    用于定位关键文件是什么时候丢掉测试的
+
+    查询参数:
+        repo_id: 仓库唯一标识（必填）
+        branch: 分支名（必填）
+        file_path: 文件路径（必填）
+        limit: 返回点数上限（默认100）
+    """
+    try:
+        repo_id = request.args.get('repo_id')
+        branch = request.args.get('branch')
+        file_path = request.args.get('file_path')
+        if not repo_id or not branch or not file_path:
+            return jsonify({'error': 'repo_id, branch and file_path are required'}), 400
+        limit = int(request.args.get('limit', 100))
+
+        db = get_db()
+        # 沿重命名链回溯旧名，重命名前的快照一并纳入历史
+        paths = rename_manager.history_paths(db, repo_id, branch, file_path)
+        snapshots = db.query(CoverageFileSnapshot).filter(
+            CoverageFileSnapshot.repo_id == repo_id,
+            CoverageFileSnapshot.branch == branch,
+            CoverageFileSnapshot.file_path.in_(paths)
+        ).order_by(CoverageFileSnapshot.created_at.desc()).limit(limit).all()
+
+        # 从旧到新返回（与趋势接口一致）
+        snapshots.reverse()
+
+        if wants_csv():
+            return csv_response(
+                [s.to_dict() for s in snapshots], 'file_coverage_history.csv'
+            )
+
+        return jsonify({
+            'repo_id': repo_id,
+            'branch': branch,
+            'file': file_path,
+            'history_paths': paths,
+            'data': [s.to_dict() for s in snapshots],
+            'total': len(snapshots)
+        }), 200
+    except Exception as e:
+        logger.error(f"Error getting file coverage history: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 创建保存的查询
+@app.route('/api/v1/coverage/queries', methods=['POST'])
+def create_saved_query():
+    """
+    保存一个参数化查询作为命名报表
+
+    请求体:
+        name: 查询名称（必填，唯一）
+        repo_ids: 仓库ID列表（必填）
+        branch: 分支名（可选，为空时用各仓库基准分支）
+        path_prefix: 路径前缀过滤（可选）
+        days: 时间窗口天数（可选，默认30）
+        description: 说明（可选）
+        created_by: 创建人（可选）
+    """
+    try:
+        data = request.get_json()
+        if not data:
+            return jsonify({'error': 'Request body is required'}), 400
+
+        name = data.get('name')
+        repo_ids = data.get('repo_ids')
+        if not name or not repo_ids or not isinstance(repo_ids, list):
+            return jsonify({'error': 'name and repo_ids (list) are required'}), 400
+
+        result = saved_query_manager.create_saved_query(
+            name=name,
+            repo_ids=repo_ids,
+            branch=data.get('branch', ''),
+            path_prefix=data.get('path_prefix', ''),
+            days=int(data.get('days', 30)),
+            description=data.get('description', ''),
+            created_by=data.get('created_by', '')
+        )
+        if result is None:
+            return jsonify({'error': f'Saved query already exists: {name}'}), 409
+
+        return jsonify({'success': True, 'data': result}), 201
+    except Exception as e:
+        logger.error(f"Error creating saved query: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 获取保存的查询列表
+@app.route('/api/v1/coverage/queries', methods=['GET'])
+def list_saved_queries():
+    """列出全部保存的查询"""
+    try:
+        queries = saved_query_manager.list_saved_queries()
+        return jsonify({'data': queries, 'total': len(queries)}), 200
+    except Exception as e:
+        logger.error(f"Error listing saved queries: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 获取单个保存的查询
+@app.route('/api/v1/coverage/queries/<int:query_id>', methods=['GET'])
+def get_saved_query(query_id):
+    """按ID获取保存的查询定义"""
+    try:
+        query = saved_query_manager.get_saved_query(query_id)
+        if query is None:
+            return jsonify({'error': f'Saved query not found: {query_id}'}), 404
+        return jsonify(query), 200
+    except Exception as e:
+        logger.error(f"Error getting saved query: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 删除保存的查询
+@app.route('/api/v1/coverage/queries/<int:query_id>', methods=['DELETE'])
+def delete_saved_query(query_id):
+    """删除保存的查询"""
+    try:
+        if not saved_query_manager.delete_saved_query(query_id):
+            return jsonify({'error': f'Saved query not found: {query_id}'}), 404
+        return jsonify({'success': True}), 200
+    except Exception as e:
+        logger.error(f"Error deleting saved query: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 执行保存的查询
+@app.route('/api/v1/coverage/queries/<int:query_id>/run', methods=['GET'])
+def run_saved_query(query_id):
+    """执行保存的查询并返回各仓库的覆盖率结果"""
+    try:
+        result = saved_query_manager.run_saved_query(query_id)
+        if result is None:
+            return jsonify({'error': f'Saved query not found: {query_id}'}), 404
+
+        if wants_csv():
+            return csv_response(result['repos'], 'saved_query_result.csv')
+
+        return jsonify(result), 200
+    except Exception as e:
+        logger.error(f"Error running saved query: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 平台状态
+@app.route('/api/v1/system/status', methods=['GET'])
+def get_system_status():
+    """
+    平台自身的状态摘要（摄入延迟、各通道最近一次成功处理、组件健康）
+    供内部状态页消费，用户可以自查慢是不是平台侧的问题
+    """
+    try:
+        return jsonify(status_manager.get_system_status()), 200
+    except Exception as e:
+        logger.error(f"Error getting system status: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 获取加权覆盖率
+@app.route('/api/v1/coverage/weighted', methods=['GET'])
+def get_weighted_coverage():
+    """
+    按配置的关键路径权重计算加权覆盖率，与原始覆盖率并列返回
+
+    查询参数:
+        repo_id: 仓库唯一标识（必填）
+        branch: 分支名（必填）
+    """
+    try:
+        repo_id = request.args.get('repo_id')
+        branch = request.args.get('branch')
+        if not repo_id or not branch:
+            return jsonify({'error': 'repo_id and branch are required'}), 400
+
+        result = weighting_manager.get_weighted_coverage(repo_id, branch)
+        if result is None:
+            return jsonify({'error': f'Config not found for repo: {repo_id}'}), 404
+
+        return jsonify(result), 200
+    except Exception as e:
+        logger.error(f"Error getting weighted coverage: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 查询仓库配额用量
+@app.route('/api/v1/coverage/quota', methods=['GET'])
+def get_repo_quota():
+    """
+    查询仓库的存储/上报配额用量（软性配额，只提醒不拒绝）
+
+    查询参数:
+        repo_id: 仓库唯一标识（必填）
+    """
+    try:
+        repo_id = request.args.get('repo_id')
+        if not repo_id:
+            return jsonify({'error': 'repo_id is required'}), 400
+
+        return jsonify(quota_manager.get_quota_status(repo_id)), 200
+    except Exception as e:
+        logger.error(f"Error getting quota status: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 创建只读分享链接
+@app.route('/api/v1/coverage/share', methods=['POST'])
+def create_share_link():
+    """
+    创建限时免认证的分享链接（范围限定为单个commit的覆盖率视图）
+    token明文仅在本次响应中返回一次
+
+    请求体:
+        repo_id: 仓库唯一标识（必填）
+        branch: 分支名（必填）
+        commit: 提交SHA（必填，须已有覆盖率快照）
+        ttl_hours: 有效期小时数（可选，默认72，超上限截断）
+        created_by: 创建人（可选，审计用）
+    """
+    try:
+        data = request.get_json()
+        if not data:
+            return jsonify({'error': 'Request body is required'}), 400
+
+        repo_id = data.get('repo_id')
+        branch = data.get('branch')
+        commit = data.get('commit')
+        if not repo_id or not branch or not commit:
+            return jsonify({'error': 'repo_id, branch and commit are required'}), 400
+
+        result = share_manager.create_share_link(
+            repo_id=repo_id,
+            branch=branch,
+            commit=commit,
+            ttl_hours=data.get('ttl_hours'),
+            created_by=data.get('created_by', '')
+        )
+        if result is None:
+            return jsonify({
+                'error': f'No coverage snapshot for commit: {commit}'
+            }), 404
+
+        result['url'] = f"/api/v1/share/{result['token']}"
+        return jsonify({'success': True, 'data': result}), 201
+    except Exception as e:
+        logger.error(f"Error creating share link: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 访问分享视图（免认证）
+@app.route('/api/v1/share/<token>', methods=['GET'])
+def get_shared_coverage(token):
+    """
+    按分享token获取commit的覆盖率视图
+    链接过期返回410，token无效返回404
+    """
+    try:
+        result = share_manager.resolve_share_link(token)
+        if result is None:
+            return jsonify({'error': 'Share link not found'}), 404
+        if result.get('expired'):
+            return jsonify({'error': 'Share link expired'}), 410
+
+        return jsonify(result), 200
+    except Exception as e:
+        logger.error(f"Error resolving share link: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 复杂度-覆盖率四象限
+@app.route('/api/v1/coverage/complexity', methods=['GET'])
+def get_complexity_quadrants():
+    """
+    获取复杂度-覆盖率四象限数据（复杂又没测的文件优先补测）
+    复杂度在报告入库时按源码估算，只有Go文件参与
+
+    查询参数:
+        repo_id: 仓库唯一标识（必填）
+        branch: 分支名（必填）
+    """
+    try:
+        repo_id = request.args.get('repo_id')
+        branch = request.args.get('branch')
+        if not repo_id or not branch:
+            return jsonify({'error': 'repo_id and branch are required'}), 400
+
+        result = complexity_manager.get_complexity_quadrants(repo_id, branch)
+        if result is None:
+            return jsonify({'error': f'Config not found for repo: {repo_id}'}), 404
+
+        return jsonify(result), 200
+    except Exception as e:
+        logger.error(f"Error getting complexity quadrants: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 平台事故列表
+@app.route('/api/v1/system/incidents', methods=['GET'])
+def list_platform_incidents():
+    """
+    列出平台事故（多个仓库同时覆盖率回退时的归并告警）
+
+    查询参数:
+        status: 状态过滤（可选，active/resolved）
+        limit: 返回条数上限（可选，默认20）
+    """
+    try:
+        status = request.args.get('status')
+        limit = int(request.args.get('limit', 20))
+        incidents = incident_manager.list_incidents(status=status, limit=limit)
+        return jsonify({'data': incidents, 'total': len(incidents)}), 200
+    except Exception as e:
+        logger.error(f"Error listing platform incidents: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 队列-存储对账记录
+@app.route('/api/v1/system/reconciliation', methods=['GET'])
+def list_reconciliation_checks():
+    """
+    列出最近的队列-存储对账记录（由reconcile.py周期产出）
+
+    查询参数:
+        limit: 返回条数上限（可选，默认50）
+    """
+    try:
+        limit = int(request.args.get('limit', 50))
+        checks = reconciliation_manager.list_checks(limit=limit)
+        return jsonify({'data': checks, 'total': len(checks)}), 200
+    except Exception as e:
+        logger.error(f"Error listing reconciliation checks: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 分支删除webhook
+@app.route('/api/v1/webhooks/branch-deleted', methods=['POST'])
+def handle_branch_deleted_webhook():
+    """
+    消费分支删除webhook，把分支数据标记为stale并进入保留期
+
+    支持两种请求体:
+        1. GitLab push事件（after全0表示分支删除，project.id 为仓库ID）
+        2. 简单格式 {"repo_id": "xxx", "branch": "feature/xxx"}
+    """
+    try:
+        data = request.get_json()
+        if not data:
+            return jsonify({'error': 'Invalid request body'}), 400
+
+        repo_id = None
+        branch = None
+        if data.get('object_kind') == 'push':
+            # GitLab push事件：after全0才是分支删除
+            if set(data.get('after', '')) != {'0'}:
+                return jsonify({'success': True, 'ignored': 'not a branch deletion'}), 200
+            repo_id = str(data.get('project', {}).get('id', ''))
+            ref = data.get('ref', '')
+            branch = ref[len('refs/heads/'):] if ref.startswith('refs/heads/') else ref
+        else:
+            repo_id = data.get('repo_id')
+            branch = data.get('branch')
+
+        if not repo_id or not branch:
+            return jsonify({'error': 'repo_id and branch are required'}), 400
+
+        result = retention_manager.mark_branch_deleted(repo_id, branch)
+        return jsonify({'success': True, 'data': result}), 200
+    except Exception as e:
+        logger.error(f"Error handling branch deletion webhook: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 获取保留期中的失效分支
+@app.route('/api/v1/coverage/stale-branches', methods=['GET'])
+def get_stale_branches():
+    """
+    列出处于保留期的失效分支
+
+    查询参数:
+        repo_id: 仓库唯一标识（可选）
+    """
+    try:
+        branches = retention_manager.get_stale_branches(
+            repo_id=request.args.get('repo_id')
+        )
+        return jsonify({'data': branches, 'total': len(branches)}), 200
+    except Exception as e:
+        logger.error(f"Error listing stale branches: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 清理保留期已过的分支数据
+@app.route('/api/v1/coverage/retention/prune', methods=['POST'])
+def prune_stale_branches():
+    """清理保留期已过的失效分支数据（由定时任务调用）"""
+    try:
+        result = retention_manager.prune_expired_branches()
+        return jsonify({'success': True, 'data': result}), 200
+    except Exception as e:
+        logger.error(f"Error pruning stale branches: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 获取各仓库基准分支的覆盖率新鲜度
+@app.route('/api/v1/coverage/freshness', methods=['GET'])
+def get_coverage_freshness():
+    """
+    获取全部仓库基准分支覆盖率的新鲜度与SLO状态
+    （废弃的CI接入会以stale/never状态暴露，详见freshness_manager）
+    """
+    try:
+        result = freshness_manager.get_freshness_overview()
+        return jsonify(result), 200
+    except Exception as e:
+        logger.error(f"Error getting coverage freshness: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 执行一轮新鲜度检查
+@app.route('/api/v1/coverage/freshness/check', methods=['POST'])
+def run_coverage_freshness_check():
+    """执行新鲜度检查并记录心跳/告警（由定时任务调用）"""
+    try:
+        result = freshness_manager.run_freshness_check()
+        return jsonify({'success': True, 'data': result}), 200
+    except Exception as e:
+        logger.error(f"Error running freshness check: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 创建门禁豁免
+@app.route('/api/v1/coverage/gate-exceptions', methods=['POST'])
+@apikey_manager.require_scope(apikey_manager.SCOPE_ADMIN_CONFIG)
+def create_gate_exception():
+    """
+    创建带截止日期的门禁豁免（详见exception_manager）
+
+    请求体:
+        repo_id: 仓库唯一标识（必填）
+        path_pattern: 路径模式（必填，目录前缀以/结尾或fnmatch模式）
+        reason: 豁免理由（必填）
+        expires_at: 截止时间（毫秒时间戳，必填且必须在未来）
+        created_by: 创建人（可选）
+    """
+    try:
+        data = request.get_json() or {}
+        result = exception_manager.create_exception(
+            repo_id=data.get('repo_id'),
+            path_pattern=data.get('path_pattern'),
+            reason=data.get('reason'),
+            expires_at=data.get('expires_at'),
+            created_by=data.get('created_by')
+        )
+        return jsonify({'success': True, 'data': result}), 201
+    except ValueError as e:
+        return jsonify({'error': str(e)}), 400
+    except Exception as e:
+        logger.error(f"Error creating gate exception: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 获取门禁豁免列表
+@app.route('/api/v1/coverage/gate-exceptions', methods=['GET'])
+def list_gate_exceptions():
+    """
+    获取门禁豁免列表
+
+    查询参数:
+        repo_id: 仓库唯一标识（可选）
+        include_expired: 是否包含已到期的（默认false）
+    """
+    try:
+        result = exception_manager.list_exceptions(
+            repo_id=request.args.get('repo_id'),
+            include_expired=request.args.get('include_expired') == 'true'
+        )
+        return jsonify({'data': result, 'total': len(result)}), 200
+    except Exception as e:
+        logger.error(f"Error listing gate exceptions: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 获取即将到期的门禁豁免
+@app.route('/api/v1/coverage/gate-exceptions/expiring', methods=['GET'])
+def list_expiring_gate_exceptions():
+    """
+    获取即将到期的门禁豁免（默认提前14天，摘要/提醒用）
+
+    查询参数:
+        days: 提前天数（可选）
+    """
+    try:
+        days = request.args.get('days')
+        result = exception_manager.get_expiring_exceptions(
+            days=int(days) if days else None
+        )
+        return jsonify({'data': result, 'total': len(result)}), 200
+    except Exception as e:
+        logger.error(f"Error listing expiring gate exceptions: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 执行一轮豁免到期检查
+@app.route('/api/v1/coverage/gate-exceptions/check', methods=['POST'])
+def run_gate_exception_check():
+    """执行豁免到期检查并记录心跳/告警（由定时任务调用）"""
+    try:
+        result = exception_manager.run_expiry_check()
+        return jsonify({'success': True, 'data': result}), 200
+    except Exception as e:
+        logger.error(f"Error running gate exception check: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 删除门禁豁免
+@app.route('/api/v1/coverage/gate-exceptions/<int:exception_id>', methods=['DELETE'])
+@apikey_manager.require_scope(apikey_manager.SCOPE_ADMIN_CONFIG)
+def delete_gate_exception(exception_id):
+    """删除门禁豁免"""
+    try:
+        if not exception_manager.delete_exception(exception_id):
+            return jsonify({'error': 'Gate exception not found'}), 404
+        return jsonify({'success': True}), 200
+    except Exception as e:
+        logger.error(f"Error deleting gate exception: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 执行一轮测试缺口扫描
+@app.route('/api/v1/coverage/test-gaps/scan', methods=['POST'])
+@apikey_manager.require_scope(apikey_manager.SCOPE_ADMIN_CONFIG)
+def run_test_gap_scan():
+    """
+    扫描近期有改动且覆盖率低于底线的文件并自动建单/关单
+    （会写GitLab，默认由TEST_GAP_ENABLED关闭，详见testgap_manager）
+    """
+    try:
+        result = testgap_manager.run_test_gap_scan()
+        return jsonify({'success': True, 'data': result}), 200
+    except Exception as e:
+        logger.error(f"Error running test gap scan: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 获取测试缺口建单记录
+@app.route('/api/v1/coverage/test-gaps', methods=['GET'])
+def list_test_gap_issues():
+    """
+    获取测试缺口建单记录
+
+    查询参数:
+        repo_id: 仓库唯一标识（可选）
+        status: 状态过滤（open/closed，可选）
+    """
+    try:
+        result = testgap_manager.list_test_gap_issues(
+            repo_id=request.args.get('repo_id'),
+            status=request.args.get('status')
+        )
+        return jsonify(result), 200
+    except Exception as e:
+        logger.error(f"Error listing test gap issues: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 获取报告处理耗时最慢的记录（管理端）
+@app.route('/api/v1/admin/ingest/slow-reports', methods=['GET'])
+@apikey_manager.require_scope(apikey_manager.SCOPE_ADMIN_CONFIG)
+def get_ingest_slow_reports():
+    """
+    获取某天处理最慢的报告和仓库（按阶段耗时，详见profiling_manager）
+
+    查询参数:
+        date: 日期（YYYY-MM-DD，默认今天）
+        limit: 返回条数上限（默认10）
+    """
+    try:
+        result = profiling_manager.get_slow_reports(
+            date=request.args.get('date'),
+            limit=int(request.args.get('limit', 10))
+        )
+        return jsonify(result), 200
+    except ValueError as e:
+        return jsonify({'error': str(e)}), 400
+    except Exception as e:
+        logger.error(f"Error getting slow ingest reports: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 降采样不活跃feature分支的行级数据
+@app.route('/api/v1/coverage/retention/downsample', methods=['POST'])
+def downsample_feature_branches():
+    """
+    删除长期不活跃feature分支的行级数据、只留文件级摘要
+    （受保护分支不受影响，由定时任务调用，详见retention_manager）
+    """
+    try:
+        result = retention_manager.downsample_feature_branches()
+        return jsonify({'success': True, 'data': result}), 200
+    except Exception as e:
+        logger.error(f"Error downsampling feature branches: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 导出覆盖率模型为 protobuf
+@app.route('/api/v1/coverage/export/protobuf', methods=['GET'])
+def export_coverage_protobuf():
+    """
+    导出仓库分支的归一化覆盖率模型（protobuf 二进制）
+    消息定义见 proto/coverage.proto（RepoCoverage）
+
+    查询参数:
+        repo_id: 仓库唯一标识（必填）
+        branch: 分支名（必填）
+    """
+    try:
+        repo_id = request.args.get('repo_id')
+        branch = request.args.get('branch')
+        if not repo_id or not branch:
+            return jsonify({'error': 'repo_id and branch are required'}), 400
+
+        payload = proto_export.export_repo_coverage(repo_id, branch)
+        if payload is None:
+            return jsonify({'error': f'Config not found for repo: {repo_id}'}), 404
+
+        return Response(
+            payload,
+            mimetype='application/x-protobuf',
+            headers={
+                'X-Proto-Message': 'orbit.coverage.v1.RepoCoverage'
+            }
+        )
+    except Exception as e:
+        logger.error(f"Error exporting coverage protobuf: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# ==================== API Key Management APIs ====================
+
+# 创建API密钥
+@app.route('/api/v1/admin/api-keys', methods=['POST'])
+@apikey_manager.require_scope(apikey_manager.SCOPE_ADMIN_CONFIG)
+def create_api_key():
+    """
+    创建API密钥
+
+    请求体:
+        {
+            "name": "ci-uploader",
+            "scopes": ["read:coverage", "write:reports"],
+            "expires_at": 0
+        }
+
+    注意: 明文密钥仅在创建时返回一次
+    """
+    try:
+        data = request.get_json()
+        if not data:
+            return jsonify({'error': 'Invalid request body'}), 400
+
+        name = data.get('name', '')
+        scopes = data.get('scopes', [])
+        expires_at = data.get('expires_at', 0)
+
+        if not name:
+            return jsonify({'error': 'Missing name'}), 400
+        if not scopes or not isinstance(scopes, list):
+            return jsonify({'error': 'Missing scopes'}), 400
+
+        result = apikey_manager.create_api_key(name, scopes, expires_at=expires_at)
+        return jsonify({
+            'success': True,
+            'data': result
+        }), 201
+    except ValueError as e:
+        return jsonify({'error': str(e)}), 400
+    except Exception as e:
+        logger.error(f"Error creating API key: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 获取API密钥列表
+@app.route('/api/v1/admin/api-keys', methods=['GET'])
+@apikey_manager.require_scope(apikey_manager.SCOPE_ADMIN_CONFIG)
+def list_api_keys():
+    """获取所有API密钥（不含密钥明文）"""
+    try:
+        keys = apikey_manager.list_api_keys()
+        return jsonify({
+            'data': keys,
+            'total': len(keys)
+        }), 200
+    except Exception as e:
+        logger.error(f"Error listing API keys: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 吊销API密钥
+@app.route('/api/v1/admin/api-keys/<int:key_id>', methods=['DELETE'])
+@apikey_manager.require_scope(apikey_manager.SCOPE_ADMIN_CONFIG)
+def revoke_api_key(key_id):
+    """吊销API密钥"""
+    try:
+        apikey_manager.revoke_api_key(key_id)
+        return jsonify({
+            'success': True,
+            'message': 'API key revoked'
+        }), 200
+    except RuntimeError as e:
+        return jsonify({'error': str(e)}), 404
+    except Exception as e:
+        logger.error(f"Error revoking API key: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# ==================== Config Management APIs ====================
+
+# 获取所有仓库配置列表
+@app.route('/api/v1/coverage/configs', methods=['GET'])
+def get_all_configs():
+    """
+    获取所有仓库配置列表
+    
+    查询参数:
+        repo_name: 仓库名称（可选，模糊搜索）
+        repo_type: 仓库类型（可选，1=go, 2=python, 3=java）
+    """
+    try:
+        repo_name = request.args.get('repo_name')
+        repo_type = request.args.get('repo_type')
+        
+        # 转换 repo_type 为整数（如果提供）
+        repo_type_int = None
+        if repo_type is not None:
+            try:
+                repo_type_int = int(repo_type)
+            except ValueError:
+                return jsonify({'error': 'Invalid repo_type, must be an integer'}), 400
+        
+        result = get_all_configs_logic(repo_name=repo_name, repo_type=repo_type_int)
+        return jsonify(result), 200
+    except Exception as e:
+        logger.error(f"Error getting configs: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 获取单个仓库配置
+@app.route('/api/v1/coverage/configs/<repo_id>', methods=['GET'])
+def get_config_by_repo_id(repo_id):
+    """
+    获取单个仓库配置
+    
+    参数:
+        repo_id: 仓库唯一标识
+    """
+    try:
+        result = get_config_by_id_logic(repo_id)
+        if not result:
+            return jsonify({'error': 'Config not found'}), 404
+        return jsonify(result), 200
+    except Exception as e:
+        logger.error(f"Error getting config: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 创建仓库配置
+@app.route('/api/v1/coverage/configs', methods=['POST'])
+@apikey_manager.require_scope(apikey_manager.SCOPE_ADMIN_CONFIG)
+def create_config():
+    """
+    创建仓库配置
+    
+    请求体:
+        {
+            "repo_url": "https://github.com/fujifei/tuna.git",
+            "repo_id": "xxxxx",
+            "repo_type": 1,
+            "base_branch": "master",
+            "exclude_dirs": "cmd/;config/",
+            "exclude_files": "*._test.go;active_test.go"
+        }
+    
+    注意: 
+        - repo_name 会自动从 repo_url 中提取
+        - repo_type: 1=go语言, 2=python语言, 3=java语言（默认1）
+    """
+    try:
+        data = request.get_json()
+        if not data:
+            return jsonify({'error': 'Invalid request body'}), 400
+        
+        repo_url = data.get('repo_url', '')
+        repo_id = data.get('repo_id', '')
+        repo_type = data.get('repo_type', 1)  # 默认值为1（go语言）
+        base_branch = data.get('base_branch', 'master')
+        exclude_dirs = data.get('exclude_dirs', '')
+        exclude_files = data.get('exclude_files', '')
+        gate_threshold = data.get('gate_threshold', 0)
+        criticality_weights = data.get('criticality_weights', '')
+
+        # 验证 repo_type
+        if repo_type not in [1, 2, 3]:
+            return jsonify({'error': 'Invalid repo_type, must be 1 (go), 2 (python), or 3 (java)'}), 400
+
+        result = create_config_logic(
+            repo_url=repo_url,
+            repo_id=repo_id,
+            base_branch=base_branch,
+            exclude_dirs=exclude_dirs,
+            exclude_files=exclude_files,
+            repo_type=repo_type,
+            gate_threshold=gate_threshold,
+            criticality_weights=criticality_weights
+        )
+        
+        return jsonify({
+            'success': True,
+            'data': result
+        }), 201
+    except ValueError as e:
+        return jsonify({'error': str(e)}), 400
+    except RuntimeError as e:
+        return jsonify({'error': str(e)}), 400
+    except Exception as e:
+        logger.error(f"Error creating config: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 更新仓库配置
+@app.route('/api/v1/coverage/configs/<repo_id>', methods=['PUT'])
+@apikey_manager.require_scope(apikey_manager.SCOPE_ADMIN_CONFIG)
+def update_config(repo_id):
+    """
+    更新仓库配置
+    
+    参数:
+        repo_id: 仓库唯一标识
+    
+    请求体:
+        {
+            "base_branch": "master",
+            "exclude_dirs": "cmd/;config/",
+            "exclude_files": "*._test.go;active_test.go"
+        }
+    
+    注意: repo_url 和 repo_name 不可修改
+    """
+    try:
+        data = request.get_json()
+        if not data:
+            return jsonify({'error': 'Invalid request body'}), 400
+        
+        base_branch = data.get('base_branch')
+        exclude_dirs = data.get('exclude_dirs')
+        exclude_files = data.get('exclude_files')
+        gate_threshold = data.get('gate_threshold')
+        criticality_weights = data.get('criticality_weights')
+
+        result = update_config_logic(
+            repo_id=repo_id,
+            base_branch=base_branch,
+            exclude_dirs=exclude_dirs,
+            exclude_files=exclude_files,
+            gate_threshold=gate_threshold,
+            criticality_weights=criticality_weights
+        )
+        
+        return jsonify({
+            'success': True,
+            'data': result
+        }), 200
+    except RuntimeError as e:
+        return jsonify({'error': str(e)}), 404
+    except Exception as e:
+        logger.error(f"Error updating config: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 删除仓库配置
+@app.route('/api/v1/coverage/configs/<repo_id>', methods=['DELETE'])
+@apikey_manager.require_scope(apikey_manager.SCOPE_ADMIN_CONFIG)
+def delete_config(repo_id):
+    """
+    删除仓库配置
+    
+    参数:
+        repo_id: 仓库唯一标识
+    """
+    try:
+        delete_config_logic(repo_id)
+        return jsonify({
+            'success': True,
+            'message': 'Config deleted successfully'
+        }), 200
+    except RuntimeError as e:
+        return jsonify({'error': str(e)}), 404
+    except Exception as e:
+        logger.error(f"Error deleting config: {e}")
+        return jsonify({'error': str(e)}), 500
+
+
+# 根据仓库URL获取RepoID
+@app.route('/api/v1/coverage/configs/repo-id', methods=['POST'])
 def get_repo_id():
     """
     根据仓库URL获取RepoID
@@ -785,6 +2848,9 @@ def get_repo_id():
 
 if __name__ == '__main__':
     init_db()
-    
+
+    # PREFLIGHT_ON_STARTUP开启时先做依赖检查，失败fail fast
+    preflight_manager.run_startup_preflight()
+
     logger.info("Server starting on :8826")
     app.run(host='0.0.0.0', port=8826, debug=False)
@@ -15,6 +15,10 @@ import pika
 
 from models import init_db
 from manager.manager import CoverageReportMessage, process_coverage_report
+from manager.mutation_manager import process_mutation_report
+from manager.testresult_manager import process_test_result_report
+from manager.status_manager import record_heartbeat
+from manager.deadline import DeadlineExceeded, start_deadline
 
 # 配置日志
 logging.basicConfig(
@@ -44,6 +48,111 @@ RABBITMQ_USER = os.getenv('RABBITMQ_USER', 'coverage')
 RABBITMQ_PASSWORD = os.getenv('RABBITMQ_PASSWORD', 'coverage123')
 RABBITMQ_VHOST = os.getenv('RABBITMQ_VHOST', '/')
 RABBITMQ_URL = os.getenv('RABBITMQ_URL', f"amqp://{RABBITMQ_USER}:{RABBITMQ_PASSWORD}@{RABBITMQ_HOST}:{RABBITMQ_PORT}{RABBITMQ_VHOST}")
+# RabbitMQ集群：逗号分隔的多个AMQP地址，按顺序尝试连接（failover）
+# 设置后优先于RABBITMQ_URL生效
+RABBITMQ_URLS = os.getenv('RABBITMQ_URLS', '')
+
+# 队列拓扑命名（多环境共用一个broker集群时按环境区分，需与publisher一致）
+COVERAGE_EXCHANGE = os.getenv('COVERAGE_EXCHANGE', 'coverage_exchange')
+COVERAGE_QUEUE = os.getenv('COVERAGE_QUEUE', 'coverage_queue')
+COVERAGE_ROUTING_KEY = os.getenv('COVERAGE_ROUTING_KEY', 'coverage.report')
+# 队列绑定键默认与发布路由键一致，需要通配订阅时可单独设置
+COVERAGE_BINDING_KEY = os.getenv('COVERAGE_BINDING_KEY', COVERAGE_ROUTING_KEY)
+
+# 队列拓扑配置（由consumer启动时幂等声明）
+# 注意：RabbitMQ不允许修改已存在队列的参数，变更拓扑需先删除旧队列或换队列名
+QUEUE_TYPE = os.getenv('QUEUE_TYPE', 'classic')  # classic/quorum
+QUEUE_MODE = os.getenv('QUEUE_MODE', '')  # lazy（仅classic队列支持）
+QUEUE_MAX_LENGTH = int(os.getenv('QUEUE_MAX_LENGTH', '0'))  # 0=不限制
+QUEUE_OVERFLOW = os.getenv('QUEUE_OVERFLOW', '')  # drop-head/reject-publish/reject-publish-dlx
+ALTERNATE_EXCHANGE = os.getenv('ALTERNATE_EXCHANGE', '')  # 无法路由消息的兜底交换机
+
+
+def build_queue_arguments() -> Dict:
+    """根据环境变量构建队列声明参数"""
+    arguments = {}
+    if QUEUE_TYPE and QUEUE_TYPE != 'classic':
+        arguments['x-queue-type'] = QUEUE_TYPE
+    if QUEUE_MODE:
+        if QUEUE_TYPE == 'classic':
+            arguments['x-queue-mode'] = QUEUE_MODE
+        else:
+            # quorum队列没有lazy模式（本身就是磁盘优先）
+            logger.warning(f"QUEUE_MODE={QUEUE_MODE} ignored for {QUEUE_TYPE} queue")
+    if QUEUE_MAX_LENGTH > 0:
+        arguments['x-max-length'] = QUEUE_MAX_LENGTH
+        if QUEUE_OVERFLOW:
+            arguments['x-overflow'] = QUEUE_OVERFLOW
+    return arguments
+
+
+def get_rabbitmq_urls() -> list:
+    """返回按顺序尝试的AMQP地址列表"""
+    if RABBITMQ_URLS:
+        return [u.strip() for u in RABBITMQ_URLS.split(',') if u.strip()]
+    return [RABBITMQ_URL]
+
+
+# 各消息类型的已知字段（用于发现publisher侧的schema漂移）
+KNOWN_MESSAGE_FIELDS = {
+    'coverage': {
+        'type', 'repo', 'repo_id', 'branch', 'commit', 'ci',
+        'coverage', 'coverages', 'scope', 'metadata', 'timestamp'
+    },
+    'mutation': {
+        'type', 'repo', 'repo_id', 'branch', 'commit', 'mutation', 'timestamp'
+    },
+    'test_results': {
+        'type', 'repo', 'repo_id', 'branch', 'commit', 'results', 'timestamp'
+    },
+}
+
+# 各消息类型必须携带的字段
+REQUIRED_MESSAGE_FIELDS = {
+    'coverage': {'repo_id', 'branch', 'commit'},
+    'mutation': {'repo_id', 'branch'},
+    'test_results': {'repo_id', 'branch'},
+}
+
+# schema问题计数（unknown_field:<name> / missing_field:<name> -> 次数）
+# 周期性输出汇总，便于在日志里发现老SDK或写错字段名的publisher
+schema_metrics: Dict[str, int] = {}
+SCHEMA_METRICS_LOG_INTERVAL = 100
+_schema_checked_count = 0
+
+
+def check_message_schema(data: Dict, message_type: str) -> None:
+    """
+    对消息做宽松的schema检查：未知字段和缺失字段只告警计数，不拒绝消息
+    publisher漂移（老SDK、字段名拼错）靠这里先暴露出来
+    """
+    global _schema_checked_count
+
+    known = KNOWN_MESSAGE_FIELDS.get(message_type)
+    required = REQUIRED_MESSAGE_FIELDS.get(message_type, set())
+    if known is None:
+        return
+
+    for field in data:
+        if field not in known:
+            key = f'unknown_field:{field}'
+            schema_metrics[key] = schema_metrics.get(key, 0) + 1
+            logger.warning(
+                f"[schema检查] 消息包含未知字段 '{field}' (type={message_type}), "
+                "可能是publisher侧字段名拼错或版本不一致"
+            )
+
+    for field in required:
+        if not data.get(field):
+            key = f'missing_field:{field}'
+            schema_metrics[key] = schema_metrics.get(key, 0) + 1
+            logger.warning(
+                f"[schema检查] 消息缺失必需字段 '{field}' (type={message_type})"
+            )
+
+    _schema_checked_count += 1
+    if _schema_checked_count % SCHEMA_METRICS_LOG_INTERVAL == 0 and schema_metrics:
+        logger.info(f"[schema检查] 累计schema问题统计: {schema_metrics}")
 
 
 def get_retry_count(headers: Optional[Dict]) -> int:
@@ -65,60 +174,116 @@ def get_retry_count(headers: Optional[Dict]) -> int:
 
 
 def connect_rabbitmq() -> pika.BlockingConnection:
-    """连接RabbitMQ"""
+    """
+    连接RabbitMQ
+    配置了RABBITMQ_URLS时按顺序尝试各节点（集群failover）
+    parameters在每次连接时重新构建，保证主机名每次都重新解析DNS
+    （集群节点IP变化后重连能拿到新地址）
+    """
+    urls = get_rabbitmq_urls()
     try:
-        logger.info(f"Connecting to RabbitMQ at {RABBITMQ_HOST}:{RABBITMQ_PORT}")
-        parameters = pika.URLParameters(RABBITMQ_URL)
-        # 配置心跳超时为300秒（5分钟），避免长时间无消息时连接断开
-        parameters.heartbeat = 300
+        logger.info(f"Connecting to RabbitMQ ({len(urls)} endpoint(s))")
+        parameters = []
+        for url in urls:
+            p = pika.URLParameters(url)
+            # 配置心跳超时为300秒（5分钟），避免长时间无消息时连接断开
+            p.heartbeat = 300
+            parameters.append(p)
+        # pika支持传入parameters列表，按顺序尝试直到连接成功
         connection = pika.BlockingConnection(parameters)
-        logger.info(f"Successfully connected to RabbitMQ at {RABBITMQ_HOST}:{RABBITMQ_PORT}")
+        logger.info("Successfully connected to RabbitMQ")
         return connection
     except Exception as e:
-        logger.error(f"Failed to connect to RabbitMQ at {RABBITMQ_HOST}:{RABBITMQ_PORT}: {e}")
+        logger.error(f"Failed to connect to RabbitMQ (tried {len(urls)} endpoint(s)): {e}")
         raise
 
 
 def setup_queue(channel: pika.channel.Channel) -> None:
-    """设置队列"""
+    """设置队列（声明是幂等的，参数与已有队列一致时重复声明无副作用）"""
+    exchange_arguments = {}
+    if ALTERNATE_EXCHANGE:
+        # 先声明兜底交换机，路由失败的消息会进入这里而不是被丢弃
+        channel.exchange_declare(
+            exchange=ALTERNATE_EXCHANGE,
+            exchange_type='fanout',
+            durable=True
+        )
+        exchange_arguments['alternate-exchange'] = ALTERNATE_EXCHANGE
+
     # 声明交换机
     channel.exchange_declare(
-        exchange='coverage_exchange',
+        exchange=COVERAGE_EXCHANGE,
         exchange_type='topic',
-        durable=True
+        durable=True,
+        arguments=exchange_arguments or None
     )
-    
-    # 声明队列
+
+    # 声明队列（类型/lazy模式/长度上限由环境变量配置）
+    queue_arguments = build_queue_arguments()
+    if queue_arguments:
+        logger.info(f"Declaring {COVERAGE_QUEUE} with arguments: {queue_arguments}")
     channel.queue_declare(
-        queue='coverage_queue',
-        durable=True
+        queue=COVERAGE_QUEUE,
+        durable=True,
+        arguments=queue_arguments or None
     )
-    
+
     # 绑定队列到交换机
     channel.queue_bind(
-        exchange='coverage_exchange',
-        queue='coverage_queue',
-        routing_key='coverage.report'
+        exchange=COVERAGE_EXCHANGE,
+        queue=COVERAGE_QUEUE,
+        routing_key=COVERAGE_BINDING_KEY
     )
 
 
 def main():
     logger.info("Starting Coverage Consumer Service...")
-    
+
     # 初始化数据库
     init_db()
-    
+
+    # PREFLIGHT_ON_STARTUP开启时先做依赖检查，失败fail fast
+    # 队列此时可能还未声明（由下面的setup_queue负责），broker检查跳过
+    from manager import preflight_manager
+    preflight_manager.run_startup_preflight(include_broker=False)
+
+    # 滚动升级交接协议：启用时先获取队列租约再开始消费，
+    # 旧消费者存活时会等它排空在途消息后交接（见handover_manager）
+    from manager import handover_manager
+    lease_holder = None
+    if handover_manager.HANDOVER_ENABLED:
+        lease_holder = handover_manager.make_holder_id()
+        handover_manager.acquire_lease(COVERAGE_QUEUE, lease_holder)
+
     # 连接RabbitMQ
     connection = connect_rabbitmq()
     channel = connection.channel()
-    
+
     try:
         # 设置队列
         setup_queue(channel)
-        
+
         # 设置QoS，确保公平分发
         channel.basic_qos(prefetch_count=1)
-        
+
+        # 租约心跳：发现新消费者请求交接时停止拉取，
+        # start_consuming返回前会先处理完在途消息（prefetch=1）
+        if lease_holder:
+            def lease_tick():
+                try:
+                    if handover_manager.heartbeat(COVERAGE_QUEUE, lease_holder):
+                        logger.info(
+                            "Handover requested by new consumer, "
+                            "draining and stopping..."
+                        )
+                        channel.stop_consuming()
+                        return
+                except Exception as lease_err:
+                    logger.warning(f"Lease heartbeat failed: {lease_err}")
+                connection.call_later(handover_manager.POLL_SECONDS, lease_tick)
+
+            connection.call_later(handover_manager.POLL_SECONDS, lease_tick)
+
         logger.info("Waiting for coverage reports...")
         
         def callback(ch, method, properties, body):
@@ -137,6 +302,34 @@ def main():
             try:
                 # 解析消息
                 data = json.loads(body.decode('utf-8'))
+
+                # 按消息类型分发（默认coverage，保持向后兼容）
+                message_type = data.get('type', 'coverage')
+
+                # schema漂移检查（只告警计数，不影响消费）
+                check_message_schema(data, message_type)
+                if message_type == 'mutation':
+                    logger.info(
+                        f"[消息接收] 变异测试报告 - repo_id={data.get('repo_id')}, "
+                        f"branch={data.get('branch')}, commit={data.get('commit')}"
+                    )
+                    process_mutation_report(data)
+                    ch.basic_ack(delivery_tag=method.delivery_tag)
+                    logger.info("[消息消费成功] 变异测试报告处理完成")
+                    record_heartbeat('rabbitmq-consumer')
+                    return
+
+                if message_type == 'test_results':
+                    logger.info(
+                        f"[消息接收] 测试结果报告 - repo_id={data.get('repo_id')}, "
+                        f"branch={data.get('branch')}, commit={data.get('commit')}"
+                    )
+                    process_test_result_report(data)
+                    ch.basic_ack(delivery_tag=method.delivery_tag)
+                    logger.info("[消息消费成功] 测试结果报告处理完成")
+                    record_heartbeat('rabbitmq-consumer')
+                    return
+
                 report_msg = CoverageReportMessage(data)
                 
                 # 打印接收到的消息详细信息
@@ -154,8 +347,10 @@ def main():
                     ch.basic_nack(delivery_tag=method.delivery_tag, requeue=False)
                     return
                 
-                # 处理报告
-                process_coverage_report(report_msg)
+                # 处理报告（带处理预算，超预算的报告不会无限占住worker）
+                process_coverage_report(
+                    report_msg, processing_deadline=start_deadline()
+                )
                 
                 # 消息消费成功
                 logger.info("[消息消费成功] 覆盖率报告处理完成")
@@ -169,7 +364,8 @@ def main():
                 # 确认消息
                 ch.basic_ack(delivery_tag=method.delivery_tag)
                 logger.info("[消息消费成功] 消息已确认")
-                
+                record_heartbeat('rabbitmq-consumer')
+
             except json.JSONDecodeError as e:
                 # JSON解析失败
                 logger.error(f"[消息消费失败] JSON解析失败, 错误: {e}")
@@ -179,6 +375,18 @@ def main():
                 # JSON解析失败通常不应该重试，直接拒绝
                 ch.basic_nack(delivery_tag=method.delivery_tag, requeue=False)
                 
+            except DeadlineExceeded as e:
+                # 处理预算耗尽：重试大概率同样超时，直接丢弃并记录超时原因
+                logger.error(f"[消息消费失败] 处理超出预算: {e}")
+                if report_msg:
+                    logger.error(
+                        f"[消息消费失败] 仓库信息: repo={report_msg.repo}, "
+                        f"repo_id={report_msg.repo_id}, branch={report_msg.branch}, "
+                        f"commit={report_msg.commit}"
+                    )
+                record_heartbeat('rabbitmq-consumer', status='error', detail=str(e))
+                ch.basic_nack(delivery_tag=method.delivery_tag, requeue=False)
+
             except Exception as e:
                 # 处理失败
                 logger.error("[消息消费失败] 处理覆盖率报告失败")
@@ -192,7 +400,8 @@ def main():
                     logger.error("[消息消费失败] 仓库信息: N/A (消息解析失败)")
                 logger.error(f"[消息消费失败] 错误详情: {e}")
                 logger.error(f"[消息消费失败] 当前重试次数: {retry_count}/{MAX_RETRY_COUNT}")
-                
+                record_heartbeat('rabbitmq-consumer', status='error', detail=str(e))
+
                 # 检查重试次数
                 if retry_count >= MAX_RETRY_COUNT:
                     logger.error(
@@ -220,8 +429,8 @@ def main():
                 # 重新发布消息
                 try:
                     ch.basic_publish(
-                        exchange='coverage_exchange',
-                        routing_key='coverage.report',
+                        exchange=COVERAGE_EXCHANGE,
+                        routing_key=COVERAGE_ROUTING_KEY,
                         body=body,
                         properties=pika.BasicProperties(
                             content_type=properties.content_type,
@@ -238,7 +447,7 @@ def main():
         
         # 消费消息
         channel.basic_consume(
-            queue='coverage_queue',
+            queue=COVERAGE_QUEUE,
             on_message_callback=callback,
             auto_ack=False
         )
@@ -273,6 +482,10 @@ def main():
         except Exception as e:
             logger.warning(f"Error closing connection: {e}")
 
+        # 在途消息已排空，释放租约让等待中的新消费者接管
+        if lease_holder:
+            handover_manager.release_lease(COVERAGE_QUEUE, lease_holder)
+
 
 if __name__ == '__main__':
     main()
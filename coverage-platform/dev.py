@@ -0,0 +1,196 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+本地单机开发模式
+用SQLite跑完整的采集+API链路（与生产相同的代码路径），消息不走RabbitMQ
+而是进程内直接调用consumer的处理函数；同时监视本地coverage.out文件，
+变化时自动重新采集——开发者在本机就能有一个实时的覆盖率浏览器
+
+用法:
+    python dev.py [--repo /path/to/repo] [--coverage-file coverage.out] \\
+        [--db orbit-dev.db] [--port 8826] [--interval 2]
+
+    cd my-go-project && go test -coverprofile=coverage.out ./... 后
+    在浏览器访问 http://localhost:8826/api/v1/coverage/reports 即可
+"""
+
+import argparse
+import hashlib
+import logging
+import os
+import subprocess
+import sys
+import threading
+import time
+
+import version
+
+logging.basicConfig(
+    level=logging.INFO,
+    format='%(asctime)s - %(name)s - %(levelname)s - %(message)s'
+)
+logger = logging.getLogger(__name__)
+
+BASE_DIR = os.path.dirname(os.path.abspath(__file__))
+
+
+def parse_args():
+    parser = argparse.ArgumentParser(description='本地单机开发模式（SQLite）')
+    parser.add_argument('--version', action=version.VersionAction)
+    parser.add_argument('--repo', default=os.getcwd(),
+                        help='本地仓库路径（默认当前目录）')
+    parser.add_argument('--coverage-file', default='coverage.out',
+                        help='覆盖率文件路径，相对于仓库（默认coverage.out）')
+    parser.add_argument('--format', default='goc',
+                        help='覆盖率格式：goc/jaca/pyca（默认goc）')
+    parser.add_argument('--db', default='orbit-dev.db',
+                        help='SQLite数据库文件（默认orbit-dev.db）')
+    parser.add_argument('--port', type=int, default=8826,
+                        help='API监听端口（默认8826）')
+    parser.add_argument('--interval', type=float, default=2,
+                        help='文件监视轮询间隔秒数（默认2）')
+    return parser.parse_args()
+
+
+def git_output(repo_path, *args):
+    """在仓库目录执行git命令并返回stdout，失败返回 None"""
+    try:
+        result = subprocess.run(
+            ['git'] + list(args),
+            cwd=repo_path, capture_output=True, text=True, timeout=10
+        )
+        if result.returncode != 0:
+            return None
+        return result.stdout.strip()
+    except Exception:
+        return None
+
+
+def local_repo_id(repo_path):
+    """本地仓库的稳定标识（路径哈希，避免与线上repo_id冲突）"""
+    digest = hashlib.sha1(repo_path.encode('utf-8')).hexdigest()[:12]
+    return f'local-{digest}'
+
+
+def ensure_config(repo_id, repo_path, base_branch):
+    """为本地仓库建一条配置（增量覆盖率等功能依赖配置中的repo_url）"""
+    from models import CoverageConfig, get_db_session
+    db = get_db_session()
+    try:
+        config = db.query(CoverageConfig).filter(
+            CoverageConfig.repo_id == repo_id
+        ).first()
+        if config:
+            return
+        now = int(time.time() * 1000)
+        db.add(CoverageConfig(
+            repo_id=repo_id,
+            repo_name=os.path.basename(repo_path),
+            repo_url=repo_path,
+            base_branch=base_branch,
+            created_at=now,
+            updated_at=now
+        ))
+        db.commit()
+    finally:
+        db.close()
+
+
+def ingest(repo_id, repo_path, coverage_path, coverage_format):
+    """读取覆盖率文件并走生产同款的消费处理链路（进程内，不经broker）"""
+    from manager.manager import CoverageReportMessage, process_coverage_report
+
+    with open(coverage_path, 'r', encoding='utf-8') as f:
+        raw = f.read()
+
+    branch = git_output(repo_path, 'rev-parse', '--abbrev-ref', 'HEAD') or 'master'
+    commit = git_output(repo_path, 'rev-parse', 'HEAD')
+    if not commit:
+        logger.error(f"Not a git repository (or no commits): {repo_path}")
+        return False
+
+    message = CoverageReportMessage({
+        'repo': repo_path,
+        'repo_id': repo_id,
+        'branch': branch,
+        'commit': commit,
+        'ci': {'provider': 'local', 'pipeline_id': '0', 'job_id': 'dev'},
+        'coverage': {'format': coverage_format, 'raw': raw},
+        'timestamp': int(time.time())
+    })
+    success = process_coverage_report(message)
+    if success:
+        logger.info(f"Ingested {coverage_path} ({branch}@{commit[:8]})")
+    else:
+        logger.error(f"Failed to ingest {coverage_path}")
+    return success
+
+
+def watch_loop(repo_id, repo_path, coverage_path, coverage_format, interval):
+    """轮询覆盖率文件的mtime，变化时重新采集"""
+    last_mtime = None
+    logger.info(f"Watching {coverage_path} for changes...")
+    while True:
+        try:
+            mtime = os.path.getmtime(coverage_path)
+        except OSError:
+            mtime = None
+
+        if mtime is not None and mtime != last_mtime:
+            if last_mtime is None:
+                logger.info(f"Found {coverage_path}")
+            else:
+                logger.info(f"{coverage_path} changed, re-ingesting...")
+            try:
+                ingest(repo_id, repo_path, coverage_path, coverage_format)
+            except Exception as e:
+                logger.error(f"Ingest failed: {e}")
+            last_mtime = mtime
+
+        time.sleep(interval)
+
+
+def main():
+    args = parse_args()
+
+    repo_path = os.path.abspath(args.repo)
+    coverage_path = args.coverage_file
+    if not os.path.isabs(coverage_path):
+        coverage_path = os.path.join(repo_path, coverage_path)
+
+    # 必须在导入models前指定SQLite DSN
+    db_path = os.path.abspath(args.db)
+    os.environ['DATABASE_URL'] = f'sqlite:///{db_path}'
+    sys.path.insert(0, BASE_DIR)
+    sys.path.insert(0, os.path.join(BASE_DIR, 'coverage-api'))
+
+    from models import init_db
+    init_db()
+    logger.info(f"Using SQLite database: {db_path}")
+
+    repo_id = local_repo_id(repo_path)
+    base_branch = git_output(repo_path, 'rev-parse', '--abbrev-ref', 'HEAD') or 'master'
+    ensure_config(repo_id, repo_path, base_branch)
+    logger.info(f"Local repo: {repo_path} (repo_id={repo_id})")
+
+    # API服务放后台线程，文件监视占主线程（方便Ctrl+C退出）
+    from main import app
+    api_thread = threading.Thread(
+        target=lambda: app.run(
+            host='127.0.0.1', port=args.port, debug=False, use_reloader=False
+        ),
+        daemon=True
+    )
+    api_thread.start()
+    logger.info(f"API listening on http://127.0.0.1:{args.port}")
+
+    try:
+        watch_loop(repo_id, repo_path, coverage_path, args.format, args.interval)
+    except KeyboardInterrupt:
+        logger.info("Stopping dev mode...")
+        return 0
+
+
+if __name__ == '__main__':
+    sys.exit(main())
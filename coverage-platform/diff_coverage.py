@@ -12,6 +12,8 @@ from typing import Dict, List, Optional, Set, Tuple
 from dataclasses import dataclass
 from enum import Enum
 
+from manager import precision
+
 logger = logging.getLogger(__name__)
 
 
@@ -459,8 +461,10 @@ def generate_diff_coverage_summary(file_results: List[DiffCoverageFile]) -> Diff
     incremental_coverage_rate = 0.0
     total_coverable_lines = new_covered_lines + new_uncovered_lines
     if total_coverable_lines > 0:
-        incremental_coverage_rate = (new_covered_lines / total_coverable_lines) * 100
-    
+        incremental_coverage_rate = precision.apply_policy(
+            (new_covered_lines / total_coverable_lines) * 100
+        )
+
     return DiffCoverageSummary(
         total_files=total_files,
         total_new_lines=total_new_lines,
@@ -3,9 +3,14 @@
 
 import hashlib
 import json
+import os
 import time
 import pika
 
+# 队列拓扑命名（需与目标环境的consumer一致）
+COVERAGE_EXCHANGE = os.getenv('COVERAGE_EXCHANGE', 'coverage_exchange')
+COVERAGE_ROUTING_KEY = os.getenv('COVERAGE_ROUTING_KEY', 'coverage.report')
+
 # CoverageReportMessage 覆盖率报告消息结构
 def create_coverage_report_message(repo, branch, commit, ci_provider, ci_pipeline_id, ci_job_id, coverage_format, coverage_raw):
     """创建覆盖率报告消息"""
@@ -45,7 +50,7 @@ def main():
     # 声明交换机（如果不存在）
     try:
         channel.exchange_declare(
-            exchange='coverage_exchange',
+            exchange=COVERAGE_EXCHANGE,
             exchange_type='topic',
             durable=True
         )
@@ -78,8 +83,8 @@ github.com/xxx/tuna/file2.go:20.1,25.3 2"""
     # 发布消息（设置持久化）
     try:
         channel.basic_publish(
-            exchange='coverage_exchange',
-            routing_key='coverage.report',
+            exchange=COVERAGE_EXCHANGE,
+            routing_key=COVERAGE_ROUTING_KEY,
             body=body,
             properties=pika.BasicProperties(
                 content_type='application/json',
@@ -0,0 +1,123 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+趋势标注业务逻辑管理层
+接收外部事件webhook（发布、故障、测试基础设施中断等）并落库，
+查询趋势时按时间窗口叠加返回，覆盖率突降可以直接对上已知事件
+而不用盲查
+"""
+
+import logging
+import time
+from typing import Dict, List, Optional
+
+from sqlalchemy import or_
+
+from models import TrendAnnotation, get_db_session
+
+logger = logging.getLogger(__name__)
+
+# 支持的事件类型
+EVENT_DEPLOY = 'deploy'
+EVENT_INCIDENT = 'incident'
+EVENT_OUTAGE = 'outage'
+SUPPORTED_EVENT_TYPES = [EVENT_DEPLOY, EVENT_INCIDENT, EVENT_OUTAGE]
+
+
+def record_event(event_type: str, title: str, repo_id: Optional[str] = None,
+                 detail: Optional[str] = None, source: Optional[str] = None,
+                 occurred_at: Optional[int] = None) -> Dict:
+    """
+    记录一条外部事件
+
+    参数:
+        event_type: 事件类型（deploy/incident/outage）
+        title: 事件标题
+        repo_id: 仓库唯一标识（可选，为空表示全局事件）
+        detail: 事件详情（可选）
+        source: 来源系统（可选）
+        occurred_at: 事件发生时间（毫秒，可选，默认当前时间）
+
+    返回:
+        Dict: 事件记录
+
+    异常:
+        ValueError: 事件类型不支持或标题为空
+    """
+    if event_type not in SUPPORTED_EVENT_TYPES:
+        raise ValueError(
+            f'unsupported event_type: {event_type}, '
+            f'must be one of: {", ".join(SUPPORTED_EVENT_TYPES)}'
+        )
+    if not title or not title.strip():
+        raise ValueError('title is required')
+
+    now = int(time.time() * 1000)
+    db = get_db_session()
+    try:
+        annotation = TrendAnnotation(
+            repo_id=repo_id,
+            event_type=event_type,
+            title=title.strip(),
+            detail=detail,
+            source=source,
+            occurred_at=int(occurred_at) if occurred_at else now,
+            created_at=now
+        )
+        db.add(annotation)
+        db.commit()
+        logger.info(
+            f"Recorded {event_type} event: {title} "
+            f"(repo_id={repo_id or 'global'})"
+        )
+        return annotation.to_dict()
+    except Exception:
+        db.rollback()
+        raise
+    finally:
+        db.close()
+
+
+def get_annotations_in_window(repo_id: str, window_start: int,
+                              window_end: int) -> List[Dict]:
+    """
+    获取时间窗口内与仓库相关的标注（仓库自身事件+全局事件）
+
+    按occurred_at升序返回，用于叠加在趋势图上
+    """
+    db = get_db_session()
+    try:
+        annotations = db.query(TrendAnnotation).filter(
+            or_(
+                TrendAnnotation.repo_id == repo_id,
+                TrendAnnotation.repo_id.is_(None)
+            ),
+            TrendAnnotation.occurred_at >= window_start,
+            TrendAnnotation.occurred_at <= window_end
+        ).order_by(TrendAnnotation.occurred_at.asc()).all()
+        return [a.to_dict() for a in annotations]
+    finally:
+        db.close()
+
+
+def list_annotations(repo_id: Optional[str] = None,
+                     event_type: Optional[str] = None,
+                     limit: int = 100) -> List[Dict]:
+    """列出事件标注（可按仓库、事件类型过滤），按发生时间倒序"""
+    db = get_db_session()
+    try:
+        query = db.query(TrendAnnotation)
+        if repo_id:
+            query = query.filter(or_(
+                TrendAnnotation.repo_id == repo_id,
+                TrendAnnotation.repo_id.is_(None)
+            ))
+        if event_type:
+            query = query.filter(TrendAnnotation.event_type == event_type)
+        annotations = query.order_by(
+            TrendAnnotation.occurred_at.desc()
+        ).limit(limit).all()
+        return [a.to_dict() for a in annotations]
+    finally:
+        db.close()
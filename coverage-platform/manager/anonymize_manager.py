@@ -0,0 +1,136 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+匿名化导出业务逻辑管理层
+把平台级覆盖率数据集脱敏后导出给外部研究者/供应商：不含任何文件内容，
+仓库、分支、commit、文件路径全部替换为加盐哈希标识，目录层级深度保留
+（逐段哈希）但目录名不可还原，覆盖率百分比和语句数原样保留
+
+环境变量:
+    ANONYMIZE_SALT: 哈希盐值；不设置时每次导出随机生成，
+        此时不同导出之间的标识不可关联（需要跨导出对齐时必须固定盐值）
+"""
+
+import hashlib
+import hmac
+import logging
+import os
+import secrets
+import time
+from typing import Dict, List, Optional
+
+from models import CoverageSnapshot, CoverageFileSnapshot, get_db_session
+
+logger = logging.getLogger(__name__)
+
+# 每个仓库最多导出的趋势点数（控制导出体积）
+EXPORT_TREND_LIMIT = int(os.environ.get('ANONYMIZE_TREND_LIMIT', '200'))
+
+
+def _hasher(salt: str):
+    """返回加盐哈希函数（HMAC-SHA256截断，16个十六进制字符够避撞）"""
+    def _hash(value: str) -> str:
+        return hmac.new(
+            salt.encode('utf-8'), value.encode('utf-8'), hashlib.sha256
+        ).hexdigest()[:16]
+    return _hash
+
+
+def _anonymize_path(file_path: str, hash_fn) -> str:
+    """逐段哈希文件路径：保留目录层级深度，不泄露目录/文件名和扩展名"""
+    segments = [s for s in file_path.split('/') if s]
+    return '/'.join(hash_fn(s) for s in segments)
+
+
+def build_anonymized_export(repo_id: Optional[str] = None) -> Dict:
+    """
+    构建匿名化导出数据集
+
+    参数:
+        repo_id: 仓库唯一标识（可选，不填导出全部仓库）
+
+    返回:
+        Dict: {
+            'generated_at': 毫秒时间戳,
+            'salted': 是否使用了固定盐值（False表示标识跨导出不可关联）,
+            'repos': [{
+                'repo': 哈希标识,
+                'branches': [{
+                    'branch': 哈希标识,
+                    'trend': [{'commit', 'total_statements',
+                               'covered_statements', 'coverage_rate',
+                               'created_at'}],
+                    'files': [{'file', 'total_statements',
+                               'covered_statements', 'coverage_rate'}]
+                }]
+            }]
+        }
+        files取该分支最新commit的文件级快照
+    """
+    salt = os.environ.get('ANONYMIZE_SALT')
+    salted = bool(salt)
+    if not salt:
+        salt = secrets.token_hex(16)
+    hash_fn = _hasher(salt)
+
+    db = get_db_session()
+    try:
+        query = db.query(CoverageSnapshot)
+        if repo_id:
+            query = query.filter(CoverageSnapshot.repo_id == repo_id)
+        snapshots = query.order_by(CoverageSnapshot.created_at.asc()).all()
+
+        # repo_id -> branch -> 快照列表（时间升序）
+        grouped = {}
+        for s in snapshots:
+            grouped.setdefault(s.repo_id, {}).setdefault(s.branch, []).append(s)
+
+        repos = []
+        for raw_repo_id, branches in grouped.items():
+            branch_entries = []
+            for raw_branch, branch_snapshots in branches.items():
+                trend = [{
+                    'commit': hash_fn(s.commit),
+                    'total_statements': s.total_statements,
+                    'covered_statements': s.covered_statements,
+                    'coverage_rate': s.coverage_rate / 100.0,
+                    'created_at': s.created_at
+                } for s in branch_snapshots[-EXPORT_TREND_LIMIT:]]
+
+                # 最新commit的文件级快照（不含任何内容，路径逐段哈希）
+                latest_commit = branch_snapshots[-1].commit
+                file_snapshots = db.query(CoverageFileSnapshot).filter(
+                    CoverageFileSnapshot.repo_id == raw_repo_id,
+                    CoverageFileSnapshot.branch == raw_branch,
+                    CoverageFileSnapshot.commit == latest_commit
+                ).all()
+                files = [{
+                    'file': _anonymize_path(f.file_path, hash_fn),
+                    'total_statements': f.total_statements,
+                    'covered_statements': f.covered_statements,
+                    'coverage_rate': f.coverage_rate / 100.0
+                } for f in file_snapshots]
+
+                branch_entries.append({
+                    'branch': hash_fn(raw_branch),
+                    'trend': trend,
+                    'files': files
+                })
+
+            repos.append({
+                'repo': hash_fn(raw_repo_id),
+                'branches': branch_entries
+            })
+
+        logger.info(
+            f"Built anonymized export: {len(repos)} repos "
+            f"(salted={salted})"
+        )
+        return {
+            'generated_at': int(time.time() * 1000),
+            'salted': salted,
+            'repos': repos
+        }
+    finally:
+        db.close()
@@ -0,0 +1,127 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+导出 API 覆盖分析模块
+针对 Go 仓库，列出没有任何覆盖的导出函数/方法，
+形成"对外 API 没有测试"的优先级清单
+
+函数边界复用 function_manager 的源码解析，
+导出判定按 Go 规则：首字母大写即导出
+"""
+
+import logging
+from typing import Dict, List, Optional
+
+from models import CoverageReport, CoverageFile, CoverageConfig, get_db_session
+from manager.manager import should_exclude_file
+from manager.diff_manager import get_coverage_blocks_for_file
+from manager.function_manager import (
+    get_file_content_at_commit, parse_function_spans, coverage_rate_for_span
+)
+from manager import link_manager
+
+logger = logging.getLogger(__name__)
+
+# Go 仓库的 repo_type（与 CoverageConfig.repo_type 一致）
+REPO_TYPE_GO = 1
+
+
+def _is_exported(name: str) -> bool:
+    """Go 导出判定：标识符首字母大写"""
+    return bool(name) and name[0].isupper()
+
+
+def get_untested_api_report(repo_id: str, branch: str) -> Optional[Dict]:
+    """
+    列出仓库分支上零覆盖的导出函数/方法
+
+    返回:
+        Optional[Dict]: {
+            'repo_id', 'branch', 'commit',
+            'total_exported',    # 导出函数总数（有可覆盖语句的）
+            'untested': [{'file', 'function', 'start_line', 'end_line',
+                          'statements', 'url'}]
+        url 是锚定到 commit 的托管平台深链接，直接跳到函数定义处
+        }
+        按 statements 降序排列（大函数优先补测试）
+        仓库非 Go 类型或无报告时返回 None
+    """
+    db = get_db_session()
+    try:
+        config = db.query(CoverageConfig).filter(
+            CoverageConfig.repo_id == repo_id
+        ).first()
+        if not config or config.repo_type != REPO_TYPE_GO:
+            logger.warning(f"Repo is not a Go repo: {repo_id}")
+            return None
+
+        report = db.query(CoverageReport).filter(
+            CoverageReport.repo_id == repo_id,
+            CoverageReport.branch == branch,
+            CoverageReport.status == 'completed'
+        ).order_by(CoverageReport.created_at.desc()).first()
+        if not report:
+            logger.warning(f"No completed report for {repo_id}/{branch}")
+            return None
+        # 仓库URL在配置表里（报告上没有存）
+        repo_url = config.repo_url
+        commit = report.commit
+
+        files = db.query(CoverageFile).filter(
+            CoverageFile.repo_id == repo_id,
+            CoverageFile.branch == branch
+        ).all()
+        file_paths = [f.file_path for f in files if f.file_path.endswith('.go')]
+        exclude_dirs = config.exclude_dirs
+        exclude_files = config.exclude_files
+    finally:
+        db.close()
+
+    total_exported = 0
+    untested = []
+    for file_path in file_paths:
+        if should_exclude_file(file_path, exclude_dirs, exclude_files):
+            continue
+
+        content = get_file_content_at_commit(repo_url, commit, file_path)
+        if content is None:
+            continue
+
+        blocks = get_coverage_blocks_for_file(
+            repo_id, branch, file_path, repo_url=repo_url, commit=commit
+        )
+
+        for span in parse_function_spans(file_path, content):
+            if not _is_exported(span['name']):
+                continue
+
+            rate = coverage_rate_for_span(
+                blocks, span['start_line'], span['end_line']
+            )
+            if rate is None:
+                # 区间内没有可覆盖语句（如纯声明），不计入
+                continue
+
+            total_exported += 1
+            if rate['covered_statements'] == 0:
+                untested.append({
+                    'file': file_path,
+                    'function': span['name'],
+                    'start_line': span['start_line'],
+                    'end_line': span['end_line'],
+                    'statements': rate['total_statements'],
+                    'url': link_manager.file_link(
+                        repo_url, commit, file_path,
+                        span['start_line'], span['end_line']
+                    )
+                })
+
+    untested.sort(key=lambda f: f['statements'], reverse=True)
+    return {
+        'repo_id': repo_id,
+        'branch': branch,
+        'commit': commit,
+        'total_exported': total_exported,
+        'untested': untested
+    }
@@ -0,0 +1,192 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+API密钥管理模块
+提供细粒度scope的API密钥创建、校验、吊销
+scope示例: read:coverage（读取覆盖率数据）、write:reports（写入报告）、admin:config（管理配置）
+默认不强制鉴权，设置环境变量 ORBIT_REQUIRE_API_KEY=1 后启用
+"""
+
+import hashlib
+import logging
+import os
+from functools import wraps
+from typing import Dict, List, Optional
+
+from manager import determinism
+
+from flask import jsonify, request
+
+from models import ApiKey, get_db_session
+
+logger = logging.getLogger(__name__)
+
+# 支持的scope列表
+SCOPE_READ_COVERAGE = 'read:coverage'
+SCOPE_WRITE_REPORTS = 'write:reports'
+SCOPE_ADMIN_CONFIG = 'admin:config'
+
+SUPPORTED_SCOPES = [SCOPE_READ_COVERAGE, SCOPE_WRITE_REPORTS, SCOPE_ADMIN_CONFIG]
+
+# 是否强制API密钥鉴权（默认关闭，保持向后兼容）
+REQUIRE_API_KEY = os.getenv('ORBIT_REQUIRE_API_KEY', '0') == '1'
+
+API_KEY_HEADER = 'X-API-Key'
+
+
+def _hash_key(key: str) -> str:
+    """计算密钥的SHA256哈希"""
+    return hashlib.sha256(key.encode('utf-8')).hexdigest()
+
+
+def create_api_key(name: str, scopes: List[str], expires_at: int = 0) -> Dict:
+    """
+    创建API密钥
+
+    参数:
+        name: 密钥用途说明
+        scopes: 授权范围列表
+        expires_at: 过期时间（毫秒时间戳），0表示永不过期
+
+    返回:
+        Dict: 密钥信息，包含明文key（仅此一次返回）
+
+    异常:
+        ValueError: scope不合法
+    """
+    for scope in scopes:
+        if scope not in SUPPORTED_SCOPES:
+            raise ValueError(
+                f'Invalid scope: {scope}, must be one of: {", ".join(SUPPORTED_SCOPES)}'
+            )
+
+    # 生成密钥：orbit_ 前缀 + 32字节随机
+    plain_key = 'orbit_' + determinism.token_hex(32)
+
+    db = get_db_session()
+    try:
+        now = determinism.now_ms()
+        api_key = ApiKey(
+            name=name,
+            key_hash=_hash_key(plain_key),
+            key_prefix=plain_key[:12],
+            scopes=';'.join(scopes),
+            expires_at=expires_at,
+            last_used_at=0,
+            revoked=0,
+            created_at=now
+        )
+        db.add(api_key)
+        db.commit()
+
+        logger.info(f"Created API key: name={name}, scopes={scopes}")
+
+        result = api_key.to_dict()
+        result['key'] = plain_key  # 明文仅在创建时返回一次
+        return result
+    except Exception as e:
+        logger.error(f"Error creating API key: {e}")
+        db.rollback()
+        raise
+    finally:
+        db.close()
+
+
+def list_api_keys() -> List[Dict]:
+    """获取所有API密钥（不包含密钥本身）"""
+    db = get_db_session()
+    try:
+        keys = db.query(ApiKey).order_by(ApiKey.created_at.desc()).all()
+        return [k.to_dict() for k in keys]
+    finally:
+        db.close()
+
+
+def revoke_api_key(key_id: int) -> bool:
+    """
+    吊销API密钥
+
+    异常:
+        RuntimeError: 密钥不存在
+    """
+    db = get_db_session()
+    try:
+        api_key = db.query(ApiKey).filter(ApiKey.id == key_id).first()
+        if not api_key:
+            raise RuntimeError('API key not found')
+
+        api_key.revoked = 1
+        db.commit()
+
+        logger.info(f"Revoked API key: id={key_id}, name={api_key.name}")
+        return True
+    except Exception as e:
+        db.rollback()
+        raise
+    finally:
+        db.close()
+
+
+def verify_api_key(plain_key: str, required_scope: str) -> bool:
+    """
+    校验API密钥是否有效且具有指定scope
+    校验成功时更新last_used_at（用于审计）
+    """
+    if not plain_key:
+        return False
+
+    db = get_db_session()
+    try:
+        api_key = db.query(ApiKey).filter(
+            ApiKey.key_hash == _hash_key(plain_key)
+        ).first()
+
+        if not api_key:
+            return False
+        if api_key.revoked:
+            logger.warning(f"Rejected revoked API key: {api_key.key_prefix}...")
+            return False
+
+        now = determinism.now_ms()
+        if api_key.expires_at and api_key.expires_at < now:
+            logger.warning(f"Rejected expired API key: {api_key.key_prefix}...")
+            return False
+
+        scopes = (api_key.scopes or '').split(';')
+        if required_scope not in scopes:
+            logger.warning(
+                f"API key {api_key.key_prefix}... missing scope: {required_scope}"
+            )
+            return False
+
+        # 更新最后使用时间（审计用）
+        api_key.last_used_at = now
+        db.commit()
+        return True
+    except Exception as e:
+        logger.error(f"Error verifying API key: {e}")
+        db.rollback()
+        return False
+    finally:
+        db.close()
+
+
+def require_scope(scope: str):
+    """
+    Flask装饰器：要求请求携带具有指定scope的API密钥
+    未启用 ORBIT_REQUIRE_API_KEY 时直接放行（向后兼容）
+    """
+    def decorator(f):
+        @wraps(f)
+        def wrapper(*args, **kwargs):
+            if not REQUIRE_API_KEY:
+                return f(*args, **kwargs)
+
+            plain_key = request.headers.get(API_KEY_HEADER, '')
+            if not verify_api_key(plain_key, scope):
+                return jsonify({'error': 'Invalid or insufficient API key'}), 401
+
+            return f(*args, **kwargs)
+        return wrapper
+    return decorator
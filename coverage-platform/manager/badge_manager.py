@@ -0,0 +1,354 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+徽章管理模块
+生成 shields 风格的 SVG 覆盖率徽章
+支持多种指标：全量覆盖率、增量覆盖率、block 覆盖率、趋势箭头
+支持多种样式（flat/flat-square/for-the-badge）和自定义标签文本，
+CJK 标签按更宽的字宽估算并附加留白，避免中文标签被裁切
+"""
+
+import logging
+import time
+from typing import Dict, Optional, Tuple
+from xml.sax.saxutils import escape as _xml_escape
+
+from models import (
+    CoverageReport, CoverageFile, CoverageRange, CoverageConfig,
+    CoverageSnapshot, get_db_session
+)
+from manager.manager import get_report_statistics, should_exclude_file
+from manager import precision
+
+logger = logging.getLogger(__name__)
+
+# 支持的徽章指标
+BADGE_METRIC_LINE = 'line'      # 全量语句覆盖率（默认）
+BADGE_METRIC_DIFF = 'diff'      # 增量覆盖率（最近一次报告 vs 基准分支）
+BADGE_METRIC_BLOCK = 'block'    # block 级覆盖率（近似分支覆盖）
+BADGE_METRIC_TREND = 'trend'    # 趋势箭头（对比一周前的快照）
+
+SUPPORTED_METRICS = [
+    BADGE_METRIC_LINE, BADGE_METRIC_DIFF, BADGE_METRIC_BLOCK, BADGE_METRIC_TREND
+]
+
+# 趋势对比的默认时间窗口（7天，毫秒）
+TREND_WINDOW_MS = 7 * 24 * 3600 * 1000
+
+# 支持的徽章样式
+BADGE_STYLE_FLAT = 'flat'                    # shields.io flat（默认）
+BADGE_STYLE_FLAT_SQUARE = 'flat-square'      # 无圆角无渐变
+BADGE_STYLE_FOR_THE_BADGE = 'for-the-badge'  # 大写加高样式
+
+SUPPORTED_STYLES = [
+    BADGE_STYLE_FLAT, BADGE_STYLE_FLAT_SQUARE, BADGE_STYLE_FOR_THE_BADGE
+]
+
+# SVG 模板（shields.io flat 风格）
+_SVG_TEMPLATE = (
+    '<svg xmlns="http://www.w3.org/2000/svg" width="{total_width}" height="20">'
+    '<linearGradient id="b" x2="0" y2="100%">'
+    '<stop offset="0" stop-color="#bbb" stop-opacity=".1"/>'
+    '<stop offset="1" stop-opacity=".1"/>'
+    '</linearGradient>'
+    '<mask id="a"><rect width="{total_width}" height="20" rx="3" fill="#fff"/></mask>'
+    '<g mask="url(#a)">'
+    '<rect width="{label_width}" height="20" fill="#555"/>'
+    '<rect x="{label_width}" width="{value_width}" height="20" fill="{color}"/>'
+    '<rect width="{total_width}" height="20" fill="url(#b)"/>'
+    '</g>'
+    '<g fill="#fff" text-anchor="middle" '
+    'font-family="DejaVu Sans,Verdana,Geneva,sans-serif" font-size="11">'
+    '<text x="{label_x}" y="15" fill="#010101" fill-opacity=".3">{label}</text>'
+    '<text x="{label_x}" y="14">{label}</text>'
+    '<text x="{value_x}" y="15" fill="#010101" fill-opacity=".3">{value}</text>'
+    '<text x="{value_x}" y="14">{value}</text>'
+    '</g>'
+    '</svg>'
+)
+
+# SVG 模板（flat-square 风格：无圆角、无渐变）
+_SVG_TEMPLATE_FLAT_SQUARE = (
+    '<svg xmlns="http://www.w3.org/2000/svg" width="{total_width}" height="20">'
+    '<g shape-rendering="crispEdges">'
+    '<rect width="{label_width}" height="20" fill="#555"/>'
+    '<rect x="{label_width}" width="{value_width}" height="20" fill="{color}"/>'
+    '</g>'
+    '<g fill="#fff" text-anchor="middle" '
+    'font-family="DejaVu Sans,Verdana,Geneva,sans-serif" font-size="11">'
+    '<text x="{label_x}" y="14">{label}</text>'
+    '<text x="{value_x}" y="14">{value}</text>'
+    '</g>'
+    '</svg>'
+)
+
+# SVG 模板（for-the-badge 风格：加高、全大写）
+_SVG_TEMPLATE_FOR_THE_BADGE = (
+    '<svg xmlns="http://www.w3.org/2000/svg" width="{total_width}" height="28">'
+    '<g shape-rendering="crispEdges">'
+    '<rect width="{label_width}" height="28" fill="#555"/>'
+    '<rect x="{label_width}" width="{value_width}" height="28" fill="{color}"/>'
+    '</g>'
+    '<g fill="#fff" text-anchor="middle" '
+    'font-family="DejaVu Sans,Verdana,Geneva,sans-serif" font-size="10" '
+    'letter-spacing="1">'
+    '<text x="{label_x}" y="18">{label}</text>'
+    '<text x="{value_x}" y="18">{value}</text>'
+    '</g>'
+    '</svg>'
+)
+
+# CJK 标签的额外留白（像素，字宽估算偏差的兜底，避免裁切）
+CJK_EXTRA_PADDING = 6
+
+
+def _estimate_text_width(text: str) -> int:
+    """
+    估算文本渲染宽度（像素）
+    非精确测量，按字符数近似：ASCII 约 6.5px，其他（如 CJK）约 13px；
+    含非 ASCII 字符时再加固定留白，估算偏小也不至于裁切
+    """
+    width = 0
+    has_wide = False
+    for ch in text:
+        if ord(ch) < 128:
+            width += 6.5
+        else:
+            width += 13
+            has_wide = True
+    if has_wide:
+        width += CJK_EXTRA_PADDING
+    return int(width)
+
+
+def color_for_rate(rate: float) -> str:
+    """根据覆盖率返回徽章颜色（红→黄→绿）"""
+    if rate >= 90:
+        return '#4c1'       # brightgreen
+    elif rate >= 80:
+        return '#97ca00'    # green
+    elif rate >= 70:
+        return '#a4a61d'    # yellowgreen
+    elif rate >= 60:
+        return '#dfb317'    # yellow
+    elif rate >= 50:
+        return '#fe7d37'    # orange
+    else:
+        return '#e05d44'    # red
+
+
+def render_badge_svg(label: str, value: str, color: str,
+                     style: str = BADGE_STYLE_FLAT) -> str:
+    """
+    渲染 SVG 徽章
+
+    参数:
+        label: 左侧标签文本
+        value: 右侧值文本
+        color: 右侧背景色（如 '#4c1'）
+        style: 徽章样式（flat/flat-square/for-the-badge）
+
+    异常:
+        ValueError: 样式不支持
+    """
+    if style not in SUPPORTED_STYLES:
+        raise ValueError(f'unsupported badge style: {style}')
+
+    if style == BADGE_STYLE_FOR_THE_BADGE:
+        label = label.upper()
+        value = value.upper()
+        template = _SVG_TEMPLATE_FOR_THE_BADGE
+        padding = 20
+    elif style == BADGE_STYLE_FLAT_SQUARE:
+        template = _SVG_TEMPLATE_FLAT_SQUARE
+        padding = 10
+    else:
+        template = _SVG_TEMPLATE
+        padding = 10
+
+    label_width = _estimate_text_width(label) + padding
+    value_width = _estimate_text_width(value) + padding
+    total_width = label_width + value_width
+
+    # label可能来自查询参数，拼进SVG前必须做XML转义
+    # （SVG按image/svg+xml返回，不转义就是反射型XSS）
+    return template.format(
+        total_width=total_width,
+        label_width=label_width,
+        value_width=value_width,
+        color=color,
+        label=_xml_escape(label),
+        value=_xml_escape(value),
+        label_x=label_width // 2,
+        value_x=label_width + value_width // 2
+    )
+
+
+def _get_latest_report(db, repo_id: str, branch: Optional[str] = None) -> Optional[CoverageReport]:
+    """获取仓库最近一次更新的报告（可按分支过滤）"""
+    query = db.query(CoverageReport).filter(CoverageReport.repo_id == repo_id)
+    if branch:
+        query = query.filter(CoverageReport.branch == branch)
+    return query.order_by(CoverageReport.updated_at.desc()).first()
+
+
+def _get_block_coverage(db, report: CoverageReport) -> Tuple[int, int]:
+    """
+    计算 block 级覆盖率（覆盖的 block 数 / 总 block 数）
+    数据模型中没有真正的分支信息，以 block 覆盖作为近似
+    """
+    config = db.query(CoverageConfig).filter(
+        CoverageConfig.repo_id == report.repo_id
+    ).first()
+    exclude_dirs = config.exclude_dirs if config else ''
+    exclude_files = config.exclude_files if config else ''
+
+    files = db.query(CoverageFile).filter(
+        CoverageFile.repo_id == report.repo_id,
+        CoverageFile.branch == report.branch
+    ).all()
+
+    total_blocks = 0
+    covered_blocks = 0
+    for file in files:
+        if should_exclude_file(file.file_path, exclude_dirs, exclude_files):
+            continue
+        ranges = db.query(CoverageRange).filter(
+            CoverageRange.file_id == file.id
+        ).all()
+        for r in ranges:
+            total_blocks += 1
+            if r.hit > 0:
+                covered_blocks += 1
+
+    return total_blocks, covered_blocks
+
+
+def _get_trend_delta(db, repo_id: str, branch: str) -> Optional[float]:
+    """
+    计算当前覆盖率相对一周前快照的变化（百分点）
+    没有足够的历史快照时返回 None
+    """
+    latest = db.query(CoverageSnapshot).filter(
+        CoverageSnapshot.repo_id == repo_id,
+        CoverageSnapshot.branch == branch
+    ).order_by(CoverageSnapshot.created_at.desc()).first()
+
+    if not latest:
+        return None
+
+    cutoff = int(time.time() * 1000) - TREND_WINDOW_MS
+    baseline = db.query(CoverageSnapshot).filter(
+        CoverageSnapshot.repo_id == repo_id,
+        CoverageSnapshot.branch == branch,
+        CoverageSnapshot.created_at <= cutoff
+    ).order_by(CoverageSnapshot.created_at.desc()).first()
+
+    if not baseline:
+        # 窗口外没有快照，退化为使用最早的快照作为基准
+        baseline = db.query(CoverageSnapshot).filter(
+            CoverageSnapshot.repo_id == repo_id,
+            CoverageSnapshot.branch == branch
+        ).order_by(CoverageSnapshot.created_at.asc()).first()
+
+    if not baseline or baseline.id == latest.id:
+        return None
+
+    return (latest.coverage_rate - baseline.coverage_rate) / 100.0
+
+
+def generate_badge(repo_id: str, metric: str = BADGE_METRIC_LINE,
+                   branch: Optional[str] = None,
+                   style: str = BADGE_STYLE_FLAT,
+                   label: Optional[str] = None) -> str:
+    """
+    生成指定仓库的覆盖率徽章 SVG
+
+    参数:
+        repo_id: 仓库唯一标识
+        metric: 指标类型（line/diff/block/trend）
+        branch: 分支名（可选，默认取最近更新的报告）
+        style: 徽章样式（flat/flat-square/for-the-badge）
+        label: 自定义标签文本（可选，默认按指标取英文标签）
+
+    返回:
+        str: SVG 文本；仓库无数据时返回 "unknown" 徽章
+    """
+    db = get_db_session()
+    try:
+        report = _get_latest_report(db, repo_id, branch)
+        if not report:
+            return render_badge_svg(
+                label or 'coverage', 'unknown', '#9f9f9f', style
+            )
+
+        if metric == BADGE_METRIC_LINE:
+            stats = get_report_statistics(report)
+            rate = precision.apply_policy(stats['coverage_rate'])
+            return render_badge_svg(
+                label or 'coverage', f'{precision.format_rate(rate)}%',
+                color_for_rate(rate), style
+            )
+
+        elif metric == BADGE_METRIC_BLOCK:
+            total_blocks, covered_blocks = _get_block_coverage(db, report)
+            if total_blocks == 0:
+                return render_badge_svg(
+                    label or 'block coverage', 'unknown', '#9f9f9f', style
+                )
+            rate = precision.apply_policy(covered_blocks / total_blocks * 100)
+            return render_badge_svg(
+                label or 'block coverage', f'{precision.format_rate(rate)}%',
+                color_for_rate(rate), style
+            )
+
+        elif metric == BADGE_METRIC_DIFF:
+            # 增量覆盖率：复用 diff_manager 的计算逻辑
+            from manager.diff_manager import calculate_diff_coverage
+            config = db.query(CoverageConfig).filter(
+                CoverageConfig.repo_id == report.repo_id
+            ).first()
+            if not config:
+                return render_badge_svg(
+                    label or 'diff coverage', 'unknown', '#9f9f9f', style
+                )
+            diff_result = calculate_diff_coverage(
+                repo_url=config.repo_url,
+                repo_id=report.repo_id,
+                target_branch=report.branch,
+                target_commit=report.commit
+            )
+            if not diff_result:
+                return render_badge_svg(
+                    label or 'diff coverage', 'unknown', '#9f9f9f', style
+                )
+            rate = precision.apply_policy(
+                diff_result['summary'].get('incremental_coverage_rate', 0.0)
+            )
+            return render_badge_svg(
+                label or 'diff coverage', f'{precision.format_rate(rate)}%',
+                color_for_rate(rate), style
+            )
+
+        elif metric == BADGE_METRIC_TREND:
+            delta = _get_trend_delta(db, report.repo_id, report.branch)
+            if delta is None:
+                return render_badge_svg(
+                    label or 'coverage trend', 'no data', '#9f9f9f', style
+                )
+            if delta > 0.005:
+                arrow, color = '↑', '#4c1'
+            elif delta < -0.005:
+                arrow, color = '↓', '#e05d44'
+            else:
+                arrow, color = '→', '#9f9f9f'
+            return render_badge_svg(
+                label or 'coverage trend', f'{arrow} {delta:+.1f}pt',
+                color, style
+            )
+
+        else:
+            raise ValueError(f'unsupported badge metric: {metric}')
+    finally:
+        db.close()
@@ -0,0 +1,91 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+组织基准分析模块
+把一个仓库的覆盖率放到同语言的全部接入仓库中做百分位对比，
+回答"72%在公司内部算好还是落后"这类问题
+"""
+
+import logging
+from typing import Dict, List, Optional
+
+from models import CoverageConfig, CoverageSnapshot, get_db_session
+from manager import precision
+
+logger = logging.getLogger(__name__)
+
+# repo_type 到语言名的映射（与 CoverageConfig.repo_type 一致）
+REPO_TYPE_LANGUAGES = {1: 'go', 2: 'python', 3: 'java'}
+
+
+def _latest_rate(db, repo_id: str, branch: str) -> Optional[float]:
+    """取仓库基准分支最新快照的覆盖率（百分比）"""
+    snapshot = db.query(CoverageSnapshot).filter(
+        CoverageSnapshot.repo_id == repo_id,
+        CoverageSnapshot.branch == branch
+    ).order_by(CoverageSnapshot.created_at.desc()).first()
+    if not snapshot:
+        return None
+    return snapshot.coverage_rate / 100.0
+
+
+def _percentile_value(sorted_rates: List[float], percentile: float) -> float:
+    """最近秩法计算百分位值（sorted_rates 升序非空）"""
+    if not sorted_rates:
+        return 0.0
+    rank = max(1, round(percentile / 100 * len(sorted_rates)))
+    return sorted_rates[rank - 1]
+
+
+def get_repo_benchmark(repo_id: str) -> Optional[Dict]:
+    """
+    计算仓库在同语言接入仓库中的覆盖率百分位
+
+    返回:
+        Optional[Dict]: {
+            'repo_id', 'language', 'coverage_rate',
+            'percentile',   # 该仓库超过了同语言多少比例的仓库（0-100）
+            'cohort_size',  # 同语言且有覆盖率数据的仓库数
+            'median', 'p75', 'p90'
+        }
+        仓库不存在或无覆盖率数据时返回 None
+    """
+    db = get_db_session()
+    try:
+        config = db.query(CoverageConfig).filter(
+            CoverageConfig.repo_id == repo_id
+        ).first()
+        if not config:
+            return None
+
+        own_rate = _latest_rate(db, repo_id, config.base_branch)
+        if own_rate is None:
+            return None
+
+        # 同语言的全部接入仓库的最新覆盖率
+        cohort_configs = db.query(CoverageConfig).filter(
+            CoverageConfig.repo_type == config.repo_type
+        ).all()
+        rates = []
+        for c in cohort_configs:
+            rate = _latest_rate(db, c.repo_id, c.base_branch)
+            if rate is not None:
+                rates.append(rate)
+
+        rates.sort()
+        below = sum(1 for r in rates if r < own_rate)
+        percentile = below / len(rates) * 100 if rates else 0.0
+
+        return {
+            'repo_id': repo_id,
+            'language': REPO_TYPE_LANGUAGES.get(config.repo_type, 'unknown'),
+            'coverage_rate': precision.apply_policy(own_rate),
+            'percentile': precision.apply_policy(percentile),
+            'cohort_size': len(rates),
+            'median': precision.apply_policy(_percentile_value(rates, 50)),
+            'p75': precision.apply_policy(_percentile_value(rates, 75)),
+            'p90': precision.apply_policy(_percentile_value(rates, 90))
+        }
+    finally:
+        db.close()
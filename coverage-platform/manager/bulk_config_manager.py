@@ -0,0 +1,155 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+批量配置管理模块
+把全部仓库配置导出为YAML、批量套用修改（如全组织统一提高门禁阈值），
+支持dry-run先看diff再落库——管理几百个仓库的平台管理员不用逐个点
+
+导出格式:
+    repos:
+      - repo_id: "123"
+        repo_name: tuna        # 只读，帮助人工核对
+        repo_url: https://...  # 只读
+        base_branch: master
+        exclude_dirs: vendor/
+        exclude_files: "*.pb.go"
+        repo_type: 1
+        gate_threshold: 60
+        criticality_weights: "pkg/pay/=3"
+批量套用时只认repo_id和下面的可写字段，其余忽略
+"""
+
+import logging
+import time
+from typing import Dict, List
+
+import yaml
+
+from models import CoverageConfig, get_db_session
+
+logger = logging.getLogger(__name__)
+
+# 批量套用允许修改的字段
+WRITABLE_FIELDS = [
+    'base_branch', 'exclude_dirs', 'exclude_files',
+    'repo_type', 'gate_threshold', 'criticality_weights',
+    'freshness_slo_hours'
+]
+
+
+def export_configs_yaml() -> str:
+    """导出全部仓库配置为YAML（可写字段+只读的识别字段）"""
+    db = get_db_session()
+    try:
+        configs = db.query(CoverageConfig).order_by(
+            CoverageConfig.repo_name.asc()
+        ).all()
+
+        repos = []
+        for config in configs:
+            entry = {
+                'repo_id': config.repo_id,
+                'repo_name': config.repo_name,
+                'repo_url': config.repo_url
+            }
+            for field in WRITABLE_FIELDS:
+                entry[field] = getattr(config, field)
+            repos.append(entry)
+
+        return yaml.safe_dump(
+            {'repos': repos}, allow_unicode=True, sort_keys=False
+        )
+    finally:
+        db.close()
+
+
+def bulk_apply(doc: Dict, dry_run: bool = True) -> Dict:
+    """
+    批量套用配置修改
+
+    参数:
+        doc: {'repos': [{'repo_id': ..., <可写字段>...}]}
+        dry_run: True时只计算diff不落库
+
+    返回:
+        Dict: {
+            'dry_run',
+            'changes': [{'repo_id', 'repo_name',
+                         'fields': {字段: {'from', 'to'}}}],
+            'unchanged': 无变化的仓库数,
+            'missing': [配置不存在的repo_id]
+        }
+
+    异常:
+        ValueError: 文档结构不合法
+    """
+    repos = doc.get('repos') if isinstance(doc, dict) else None
+    if not isinstance(repos, list):
+        raise ValueError("document must contain a 'repos' list")
+
+    db = get_db_session()
+    try:
+        changes = []
+        missing = []
+        unchanged = 0
+
+        for entry in repos:
+            if not isinstance(entry, dict) or not entry.get('repo_id'):
+                raise ValueError(f'each repo entry needs a repo_id: {entry}')
+            repo_id = str(entry['repo_id'])
+
+            config = db.query(CoverageConfig).filter(
+                CoverageConfig.repo_id == repo_id
+            ).first()
+            if not config:
+                missing.append(repo_id)
+                continue
+
+            fields = {}
+            for field in WRITABLE_FIELDS:
+                if field not in entry:
+                    continue
+                new_value = entry[field]
+                if field in ('gate_threshold', 'repo_type', 'freshness_slo_hours'):
+                    new_value = int(new_value)
+                elif isinstance(new_value, str):
+                    new_value = new_value.strip()
+                old_value = getattr(config, field)
+                if new_value == old_value:
+                    continue
+                fields[field] = {'from': old_value, 'to': new_value}
+                if not dry_run:
+                    setattr(config, field, new_value)
+
+            if not fields:
+                unchanged += 1
+                continue
+            if not dry_run:
+                config.updated_at = int(time.time() * 1000)
+            changes.append({
+                'repo_id': repo_id,
+                'repo_name': config.repo_name,
+                'fields': fields
+            })
+
+        if dry_run:
+            db.rollback()
+        else:
+            db.commit()
+            logger.info(
+                f"Bulk-applied config changes: {len(changes)} repos changed, "
+                f"{unchanged} unchanged, {len(missing)} missing"
+            )
+
+        return {
+            'dry_run': dry_run,
+            'changes': changes,
+            'unchanged': unchanged,
+            'missing': missing
+        }
+    except Exception:
+        db.rollback()
+        raise
+    finally:
+        db.close()
@@ -0,0 +1,149 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+覆盖率变更日志业务逻辑管理层
+给定发布区间（from_commit..to_commit），列出有代码变更的包（目录）
+及其发布前后的覆盖率，并标记覆盖率下降的包——发布经理可以直接
+贴进发布就绪评审
+
+覆盖率取自文件级快照（CoverageFileSnapshot），要求区间两端的commit
+都上报过覆盖率；某端没有快照时该包的对应数据为 None（标记no_data）
+"""
+
+import logging
+import os
+import subprocess
+from typing import Dict, List, Optional
+
+from models import CoverageConfig, CoverageFileSnapshot, get_db_session
+from manager.repo_manager import get_bare_repo_path
+
+logger = logging.getLogger(__name__)
+
+
+def _changed_files(repo_url: str, from_commit: str,
+                   to_commit: str) -> Optional[List[str]]:
+    """列出区间内有变更的文件路径，git失败返回 None"""
+    try:
+        bare_repo_path = get_bare_repo_path(repo_url)
+        result = subprocess.run(
+            ['git', 'diff', '--name-only', f'{from_commit}..{to_commit}'],
+            cwd=bare_repo_path,
+            capture_output=True,
+            text=True,
+            timeout=60
+        )
+        if result.returncode != 0:
+            logger.warning(f"git diff failed: {result.stderr}")
+            return None
+        return [line for line in result.stdout.splitlines() if line.strip()]
+    except Exception as e:
+        logger.warning(f"Failed to list changed files: {e}")
+        return None
+
+
+def _package_of(file_path: str) -> str:
+    """文件所属的包（目录，仓库根目录的文件归为'.'）"""
+    return os.path.dirname(file_path) or '.'
+
+
+def _package_coverage(snapshots: List, package: str) -> Optional[Dict]:
+    """从某个commit的文件级快照聚合包覆盖率，包内无数据返回 None"""
+    total = 0
+    covered = 0
+    for s in snapshots:
+        if _package_of(s.file_path) != package:
+            continue
+        total += s.total_statements
+        covered += s.covered_statements
+    if total == 0:
+        return None
+    return {
+        'total_statements': total,
+        'covered_statements': covered,
+        'coverage_rate': round(covered / total * 100, 2)
+    }
+
+
+def build_release_changelog(repo_id: str, from_commit: str,
+                            to_commit: str) -> Optional[Dict]:
+    """
+    构建发布区间的覆盖率变更日志
+
+    返回:
+        Optional[Dict]: {
+            'repo_id', 'from_commit', 'to_commit',
+            'packages': [{
+                'package',             # 目录
+                'files_changed',       # 区间内变更的文件数
+                'before', 'after',     # 两端覆盖率（无数据为 None）
+                'delta_rate',          # after - before（某端无数据为 None）
+                'decreased'            # 覆盖率是否下降
+            }]（按delta_rate升序，下降最多的在前）,
+            'decreased_count': 覆盖率下降的包数
+        }
+        仓库无配置或git diff失败返回 None
+    """
+    db = get_db_session()
+    try:
+        config = db.query(CoverageConfig).filter(
+            CoverageConfig.repo_id == repo_id
+        ).first()
+        if not config or not config.repo_url:
+            logger.warning(f"No config for repo: {repo_id}")
+            return None
+
+        changed = _changed_files(config.repo_url, from_commit, to_commit)
+        if changed is None:
+            return None
+
+        packages = {}
+        for file_path in changed:
+            packages.setdefault(_package_of(file_path), 0)
+            packages[_package_of(file_path)] += 1
+
+        before_snapshots = db.query(CoverageFileSnapshot).filter(
+            CoverageFileSnapshot.repo_id == repo_id,
+            CoverageFileSnapshot.commit == from_commit
+        ).all()
+        after_snapshots = db.query(CoverageFileSnapshot).filter(
+            CoverageFileSnapshot.repo_id == repo_id,
+            CoverageFileSnapshot.commit == to_commit
+        ).all()
+
+        entries = []
+        decreased_count = 0
+        for package, files_changed in packages.items():
+            before = _package_coverage(before_snapshots, package)
+            after = _package_coverage(after_snapshots, package)
+            delta = None
+            decreased = False
+            if before and after:
+                delta = round(after['coverage_rate'] - before['coverage_rate'], 2)
+                decreased = delta < 0
+            if decreased:
+                decreased_count += 1
+            entries.append({
+                'package': package,
+                'files_changed': files_changed,
+                'before': before,
+                'after': after,
+                'delta_rate': delta,
+                'decreased': decreased
+            })
+
+        # 下降最多的排前面，没有数据的放最后
+        entries.sort(
+            key=lambda e: e['delta_rate'] if e['delta_rate'] is not None else float('inf')
+        )
+
+        return {
+            'repo_id': repo_id,
+            'from_commit': from_commit,
+            'to_commit': to_commit,
+            'packages': entries,
+            'decreased_count': decreased_count
+        }
+    finally:
+        db.close()
@@ -0,0 +1,88 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+故障注入模块（混沌测试用，只在staging开启）
+在关键依赖调用点按配置的概率注入错误和延迟，
+用真实的故障模式验证重试、spool落盘、消息重新入队等兜底逻辑
+
+注入点（target）:
+    storage: 报告入库事务
+    git: 仓库克隆/更新
+    githost: GitLab API调用
+    publish: 消息发布
+
+环境变量:
+    CHAOS_ENABLED: 设为1/true启用（默认关闭，生产环境严禁开启）
+    CHAOS_ERROR_RATES: 各注入点的报错概率，如 "storage=0.1;git=0.05"
+    CHAOS_LATENCY_RATES: 各注入点的延迟注入概率，如 "storage=0.2"
+    CHAOS_LATENCY_MS: 注入的延迟毫秒数（默认 1000）
+"""
+
+import logging
+import os
+import random
+import time
+from typing import Dict
+
+logger = logging.getLogger(__name__)
+
+CHAOS_ENABLED = os.getenv('CHAOS_ENABLED', '').lower() in ('1', 'true')
+CHAOS_LATENCY_MS = int(os.getenv('CHAOS_LATENCY_MS', '1000'))
+
+
+class ChaosInjectedError(Exception):
+    """注入的故障（消息中带标记，便于在日志里与真实故障区分）"""
+
+    def __init__(self, target: str):
+        super().__init__(f'chaos: injected failure for target "{target}"')
+        self.target = target
+
+
+def _parse_rates(raw: str) -> Dict[str, float]:
+    """解析 "target=概率;..." 格式的注入配置，非法项跳过并记录日志"""
+    rates = {}
+    for pair in (raw or '').split(';'):
+        pair = pair.strip()
+        if not pair or '=' not in pair:
+            continue
+        target, value = pair.split('=', 1)
+        target = target.strip()
+        try:
+            rate = float(value.strip())
+        except ValueError:
+            logger.warning(f"Invalid chaos rate, skipping: {pair}")
+            continue
+        if not target or not 0 <= rate <= 1:
+            logger.warning(f"Invalid chaos rate, skipping: {pair}")
+            continue
+        rates[target] = rate
+    return rates
+
+
+ERROR_RATES = _parse_rates(os.getenv('CHAOS_ERROR_RATES', ''))
+LATENCY_RATES = _parse_rates(os.getenv('CHAOS_LATENCY_RATES', ''))
+
+if CHAOS_ENABLED:
+    logger.warning(
+        f"Chaos injection ENABLED: error_rates={ERROR_RATES}, "
+        f"latency_rates={LATENCY_RATES}, latency_ms={CHAOS_LATENCY_MS}"
+    )
+
+
+def maybe_inject(target: str) -> None:
+    """
+    在调用点按配置注入故障
+    先掷延迟（sleep后继续），再掷错误（抛ChaosInjectedError）
+    未启用时是空操作，调用点可以无条件保留
+    """
+    if not CHAOS_ENABLED:
+        return
+
+    if random.random() < LATENCY_RATES.get(target, 0):
+        logger.warning(f"[chaos] Injecting {CHAOS_LATENCY_MS}ms latency: target={target}")
+        time.sleep(CHAOS_LATENCY_MS / 1000)
+
+    if random.random() < ERROR_RATES.get(target, 0):
+        logger.warning(f"[chaos] Injecting failure: target={target}")
+        raise ChaosInjectedError(target)
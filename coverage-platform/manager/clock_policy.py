@@ -0,0 +1,63 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+时钟信任策略模块
+报告携带的 timestamp 来自 CI 侧时钟，可能漂移或晚到数小时，
+这里决定入库时间戳用"报告声明的时间"还是"平台收到的时间"：
+    reported: 信任报告时间戳（缺失时用收到时间）
+    received: 一律用平台收到时间
+    bounded:  报告时间戳与收到时间偏差超过上限时改用收到时间（默认）
+
+快照同时保留两个时间（created_at=修正后的报告时间, received_at=收到时间），
+趋势查询可以按任一时间轴排序
+
+环境变量:
+    TIMESTAMP_TRUST_POLICY: reported / received / bounded（默认bounded）
+    MAX_TIMESTAMP_SKEW_MS: bounded 策略允许的最大偏差（毫秒，默认6小时）
+"""
+
+import logging
+import os
+
+logger = logging.getLogger(__name__)
+
+TRUST_REPORTED = 'reported'
+TRUST_RECEIVED = 'received'
+TRUST_BOUNDED = 'bounded'
+
+SUPPORTED_POLICIES = [TRUST_REPORTED, TRUST_RECEIVED, TRUST_BOUNDED]
+
+TIMESTAMP_TRUST_POLICY = os.getenv('TIMESTAMP_TRUST_POLICY', TRUST_BOUNDED)
+if TIMESTAMP_TRUST_POLICY not in SUPPORTED_POLICIES:
+    logger.warning(
+        f"Unknown TIMESTAMP_TRUST_POLICY '{TIMESTAMP_TRUST_POLICY}', "
+        f"falling back to '{TRUST_BOUNDED}'"
+    )
+    TIMESTAMP_TRUST_POLICY = TRUST_BOUNDED
+
+# bounded 策略允许的最大时钟偏差（毫秒）
+MAX_TIMESTAMP_SKEW_MS = int(os.getenv('MAX_TIMESTAMP_SKEW_MS', str(6 * 3600 * 1000)))
+
+
+def effective_timestamp(reported_ms: int, received_ms: int) -> int:
+    """
+    按信任策略计算入库时间戳
+    reported_ms 为 0（消息未携带timestamp）时总是返回 received_ms
+    """
+    if not reported_ms:
+        return received_ms
+
+    if TIMESTAMP_TRUST_POLICY == TRUST_RECEIVED:
+        return received_ms
+
+    if TIMESTAMP_TRUST_POLICY == TRUST_BOUNDED:
+        skew = abs(received_ms - reported_ms)
+        if skew > MAX_TIMESTAMP_SKEW_MS:
+            logger.warning(
+                f"Reported timestamp skew {skew}ms exceeds limit "
+                f"{MAX_TIMESTAMP_SKEW_MS}ms, using received time"
+            )
+            return received_ms
+
+    return reported_ms
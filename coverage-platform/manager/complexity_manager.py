@@ -0,0 +1,176 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+圈复杂度分析模块
+报告入库时按源码估算Go文件的圈复杂度，与覆盖率联合成四象限数据，
+帮助团队优先补"复杂又没测"的文件
+
+复杂度按gocyclo同款口径估算：每个函数基础复杂度1，
+每出现一个分支点（if/for/case/&&/||）加1，文件复杂度为各函数之和
+源码通过bare仓库按commit读取，与函数级分析共用同一套解析路径
+
+环境变量:
+    COMPLEXITY_THRESHOLD: 四象限的复杂度分界线（默认 15）
+    COMPLEXITY_COVERAGE_THRESHOLD: 四象限的覆盖率分界线（默认 50）
+"""
+
+import logging
+import os
+import re
+from typing import Dict, List, Optional
+
+from models import CoverageFile, CoverageRange, CoverageConfig, get_db_session
+from manager.manager import should_exclude_file
+from manager import precision
+
+logger = logging.getLogger(__name__)
+
+COMPLEXITY_THRESHOLD = int(os.getenv('COMPLEXITY_THRESHOLD', '15'))
+COMPLEXITY_COVERAGE_THRESHOLD = float(
+    os.getenv('COMPLEXITY_COVERAGE_THRESHOLD', '50')
+)
+
+# Go分支点：控制流关键字按行首单词匹配，逻辑运算符按出现次数计
+_GO_BRANCH_KEYWORD_RE = re.compile(
+    r'^\s*(?:}?\s*else\s+)?(?:if|for|case|select)\b'
+)
+_GO_LOGICAL_OP_RE = re.compile(r'&&|\|\|')
+_GO_FUNC_RE = re.compile(r'^func\s+(?:\([^)]*\)\s+)?\w+\s*\(')
+
+
+def compute_go_complexity(content: str) -> int:
+    """
+    估算Go源文件的圈复杂度
+    每个函数记1，每个分支点（if/for/case/select/&&/||）加1
+    注释行跳过；字符串内的运算符会被误计，作为估算可接受
+    """
+    complexity = 0
+    in_block_comment = False
+    for line in content.splitlines():
+        stripped = line.strip()
+        if in_block_comment:
+            if '*/' in stripped:
+                in_block_comment = False
+            continue
+        if stripped.startswith('//'):
+            continue
+        if stripped.startswith('/*'):
+            if '*/' not in stripped:
+                in_block_comment = True
+            continue
+
+        if _GO_FUNC_RE.match(line):
+            complexity += 1
+            continue
+        if _GO_BRANCH_KEYWORD_RE.match(line):
+            complexity += 1
+        complexity += len(_GO_LOGICAL_OP_RE.findall(line))
+    return complexity
+
+
+def update_files_complexity(db, repo_url: str, commit: str,
+                            files: List[CoverageFile]) -> None:
+    """
+    为本次报告涉及的Go文件计算并写入复杂度
+    在process_coverage_report的事务内调用（仓库已拉取后），不自行提交
+    单个文件失败只跳过，不影响其他文件
+    """
+    from manager.function_manager import get_file_content_at_commit
+
+    for file in files:
+        if not file.file_path.endswith('.go'):
+            continue
+        try:
+            content = get_file_content_at_commit(repo_url, commit, file.file_path)
+            if content is None:
+                continue
+            file.complexity = compute_go_complexity(content)
+        except Exception as e:
+            logger.warning(
+                f"Failed to compute complexity for {file.file_path}: {e}"
+            )
+
+
+def get_complexity_quadrants(repo_id: str, branch: str) -> Optional[Dict]:
+    """
+    获取复杂度-覆盖率四象限数据
+
+    返回:
+        Optional[Dict]: {
+            'repo_id', 'branch',
+            'complexity_threshold', 'coverage_threshold',
+            'quadrants': {
+                'high_complexity_low_coverage': [...],  # 优先补测
+                'high_complexity_high_coverage': [...],
+                'low_complexity_low_coverage': [...],
+                'low_complexity_high_coverage': [...]
+            }
+        }
+        每个文件项为 {'file', 'complexity', 'coverage_rate', 'total_statements'}
+        仓库未配置时返回 None（复杂度未计算的文件不参与）
+    """
+    db = get_db_session()
+    try:
+        config = db.query(CoverageConfig).filter(
+            CoverageConfig.repo_id == repo_id
+        ).first()
+        if not config:
+            return None
+
+        files = db.query(CoverageFile).filter(
+            CoverageFile.repo_id == repo_id,
+            CoverageFile.branch == branch
+        ).all()
+
+        quadrants = {
+            'high_complexity_low_coverage': [],
+            'high_complexity_high_coverage': [],
+            'low_complexity_low_coverage': [],
+            'low_complexity_high_coverage': []
+        }
+        for file in files:
+            if file.complexity <= 0:
+                continue
+            if should_exclude_file(file.file_path, config.exclude_dirs,
+                                   config.exclude_files):
+                continue
+
+            total = 0
+            covered = 0
+            for r in db.query(CoverageRange).filter(
+                CoverageRange.file_id == file.id
+            ).all():
+                total += r.statements
+                if r.hit > 0:
+                    covered += r.statements
+            if total == 0:
+                continue
+            rate = precision.apply_policy(covered / total * 100)
+
+            high_complexity = file.complexity >= COMPLEXITY_THRESHOLD
+            low_coverage = rate < COMPLEXITY_COVERAGE_THRESHOLD
+            key = (
+                ('high' if high_complexity else 'low') + '_complexity_' +
+                ('low' if low_coverage else 'high') + '_coverage'
+            )
+            quadrants[key].append({
+                'file': file.file_path,
+                'complexity': file.complexity,
+                'coverage_rate': rate,
+                'total_statements': total
+            })
+
+        # 待补测象限按复杂度降序，最值得先看的排前面
+        for items in quadrants.values():
+            items.sort(key=lambda f: f['complexity'], reverse=True)
+
+        return {
+            'repo_id': repo_id,
+            'branch': branch,
+            'complexity_threshold': COMPLEXITY_THRESHOLD,
+            'coverage_threshold': COMPLEXITY_COVERAGE_THRESHOLD,
+            'quadrants': quadrants
+        }
+    finally:
+        db.close()
@@ -141,7 +141,9 @@ def create_config(
     base_branch: str = 'master',
     exclude_dirs: str = '',
     exclude_files: str = '',
-    repo_type: int = 1
+    repo_type: int = 1,
+    gate_threshold: int = 0,
+    criticality_weights: str = ''
 ) -> Dict[str, Any]:
     """
     创建仓库配置
@@ -153,7 +155,9 @@ def create_config(
         exclude_dirs: 排除的目录（分号分隔）
         exclude_files: 排除的文件（分号分隔）
         repo_type: 仓库类型（默认 1，1=go, 2=python, 3=java）
-    
+        gate_threshold: 增量覆盖率门禁阈值（百分比整数，0表示不启用）
+        criticality_weights: 关键路径权重，格式 "路径前缀=权重;..."
+
     返回:
         创建的配置字典
     
@@ -201,6 +205,8 @@ def create_config(
             base_branch=base_branch,
             exclude_dirs=exclude_dirs,
             exclude_files=exclude_files,
+            gate_threshold=gate_threshold,
+            criticality_weights=criticality_weights.strip(),
             created_at=now,
             updated_at=now
         )
@@ -221,7 +227,9 @@ def update_config(
     repo_id: str,
     base_branch: Optional[str] = None,
     exclude_dirs: Optional[str] = None,
-    exclude_files: Optional[str] = None
+    exclude_files: Optional[str] = None,
+    gate_threshold: Optional[int] = None,
+    criticality_weights: Optional[str] = None
 ) -> Dict[str, Any]:
     """
     更新仓库配置
@@ -231,7 +239,9 @@ def update_config(
         base_branch: 基准分支（可选）
         exclude_dirs: 排除的目录（可选）
         exclude_files: 排除的文件（可选）
-    
+        gate_threshold: 增量覆盖率门禁阈值（可选）
+        criticality_weights: 关键路径权重（可选）
+
     返回:
         更新后的配置字典
     
@@ -256,6 +266,10 @@ def update_config(
             config.exclude_dirs = exclude_dirs.strip()
         if exclude_files is not None:
             config.exclude_files = exclude_files.strip()
+        if gate_threshold is not None:
+            config.gate_threshold = int(gate_threshold)
+        if criticality_weights is not None:
+            config.criticality_weights = criticality_weights.strip()
         
         config.updated_at = int(time.time() * 1000)
         
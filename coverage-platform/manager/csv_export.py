@@ -0,0 +1,46 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+CSV 导出模块
+把列表类接口的行数据转成带表头的标准 CSV，
+管理侧最终都要进电子表格，不用每个人自己写转换脚本
+"""
+
+import csv
+import io
+import json
+from typing import Dict, List, Optional
+
+
+def _flatten_value(value):
+    """标量原样输出，嵌套结构序列化为JSON字符串"""
+    if value is None:
+        return ''
+    if isinstance(value, (dict, list)):
+        return json.dumps(value, ensure_ascii=False)
+    return value
+
+
+def rows_to_csv(rows: List[Dict], fields: Optional[List[str]] = None) -> str:
+    """
+    把字典列表转成CSV文本（含表头行）
+
+    参数:
+        rows: 行数据
+        fields: 列顺序，为 None 时取首行的键顺序
+
+    返回:
+        str: CSV文本，rows 为空且 fields 为 None 时只有空串
+    """
+    if fields is None:
+        if not rows:
+            return ''
+        fields = list(rows[0].keys())
+
+    output = io.StringIO()
+    writer = csv.writer(output)
+    writer.writerow(fields)
+    for row in rows:
+        writer.writerow([_flatten_value(row.get(field)) for field in fields])
+    return output.getvalue()
@@ -0,0 +1,70 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+处理预算与取消模块
+为单条报告的处理设置时间预算，在各处理阶段边界检查剩余时间，
+超出预算时中止当前报告而不是让worker被慢报告无限占住
+
+超时原因会通过组件心跳进入状态接口（见 status_manager），
+方便事后定位是哪个阶段（解析/存储/git）吃掉了预算
+
+环境变量:
+    PROCESSING_BUDGET_MS: 单条报告的处理预算（毫秒），0或未设置表示不限制
+"""
+
+import logging
+import os
+import time
+from typing import Optional
+
+logger = logging.getLogger(__name__)
+
+# 单条报告处理预算（毫秒），0表示不限制
+PROCESSING_BUDGET_MS = int(os.getenv('PROCESSING_BUDGET_MS', '0'))
+
+
+class DeadlineExceeded(Exception):
+    """处理预算耗尽，stage 记录超时发生的阶段"""
+
+    def __init__(self, stage: str, budget_ms: int):
+        self.stage = stage
+        self.budget_ms = budget_ms
+        super().__init__(
+            f"processing budget exceeded at stage '{stage}' (budget {budget_ms}ms)"
+        )
+
+
+class Deadline:
+    """
+    一次处理的截止时间
+    budget_ms 为 0 时所有检查都直接通过（不限制）
+    """
+
+    def __init__(self, budget_ms: int):
+        self.budget_ms = budget_ms
+        self.started_at = time.monotonic()
+
+    def remaining_ms(self) -> Optional[int]:
+        """剩余预算（毫秒），不限制时返回 None"""
+        if self.budget_ms <= 0:
+            return None
+        elapsed = (time.monotonic() - self.started_at) * 1000
+        return int(self.budget_ms - elapsed)
+
+    def expired(self) -> bool:
+        """预算是否已耗尽"""
+        remaining = self.remaining_ms()
+        return remaining is not None and remaining <= 0
+
+    def check(self, stage: str) -> None:
+        """预算已耗尽时抛出 DeadlineExceeded，否则无操作"""
+        if self.expired():
+            raise DeadlineExceeded(stage, self.budget_ms)
+
+
+def start_deadline(budget_ms: Optional[int] = None) -> Deadline:
+    """按指定预算（默认取环境变量）开始计时"""
+    if budget_ms is None:
+        budget_ms = PROCESSING_BUDGET_MS
+    return Deadline(budget_ms)
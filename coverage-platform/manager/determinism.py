@@ -0,0 +1,66 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+可注入的时钟与ID/令牌生成
+时间戳、随机ID、令牌的生成统一走本模块，默认用系统时钟和secrets/uuid；
+集成测试和回放工具可注入固定实现，让处理过程完全确定、
+历史处理可以逐字节复现
+
+用法（测试/回放中）:
+    from manager import determinism
+    determinism.set_providers(
+        clock=lambda: 1700000000.0,           # 固定时钟（秒，浮点）
+        token=lambda nbytes: '00' * nbytes,   # 固定令牌（十六进制串）
+    )
+    ...
+    determinism.reset_providers()
+"""
+
+import secrets
+import time
+from typing import Callable, Optional
+
+# 默认实现：系统时钟 + 密码学随机
+_default_clock = time.time
+_default_token = secrets.token_hex
+
+_clock = _default_clock
+_token = _default_token
+
+
+def set_providers(clock: Optional[Callable[[], float]] = None,
+                  token: Optional[Callable[[int], str]] = None) -> None:
+    """注入时钟/令牌实现（传None的保持当前实现不变）"""
+    global _clock, _token
+    if clock is not None:
+        _clock = clock
+    if token is not None:
+        _token = token
+
+
+def reset_providers() -> None:
+    """恢复默认实现（系统时钟+密码学随机）"""
+    global _clock, _token
+    _clock = _default_clock
+    _token = _default_token
+
+
+def now_s() -> float:
+    """当前时间（秒，浮点）"""
+    return _clock()
+
+
+def now_ms() -> int:
+    """当前时间（毫秒，整数，库中所有时间戳字段的单位）"""
+    return int(_clock() * 1000)
+
+
+def token_hex(nbytes: int) -> str:
+    """生成十六进制令牌（默认密码学随机，注入后确定）"""
+    return _token(nbytes)
+
+
+def new_id() -> str:
+    """生成32位十六进制唯一标识（uuid4形态，注入令牌实现后确定）"""
+    return token_hex(16)
@@ -18,8 +18,10 @@ from models import (
     get_db_session
 )
 from manager.repo_manager import get_worktree_dir, get_bare_repo_path
+from manager import link_manager
 from diff_coverage import (
-    CoverageBlock, CoverageIndex, DiffCoverageFile, DiffCoverageSummary,
+    CoverageBlock, CoverageIndex, DiffCoverageFile, DiffCoverageStatus,
+    DiffCoverageSummary,
     parse_git_diff, build_coverage_index, merge_diff_and_coverage,
     generate_diff_coverage_summary, generate_monaco_dataset
 )
@@ -409,10 +411,24 @@ def calculate_diff_coverage(
         
         # 8. 生成 Monaco 数据集
         monaco_data = generate_monaco_dataset(file_results)
-        
-        # 9. 返回结果
+
+        # 9. 返回结果（每个文件附带锚定到目标commit的托管平台深链接，
+        # 定位到第一个未覆盖的新增行，方便从"未覆盖行"列表一键跳转）
+        files = []
+        for f in file_results:
+            entry = f.to_dict()
+            first_uncovered = next(
+                (line.line_number for line in f.lines
+                 if line.status == DiffCoverageStatus.NEW_UNCOVERED),
+                None
+            )
+            entry['url'] = link_manager.file_link(
+                repo_url, target_commit, f.file_path, first_uncovered
+            )
+            files.append(entry)
+
         return {
-            'files': [f.to_dict() for f in file_results],
+            'files': files,
             'summary': summary.to_dict(),
             'monaco': monaco_data,
             'base_commit': base_commit,
@@ -473,3 +489,58 @@ def update_base_commit_for_report(report_id: int) -> bool:
     finally:
         db.close()
 
+
+
+# MR未覆盖行评论（默认关闭，按需通过环境变量开启）
+MR_DIFF_COMMENT_ENABLED = os.environ.get(
+    'MR_DIFF_COMMENT_ENABLED', ''
+).lower() in ('1', 'true')
+MR_DIFF_COMMENT_MAX_FILES = int(os.environ.get('MR_DIFF_COMMENT_MAX_FILES', '10'))
+
+
+def build_mr_uncovered_comment(repo_url: str, repo_id: str,
+                               target_branch: str,
+                               target_commit: str) -> Optional[str]:
+    """
+    生成MR未覆盖行提醒评论（markdown）
+
+    列出新增未覆盖行最多的文件，每个文件带锚定到目标commit的托管平台深链接
+    （直接跳到第一个未覆盖的新增行）；文件数超过MR_DIFF_COMMENT_MAX_FILES时截断
+
+    未启用MR_DIFF_COMMENT_ENABLED、增量计算失败或没有新增未覆盖行时返回 None
+    """
+    if not MR_DIFF_COMMENT_ENABLED:
+        return None
+
+    result = calculate_diff_coverage(
+        repo_url, repo_id, target_branch, target_commit
+    )
+    if not result:
+        return None
+
+    uncovered_files = []
+    for entry in result['files']:
+        count = entry['summary']['new_uncovered']
+        if count <= 0:
+            continue
+        uncovered_files.append((count, entry['file'], entry.get('url')))
+    if not uncovered_files:
+        return None
+
+    uncovered_files.sort(key=lambda item: item[0], reverse=True)
+    total_uncovered = sum(item[0] for item in uncovered_files)
+
+    lines = [
+        f'> :mag: **增量覆盖率提醒**：本次变更有 {total_uncovered} 行新增代码未被测试覆盖',
+        '>'
+    ]
+    for count, file_path, url in uncovered_files[:MR_DIFF_COMMENT_MAX_FILES]:
+        if url:
+            lines.append(f'> - [{file_path}]({url})：{count} 行未覆盖')
+        else:
+            lines.append(f'> - {file_path}：{count} 行未覆盖')
+    if len(uncovered_files) > MR_DIFF_COMMENT_MAX_FILES:
+        lines.append(
+            f'> - …另有 {len(uncovered_files) - MR_DIFF_COMMENT_MAX_FILES} 个文件存在未覆盖行'
+        )
+    return '\n'.join(lines)
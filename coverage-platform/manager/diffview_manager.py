@@ -0,0 +1,146 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+Diff阅读视图业务逻辑管理层
+把MR的diff hunk连同上下文行、每行的覆盖状态一次性拼好返回，
+前端直接渲染即可——不用对每个hunk再去调文件接口和diff接口（N+1）
+
+行结构:
+    {'line': 新文件行号, 'content': 源码, 'changed': 是否为本次新增/修改行,
+     'coverage': covered/uncovered/None（None=不可覆盖或无数据）}
+"""
+
+import logging
+from typing import Dict, List, Optional
+
+from manager.repo_manager import get_bare_repo_path
+from manager.diff_manager import (
+    get_base_branch_for_repo, get_base_commit_from_git,
+    get_coverage_blocks_for_file
+)
+from manager.function_manager import get_file_content_at_commit
+from manager import link_manager
+from diff_coverage import parse_git_diff, build_coverage_index
+
+logger = logging.getLogger(__name__)
+
+# 每个hunk前后附带的上下文行数默认值
+DEFAULT_CONTEXT_LINES = 3
+
+
+def _merge_windows(windows: List[List[int]]) -> List[List[int]]:
+    """合并重叠/相邻的行号窗口（[start, end]，闭区间）"""
+    merged = []
+    for start, end in sorted(windows):
+        if merged and start <= merged[-1][1] + 1:
+            merged[-1][1] = max(merged[-1][1], end)
+        else:
+            merged.append([start, end])
+    return merged
+
+
+def build_diff_view(repo_url: str, repo_id: str, target_branch: str,
+                    target_commit: str, base_branch: Optional[str] = None,
+                    context: int = DEFAULT_CONTEXT_LINES) -> Optional[Dict]:
+    """
+    构建带上下文与覆盖状态的diff阅读视图
+
+    返回:
+        Optional[Dict]: {
+            'base_commit', 'base_branch',
+            'files': [{
+                'file', 'url',          # url锚定到目标commit
+                'hunks': [{'start', 'end', 'lines': [行结构]}]
+            }]
+        }
+        git操作失败返回 None；无差异时files为空列表
+    """
+    try:
+        if not base_branch:
+            base_branch = get_base_branch_for_repo(repo_id)
+
+        bare_repo_path = get_bare_repo_path(repo_url)
+        base_commit = get_base_commit_from_git(
+            bare_repo_path, base_branch, target_commit
+        )
+        if not base_commit:
+            return None
+
+        diff_hunks = parse_git_diff(bare_repo_path, base_commit, target_commit)
+
+        # 按文件归组：变更行集合 + 上下文窗口
+        by_file = {}
+        for hunk in diff_hunks:
+            entry = by_file.setdefault(
+                hunk.file_path, {'changed': set(), 'windows': []}
+            )
+            entry['changed'].update(hunk.added_lines)
+            entry['changed'].update(hunk.modified_lines)
+            if hunk.new_count > 0:
+                entry['windows'].append([
+                    max(hunk.new_start - context, 1),
+                    hunk.new_start + hunk.new_count - 1 + context
+                ])
+
+        files = []
+        for file_path, entry in by_file.items():
+            if not entry['windows']:
+                # 纯删除的文件在新commit里没有可展示的行
+                continue
+
+            content = get_file_content_at_commit(
+                repo_url, target_commit, file_path
+            )
+            if content is None:
+                logger.warning(f"No content at {target_commit}: {file_path}")
+                continue
+            source_lines = content.splitlines()
+
+            blocks = get_coverage_blocks_for_file(
+                repo_id, target_branch, file_path,
+                repo_url=repo_url, commit=target_commit
+            )
+            coverage_index = build_coverage_index(blocks)
+
+            hunks = []
+            first_uncovered = None
+            for start, end in _merge_windows(entry['windows']):
+                end = min(end, len(source_lines))
+                if start > end:
+                    continue
+                lines = []
+                for line_number in range(start, end + 1):
+                    covered = coverage_index.get_line_coverage(line_number)
+                    if covered is None:
+                        coverage = None
+                    else:
+                        coverage = 'covered' if covered else 'uncovered'
+                    changed = line_number in entry['changed']
+                    if (changed and coverage == 'uncovered'
+                            and first_uncovered is None):
+                        first_uncovered = line_number
+                    lines.append({
+                        'line': line_number,
+                        'content': source_lines[line_number - 1],
+                        'changed': changed,
+                        'coverage': coverage
+                    })
+                hunks.append({'start': start, 'end': end, 'lines': lines})
+
+            files.append({
+                'file': file_path,
+                'url': link_manager.file_link(
+                    repo_url, target_commit, file_path, first_uncovered
+                ),
+                'hunks': hunks
+            })
+
+        return {
+            'base_commit': base_commit,
+            'base_branch': base_branch,
+            'files': files
+        }
+    except Exception as e:
+        logger.error(f"Failed to build diff view: {e}")
+        return None
@@ -0,0 +1,97 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+领域事件日志业务逻辑管理层
+把平台的关键领域事件（报告入库、覆盖率合并、门禁评估、通知发出）
+追加写入只增不改的事件表，自增id即序列号；消费接口按序列号游标
+拉取——下游新功能（含SSE推送）可以从任意历史位置回放或补数据，
+不用等新事件从头攒起
+
+事件写入是旁路观测，失败只记日志，绝不影响主流程
+"""
+
+import json
+import logging
+import time
+from typing import Dict, Optional
+
+from models import DomainEvent, get_db_session
+
+logger = logging.getLogger(__name__)
+
+# 领域事件类型
+EVENT_REPORT_INGESTED = 'report.ingested'
+EVENT_COVERAGE_MERGED = 'coverage.merged'
+EVENT_GATE_EVALUATED = 'gate.evaluated'
+EVENT_NOTIFICATION_SENT = 'notification.sent'
+
+# 单次拉取的条数上限
+MAX_READ_LIMIT = 500
+
+
+def append_event(event_type: str, repo_id: Optional[str] = None,
+                 payload: Optional[Dict] = None) -> None:
+    """
+    追加一条领域事件（独立事务，失败只记日志不影响主流程）
+    """
+    db = get_db_session()
+    try:
+        db.add(DomainEvent(
+            event_type=event_type,
+            repo_id=repo_id,
+            payload=json.dumps(payload, ensure_ascii=False) if payload else None,
+            created_at=int(time.time() * 1000)
+        ))
+        db.commit()
+    except Exception as e:
+        db.rollback()
+        logger.warning(f"Failed to append domain event {event_type}: {e}")
+    finally:
+        db.close()
+
+
+def read_events(after_id: int = 0, event_type: Optional[str] = None,
+                repo_id: Optional[str] = None, limit: int = 100) -> Dict:
+    """
+    按序列号游标拉取领域事件（消费接口）
+
+    参数:
+        after_id: 只返回id大于该值的事件（0表示从头回放）
+        event_type: 事件类型过滤（可选）
+        repo_id: 仓库过滤（可选）
+        limit: 返回条数上限（上限MAX_READ_LIMIT）
+
+    返回:
+        Dict: {
+            'data': [事件（payload已解析为字典）],
+            'last_id': 本批最后一条的序列号（下次拉取的游标，
+                       无数据时等于after_id）
+        }
+    """
+    limit = min(max(int(limit), 1), MAX_READ_LIMIT)
+
+    db = get_db_session()
+    try:
+        query = db.query(DomainEvent).filter(DomainEvent.id > after_id)
+        if event_type:
+            query = query.filter(DomainEvent.event_type == event_type)
+        if repo_id:
+            query = query.filter(DomainEvent.repo_id == repo_id)
+        rows = query.order_by(DomainEvent.id.asc()).limit(limit).all()
+
+        data = []
+        for row in rows:
+            entry = row.to_dict()
+            try:
+                entry['payload'] = json.loads(row.payload) if row.payload else None
+            except (ValueError, TypeError):
+                entry['payload'] = None
+            data.append(entry)
+
+        return {
+            'data': data,
+            'last_id': rows[-1].id if rows else after_id
+        }
+    finally:
+        db.close()
@@ -0,0 +1,226 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+门禁豁免业务逻辑管理层
+带截止日期的路径级门禁豁免：生效期内匹配的文件不计入增量覆盖率
+门禁，到期自动失效重新进入门禁；临到期（默认提前两周）的豁免
+可通过接口列出并由定时检查告警——"临时"豁免不会一直活下去
+
+路径匹配规则与配置排除一致：以/结尾视为目录前缀，否则按fnmatch模式
+
+环境变量:
+    EXCEPTION_EXPIRY_NOTICE_DAYS: 到期前提醒天数（默认14）
+"""
+
+import fnmatch
+import logging
+import os
+import time
+from typing import Dict, List, Optional
+
+from models import GateException, get_db_session
+from manager.status_manager import record_heartbeat
+
+logger = logging.getLogger(__name__)
+
+EXPIRY_NOTICE_DAYS = int(os.environ.get('EXCEPTION_EXPIRY_NOTICE_DAYS', '14'))
+
+
+def _matches(file_path: str, pattern: str) -> bool:
+    """路径是否命中豁免模式（目录前缀或fnmatch）"""
+    if pattern.endswith('/'):
+        return file_path.startswith(pattern)
+    return fnmatch.fnmatch(file_path, pattern)
+
+
+def create_exception(repo_id: str, path_pattern: str, reason: str,
+                     expires_at: int, created_by: Optional[str] = None) -> Dict:
+    """
+    创建门禁豁免
+
+    异常:
+        ValueError: 参数缺失或截止时间不在未来
+    """
+    if not repo_id or not path_pattern:
+        raise ValueError('repo_id and path_pattern are required')
+    if not reason:
+        raise ValueError('reason is required')
+    try:
+        expires_at = int(expires_at)
+    except (TypeError, ValueError):
+        raise ValueError('expires_at must be a millisecond timestamp')
+
+    now = int(time.time() * 1000)
+    if expires_at <= now:
+        raise ValueError('expires_at must be in the future')
+
+    db = get_db_session()
+    try:
+        exception = GateException(
+            repo_id=repo_id,
+            path_pattern=path_pattern,
+            reason=reason,
+            created_by=created_by,
+            expires_at=expires_at,
+            created_at=now
+        )
+        db.add(exception)
+        db.commit()
+        logger.info(
+            f"Created gate exception: repo_id={repo_id}, "
+            f"pattern={path_pattern}, expires_at={expires_at}"
+        )
+        return exception.to_dict()
+    except Exception:
+        db.rollback()
+        raise
+    finally:
+        db.close()
+
+
+def list_exceptions(repo_id: Optional[str] = None,
+                    include_expired: bool = False) -> List[Dict]:
+    """列出门禁豁免（默认只返回生效中的，带expired标记）"""
+    db = get_db_session()
+    try:
+        query = db.query(GateException)
+        if repo_id:
+            query = query.filter(GateException.repo_id == repo_id)
+        now = int(time.time() * 1000)
+        if not include_expired:
+            query = query.filter(GateException.expires_at > now)
+
+        result = []
+        for exception in query.order_by(GateException.expires_at.asc()).all():
+            entry = exception.to_dict()
+            entry['expired'] = exception.expires_at <= now
+            result.append(entry)
+        return result
+    finally:
+        db.close()
+
+
+def delete_exception(exception_id: int) -> bool:
+    """删除门禁豁免，不存在返回False"""
+    db = get_db_session()
+    try:
+        exception = db.query(GateException).filter(
+            GateException.id == exception_id
+        ).first()
+        if not exception:
+            return False
+        db.delete(exception)
+        db.commit()
+        logger.info(f"Deleted gate exception: id={exception_id}")
+        return True
+    except Exception:
+        db.rollback()
+        raise
+    finally:
+        db.close()
+
+
+def apply_exceptions_to_diff(repo_id: str, diff_result: Dict) -> List[Dict]:
+    """
+    把生效中的豁免套用到增量覆盖率结果上（原地修改）
+
+    命中豁免的文件打上exempted标记，其新增行从summary的
+    门禁统计中扣除并重算增量覆盖率
+
+    返回:
+        List[Dict]: 实际命中文件的豁免列表（没有命中返回空）
+    """
+    db = get_db_session()
+    try:
+        now = int(time.time() * 1000)
+        active = db.query(GateException).filter(
+            GateException.repo_id == repo_id,
+            GateException.expires_at > now
+        ).all()
+        if not active:
+            return []
+        exceptions = [e.to_dict() for e in active]
+    finally:
+        db.close()
+
+    applied_ids = set()
+    summary = diff_result['summary']
+    for entry in diff_result.get('files', []):
+        matched = next(
+            (e for e in exceptions
+             if _matches(entry['file'], e['path_pattern'])),
+            None
+        )
+        if not matched:
+            continue
+        entry['exempted'] = True
+        applied_ids.add(matched['id'])
+
+        file_summary = entry.get('summary', {})
+        summary['new_uncovered_lines'] -= file_summary.get('new_uncovered', 0)
+        summary['new_covered_lines'] -= file_summary.get('new_covered', 0)
+        summary['total_new_lines'] = max(
+            summary['total_new_lines'] - len(entry.get('lines', [])), 0
+        )
+
+    if not applied_ids:
+        return []
+
+    coverable = summary['new_covered_lines'] + summary['new_uncovered_lines']
+    summary['incremental_coverage_rate'] = (
+        summary['new_covered_lines'] / coverable * 100
+    ) if coverable > 0 else 100.0
+
+    return [e for e in exceptions if e['id'] in applied_ids]
+
+
+def get_expiring_exceptions(days: int = None) -> List[Dict]:
+    """列出将在N天内到期的豁免（默认提前EXPIRY_NOTICE_DAYS天）"""
+    if days is None:
+        days = EXPIRY_NOTICE_DAYS
+
+    db = get_db_session()
+    try:
+        now = int(time.time() * 1000)
+        deadline = now + days * 24 * 3600 * 1000
+        rows = db.query(GateException).filter(
+            GateException.expires_at > now,
+            GateException.expires_at <= deadline
+        ).order_by(GateException.expires_at.asc()).all()
+
+        result = []
+        for exception in rows:
+            entry = exception.to_dict()
+            entry['days_left'] = round(
+                (exception.expires_at - now) / 86400000.0, 1
+            )
+            result.append(entry)
+        return result
+    finally:
+        db.close()
+
+
+def run_expiry_check() -> Dict:
+    """
+    执行一轮豁免到期检查并记录心跳（由定时任务调用）
+
+    临到期的豁免打告警日志，提醒负责人补测试或续期
+    """
+    expiring = get_expiring_exceptions()
+    if expiring:
+        names = ', '.join(
+            f"{e['repo_id']}:{e['path_pattern']}({e['days_left']}d)"
+            for e in expiring[:10]
+        )
+        logger.warning(
+            f"[豁免到期提醒] {len(expiring)} 个门禁豁免即将到期: {names}"
+        )
+        record_heartbeat(
+            'gate_exception', status='error',
+            detail=f'{len(expiring)} exceptions expiring soon'
+        )
+    else:
+        record_heartbeat('gate_exception', status='ok')
+
+    return {'expiring': expiring, 'total': len(expiring)}
@@ -0,0 +1,125 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+覆盖率新鲜度SLO业务逻辑管理层
+跟踪每个仓库基准分支覆盖率的陈旧程度（距上次处理成功的基准分支
+报告多久），按仓库级SLO阈值判定是否超标——CI接入悄悄坏掉/被废弃的
+仓库会被自动暴露出来，而不是等人发现数字几个月没动
+
+新鲜度按快照的received_at（平台收到时间）计算，不受CI时钟漂移影响
+
+环境变量:
+    FRESHNESS_SLO_HOURS: 全局默认SLO阈值（小时，默认72）
+        仓库可在配置中用freshness_slo_hours单独覆盖
+"""
+
+import logging
+import os
+import time
+from typing import Dict
+
+from models import CoverageConfig, CoverageSnapshot, get_db_session
+from manager.status_manager import record_heartbeat
+
+logger = logging.getLogger(__name__)
+
+FRESHNESS_SLO_HOURS = int(os.environ.get('FRESHNESS_SLO_HOURS', '72'))
+
+STATUS_OK = 'ok'
+STATUS_STALE = 'stale'
+STATUS_NEVER = 'never'  # 从未收到过基准分支报告
+
+
+def get_freshness_overview() -> Dict:
+    """
+    获取全部仓库的基准分支新鲜度
+
+    返回:
+        Dict: {
+            'data': [{
+                'repo_id', 'repo_name', 'base_branch',
+                'slo_hours',          # 生效的SLO阈值
+                'last_received_at',   # 最近一次收到的时间（毫秒，never时为None）
+                'age_hours',          # 距今小时数（never时为None）
+                'status'              # ok/stale/never
+            }]（超标的排前面，按陈旧程度降序）,
+            'stale_count': 超标仓库数（含never）
+        }
+    """
+    db = get_db_session()
+    try:
+        now = int(time.time() * 1000)
+        entries = []
+        stale_count = 0
+
+        for config in db.query(CoverageConfig).all():
+            slo_hours = config.freshness_slo_hours or FRESHNESS_SLO_HOURS
+
+            latest = db.query(CoverageSnapshot).filter(
+                CoverageSnapshot.repo_id == config.repo_id,
+                CoverageSnapshot.branch == config.base_branch
+            ).order_by(CoverageSnapshot.received_at.desc()).first()
+
+            if not latest:
+                status = STATUS_NEVER
+                last_received_at = None
+                age_hours = None
+                stale_count += 1
+            else:
+                # 老数据received_at为0时退回created_at
+                last_received_at = latest.received_at or latest.created_at
+                age_hours = round((now - last_received_at) / 3600000.0, 1)
+                if age_hours > slo_hours:
+                    status = STATUS_STALE
+                    stale_count += 1
+                else:
+                    status = STATUS_OK
+
+            entries.append({
+                'repo_id': config.repo_id,
+                'repo_name': config.repo_name,
+                'base_branch': config.base_branch,
+                'slo_hours': slo_hours,
+                'last_received_at': last_received_at,
+                'age_hours': age_hours,
+                'status': status
+            })
+
+        # 超标的排前面，never视为无限陈旧
+        entries.sort(
+            key=lambda e: e['age_hours'] if e['age_hours'] is not None else float('inf'),
+            reverse=True
+        )
+        entries.sort(key=lambda e: e['status'] == STATUS_OK)
+
+        return {'data': entries, 'stale_count': stale_count}
+    finally:
+        db.close()
+
+
+def run_freshness_check() -> Dict:
+    """
+    执行一轮新鲜度检查并记录心跳（由定时任务调用）
+
+    超标仓库会打告警日志；心跳组件名freshness，
+    状态页接口据此展示最近一次检查结果
+    """
+    overview = get_freshness_overview()
+    stale = [e for e in overview['data'] if e['status'] != STATUS_OK]
+
+    if stale:
+        names = ', '.join(
+            f"{e['repo_name'] or e['repo_id']}({e['status']})" for e in stale[:10]
+        )
+        logger.error(
+            f"[新鲜度告警] {len(stale)} 个仓库基准分支覆盖率超过SLO: {names}"
+        )
+        record_heartbeat(
+            'freshness', status='error',
+            detail=f'{len(stale)} repos exceed freshness SLO'
+        )
+    else:
+        record_heartbeat('freshness', status='ok')
+
+    return overview
@@ -0,0 +1,284 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+函数级覆盖率分析模块
+针对 MR 中被修改的函数，计算其修改前后的覆盖率，
+让 reviewer 看到 "Foo 从 85% 降到 40%" 而不是文件级的模糊数字
+
+函数边界通过源码解析获得（Go / Python），其他语言的文件会被跳过
+"""
+
+import logging
+import re
+import subprocess
+from typing import Dict, List, Optional, Tuple
+
+from models import CoverageReport, CoverageConfig, get_db_session
+from manager.repo_manager import get_bare_repo_path
+from manager.diff_manager import (
+    get_base_branch_for_repo, get_base_commit_from_git,
+    get_coverage_blocks_for_file
+)
+from manager import precision
+from diff_coverage import CoverageBlock, parse_git_diff
+
+logger = logging.getLogger(__name__)
+
+# Go 函数/方法声明，例如 func Foo( 或 func (s *Server) Handle(
+_GO_FUNC_RE = re.compile(r'^func\s+(?:\([^)]*\)\s+)?(\w+)\s*\(')
+
+# Python 函数/方法声明（捕获缩进用于确定结束位置）
+_PY_FUNC_RE = re.compile(r'^(\s*)(?:async\s+)?def\s+(\w+)\s*\(')
+
+
+def get_file_content_at_commit(repo_url: str, commit: str,
+                               file_path: str) -> Optional[str]:
+    """通过 git show 获取指定 commit 下的文件内容，文件不存在返回 None"""
+    try:
+        bare_repo_path = get_bare_repo_path(repo_url)
+        result = subprocess.run(
+            ['git', 'show', f'{commit}:{file_path}'],
+            cwd=bare_repo_path,
+            capture_output=True,
+            text=True,
+            timeout=30
+        )
+        if result.returncode != 0:
+            return None
+        return result.stdout
+    except Exception as e:
+        logger.warning(f"Failed to get file content at {commit}: {e}")
+        return None
+
+
+def parse_function_spans(file_path: str, content: str) -> List[Dict]:
+    """
+    解析源文件中的函数边界
+
+    返回:
+        List[Dict]: [{'name': 函数名, 'start_line': 起始行, 'end_line': 结束行}]
+        不支持的语言返回空列表
+    """
+    if file_path.endswith('.go'):
+        return _parse_go_functions(content)
+    if file_path.endswith('.py'):
+        return _parse_python_functions(content)
+    return []
+
+
+def _parse_go_functions(content: str) -> List[Dict]:
+    """
+    解析 Go 函数边界
+    函数体结束位置取声明之后第一个顶格的 '}'
+    """
+    lines = content.splitlines()
+    spans = []
+    current = None
+    for i, line in enumerate(lines, start=1):
+        match = _GO_FUNC_RE.match(line)
+        if match:
+            if current:
+                # 上一个函数没有正常闭合（语法异常），以当前行之前截断
+                current['end_line'] = i - 1
+                spans.append(current)
+            current = {'name': match.group(1), 'start_line': i, 'end_line': i}
+            continue
+        if current and line.startswith('}'):
+            current['end_line'] = i
+            spans.append(current)
+            current = None
+    if current:
+        current['end_line'] = len(lines)
+        spans.append(current)
+    return spans
+
+
+def _parse_python_functions(content: str) -> List[Dict]:
+    """
+    解析 Python 函数边界
+    函数体结束位置取下一个缩进不深于 def 的非空行
+    """
+    lines = content.splitlines()
+    spans = []
+    open_funcs = []  # (缩进长度, span) 栈，支持嵌套函数/方法
+    for i, line in enumerate(lines, start=1):
+        stripped = line.strip()
+        if not stripped or stripped.startswith('#'):
+            continue
+        indent = len(line) - len(line.lstrip())
+
+        # 缩进回退：闭合所有缩进不浅于当前行的函数
+        while open_funcs and indent <= open_funcs[-1][0]:
+            _, span = open_funcs.pop()
+            span['end_line'] = i - 1
+            spans.append(span)
+
+        match = _PY_FUNC_RE.match(line)
+        if match:
+            open_funcs.append(
+                (indent, {'name': match.group(2), 'start_line': i, 'end_line': i})
+            )
+
+    for _, span in open_funcs:
+        span['end_line'] = len(lines)
+        spans.append(span)
+
+    spans.sort(key=lambda s: s['start_line'])
+    return spans
+
+
+def coverage_rate_for_span(blocks: List[CoverageBlock], start_line: int,
+                           end_line: int) -> Optional[Dict]:
+    """
+    计算与指定行区间重叠的 block 的覆盖率
+    区间内没有任何 block 时返回 None（函数不可覆盖或无数据）
+    """
+    total_statements = 0
+    covered_statements = 0
+    for block in blocks:
+        if block.end_line < start_line or block.start_line > end_line:
+            continue
+        total_statements += block.statements
+        if block.hit > 0:
+            covered_statements += block.statements
+
+    if total_statements == 0:
+        return None
+
+    return {
+        'total_statements': total_statements,
+        'covered_statements': covered_statements,
+        'coverage_rate': precision.apply_policy(
+            covered_statements / total_statements * 100
+        )
+    }
+
+
+def _touched_functions(spans: List[Dict], changed_lines: List[int]) -> List[Dict]:
+    """筛选出与变更行有交集的函数"""
+    touched = []
+    for span in spans:
+        for line in changed_lines:
+            if span['start_line'] <= line <= span['end_line']:
+                touched.append(span)
+                break
+    return touched
+
+
+def calculate_function_deltas(report_id: int) -> Optional[Dict]:
+    """
+    计算报告对应 MR 中每个被修改函数的覆盖率变化
+
+    返回:
+        Optional[Dict]: {
+            'report_id', 'base_commit', 'base_branch',
+            'functions': [{'file', 'function', 'start_line', 'end_line',
+                           'before', 'after', 'delta'}]
+        }
+        before/after 为 coverage_rate_for_span 的结果，无数据时为 None
+    """
+    db = get_db_session()
+    try:
+        report = db.query(CoverageReport).filter(
+            CoverageReport.id == report_id
+        ).first()
+        if not report:
+            logger.error(f"Report not found: {report_id}")
+            return None
+        # 仓库URL在配置表里（报告上没有存）
+        config = db.query(CoverageConfig).filter(
+            CoverageConfig.repo_id == report.repo_id
+        ).first()
+        if not config:
+            logger.error(f"Config not found for repo: {report.repo_id}")
+            return None
+        repo_url = config.repo_url
+        repo_id = report.repo_id
+        target_branch = report.branch
+        target_commit = report.commit
+    finally:
+        db.close()
+
+    base_branch = get_base_branch_for_repo(repo_id)
+    bare_repo_path = get_bare_repo_path(repo_url)
+    if not bare_repo_path:
+        logger.error("Failed to get bare repo path")
+        return None
+
+    base_commit = get_base_commit_from_git(bare_repo_path, base_branch, target_commit)
+    if not base_commit:
+        logger.error("Failed to get base commit")
+        return None
+
+    diff_hunks = parse_git_diff(bare_repo_path, base_commit, target_commit)
+
+    # 按文件聚合变更的新行号
+    file_changed_lines = {}
+    for hunk in diff_hunks:
+        lines = file_changed_lines.setdefault(hunk.file_path, [])
+        lines.extend(range(hunk.new_start, hunk.new_start + max(hunk.new_count, 1)))
+
+    functions = []
+    for file_path, changed_lines in file_changed_lines.items():
+        target_content = get_file_content_at_commit(repo_url, target_commit, file_path)
+        if target_content is None:
+            continue
+        target_spans = parse_function_spans(file_path, target_content)
+        if not target_spans:
+            continue
+
+        touched = _touched_functions(target_spans, changed_lines)
+        if not touched:
+            continue
+
+        target_blocks = get_coverage_blocks_for_file(
+            repo_id, target_branch, file_path,
+            repo_url=repo_url, commit=target_commit
+        )
+        base_blocks = get_coverage_blocks_for_file(
+            repo_id, base_branch, file_path,
+            repo_url=repo_url, commit=base_commit
+        )
+
+        # 修改前的函数边界要按基准 commit 的源码解析（行号可能已漂移）
+        base_content = get_file_content_at_commit(repo_url, base_commit, file_path)
+        base_spans = {}
+        if base_content is not None:
+            for span in parse_function_spans(file_path, base_content):
+                base_spans[span['name']] = span
+
+        for span in touched:
+            after = coverage_rate_for_span(
+                target_blocks, span['start_line'], span['end_line']
+            )
+
+            before = None
+            base_span = base_spans.get(span['name'])
+            if base_span and base_blocks:
+                before = coverage_rate_for_span(
+                    base_blocks, base_span['start_line'], base_span['end_line']
+                )
+
+            delta = None
+            if before is not None and after is not None:
+                delta = precision.apply_policy(
+                    after['coverage_rate'] - before['coverage_rate']
+                )
+
+            functions.append({
+                'file': file_path,
+                'function': span['name'],
+                'start_line': span['start_line'],
+                'end_line': span['end_line'],
+                'before': before,
+                'after': after,
+                'delta': delta
+            })
+
+    return {
+        'report_id': report_id,
+        'base_commit': base_commit,
+        'base_branch': base_branch,
+        'functions': functions
+    }
@@ -0,0 +1,240 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+覆盖率门禁模块
+根据仓库配置的增量覆盖率阈值评估报告是否达标
+支持通过 commit message 中的 [skip coverage] 标记将门禁降级为建议性（留审计日志）
+"""
+
+import logging
+import os
+import subprocess
+import time
+from typing import Dict, Optional
+
+from models import CoverageReport, CoverageConfig, AuditLog, get_db_session
+from manager.diff_manager import calculate_diff_coverage
+from manager.repo_manager import get_bare_repo_path
+from manager import precision
+from manager import opa_manager
+
+logger = logging.getLogger(__name__)
+
+# 门禁跳过标记（commit message 中包含即生效）
+SKIP_GATE_MARKER = os.getenv('GATE_SKIP_MARKER', '[skip coverage]')
+
+# 门禁结论
+VERDICT_PASSED = 'passed'
+VERDICT_FAILED = 'failed'
+VERDICT_ADVISORY = 'advisory'  # 被跳过标记降级，仅供参考
+VERDICT_DISABLED = 'disabled'  # 仓库未启用门禁
+
+
+def record_audit_log(action: str, repo_id: str, detail: str) -> None:
+    """写入审计日志（失败只告警，不影响主流程）"""
+    db = get_db_session()
+    try:
+        db.add(AuditLog(
+            action=action,
+            repo_id=repo_id,
+            detail=detail,
+            created_at=int(time.time() * 1000)
+        ))
+        db.commit()
+    except Exception as e:
+        logger.warning(f"Failed to record audit log: {e}")
+        db.rollback()
+    finally:
+        db.close()
+
+
+def get_commit_message(repo_url: str, commit: str) -> Optional[str]:
+    """从 bare 仓库读取 commit message"""
+    try:
+        bare_repo_path = get_bare_repo_path(repo_url)
+        result = subprocess.run(
+            ['git', 'log', '-1', '--format=%B', commit],
+            cwd=bare_repo_path,
+            capture_output=True,
+            text=True,
+            timeout=30
+        )
+        if result.returncode != 0:
+            logger.warning(f"Failed to get commit message: {result.stderr}")
+            return None
+        return result.stdout
+    except Exception as e:
+        logger.warning(f"Error getting commit message: {e}")
+        return None
+
+
+def has_skip_marker(repo_url: str, commit: str) -> bool:
+    """检查 commit message 是否包含门禁跳过标记"""
+    message = get_commit_message(repo_url, commit)
+    if not message:
+        return False
+    return SKIP_GATE_MARKER in message
+
+
+def evaluate_gate(report_id: int) -> Optional[Dict]:
+    """
+    评估指定报告的覆盖率门禁
+
+    规则:
+        - 仓库 gate_threshold 为 0 时门禁未启用（disabled）
+        - 增量覆盖率 >= 阈值 → passed，否则 failed
+        - commit message 含跳过标记时降级为 advisory，并记录审计日志
+
+    参数:
+        report_id: 报告 ID
+
+    返回:
+        Optional[Dict]: 门禁评估结果，失败返回 None
+    """
+    db = get_db_session()
+    try:
+        report = db.query(CoverageReport).filter(
+            CoverageReport.id == report_id
+        ).first()
+        if not report:
+            logger.error(f"Report not found: {report_id}")
+            return None
+
+        config = db.query(CoverageConfig).filter(
+            CoverageConfig.repo_id == report.repo_id
+        ).first()
+        if not config:
+            logger.error(f"Config not found for repo: {report.repo_id}")
+            return None
+
+        repo_id = report.repo_id
+        branch = report.branch
+        commit = report.commit
+        repo_url = config.repo_url
+        threshold = config.gate_threshold
+    finally:
+        db.close()
+
+    if not threshold:
+        return {
+            'report_id': report_id,
+            'verdict': VERDICT_DISABLED,
+            'threshold': 0,
+            'incremental_coverage_rate': None,
+            'skip_marker': False,
+            'policy': 'builtin',
+            'policy_reason': '',
+            'exceptions_applied': []
+        }
+
+    diff_result = calculate_diff_coverage(
+        repo_url=repo_url,
+        repo_id=repo_id,
+        target_branch=branch,
+        target_commit=commit
+    )
+    if not diff_result:
+        logger.error(f"Failed to calculate diff coverage for gate: report={report_id}")
+        return None
+
+    # 套用生效中的门禁豁免（到期的自动失效，详见exception_manager）
+    from manager import exception_manager
+    exceptions_applied = exception_manager.apply_exceptions_to_diff(
+        repo_id, diff_result
+    )
+    if exceptions_applied:
+        patterns = ', '.join(e['path_pattern'] for e in exceptions_applied)
+        logger.info(
+            f"Gate exceptions applied: report={report_id}, patterns={patterns}"
+        )
+
+    # 阈值比较前先按全局精度策略取整，避免 79.996% 这类边界争议
+    rate = precision.apply_policy(
+        diff_result['summary'].get('incremental_coverage_rate', 0.0)
+    )
+    total_new_lines = diff_result['summary'].get('total_new_lines', 0)
+
+    # 没有新增可覆盖行时直接通过
+    if total_new_lines == 0:
+        verdict = VERDICT_PASSED
+    elif rate >= threshold:
+        verdict = VERDICT_PASSED
+    else:
+        verdict = VERDICT_FAILED
+
+    # 配置了 OPA 时由各组织的 Rego 策略决定结论
+    # OPA 不可用时回退到上面的内置阈值结论（不阻塞合并流程）
+    policy = 'builtin'
+    policy_reason = ''
+    if opa_manager.is_enabled():
+        from manager.manager import get_report_statistics
+
+        db = get_db_session()
+        try:
+            report = db.query(CoverageReport).filter(
+                CoverageReport.id == report_id
+            ).first()
+            statistics = get_report_statistics(report) if report else {}
+        finally:
+            db.close()
+
+        opa_result = opa_manager.evaluate(opa_manager.build_gate_input(
+            repo_id=repo_id,
+            branch=branch,
+            commit=commit,
+            threshold=threshold,
+            rate=rate,
+            total_new_lines=total_new_lines,
+            statistics=statistics,
+            skip_marker=has_skip_marker(repo_url, commit)
+        ))
+        if opa_result is not None:
+            policy = 'rego'
+            policy_reason = opa_result['reason']
+            verdict = VERDICT_PASSED if opa_result['allow'] else VERDICT_FAILED
+
+    skip_marker = False
+    if verdict == VERDICT_FAILED and has_skip_marker(repo_url, commit):
+        skip_marker = True
+        verdict = VERDICT_ADVISORY
+        # 门禁被跳过必须留痕
+        record_audit_log(
+            action='gate_skipped',
+            repo_id=repo_id,
+            detail=(
+                f'gate downgraded to advisory by commit marker {SKIP_GATE_MARKER}: '
+                f'commit={commit}, branch={branch}, '
+                f'rate={precision.format_rate(rate)}%, threshold={threshold}%'
+            )
+        )
+        logger.warning(
+            f"Gate skipped by commit marker: report={report_id}, commit={commit}"
+        )
+
+    # 领域事件（回放日志，失败不影响主流程）
+    from manager import eventlog_manager
+    eventlog_manager.append_event(
+        eventlog_manager.EVENT_GATE_EVALUATED,
+        repo_id=repo_id,
+        payload={'report_id': report_id, 'branch': branch, 'commit': commit,
+                 'verdict': verdict, 'rate': rate, 'threshold': threshold}
+    )
+
+    return {
+        'report_id': report_id,
+        'verdict': verdict,
+        'threshold': threshold,
+        'incremental_coverage_rate': rate,
+        'total_new_lines': total_new_lines,
+        'skip_marker': skip_marker,
+        'policy': policy,
+        'policy_reason': policy_reason,
+        'exceptions_applied': [
+            {'id': e['id'], 'path_pattern': e['path_pattern'],
+             'expires_at': e['expires_at']}
+            for e in exceptions_applied
+        ],
+        'base_commit': diff_result.get('base_commit', ''),
+        'base_branch': diff_result.get('base_branch', '')
+    }
@@ -0,0 +1,245 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+GitLab 集成模块
+将覆盖率门禁结果同步到 GitLab（commit status + MR 审批规则），
+使强制策略由平台统一下发，而不是每个项目手工配置
+"""
+
+import logging
+import os
+from typing import Dict, Optional
+
+import requests
+
+logger = logging.getLogger(__name__)
+
+# GitLab 配置（支持环境变量，适配私有化部署实例）
+GITLAB_URL = os.getenv('GITLAB_URL', 'https://gitlab.com').rstrip('/')
+GITLAB_TOKEN = os.getenv('GITLAB_TOKEN', '')
+
+# 平台在 GitLab 上的状态名称，projects 的保护分支可将其设为 required status
+GATE_STATUS_NAME = 'orbit/coverage-gate'
+# 平台管理的审批规则名称
+APPROVAL_RULE_NAME = 'Orbit Coverage Gate'
+
+REQUEST_TIMEOUT = 15
+
+
+def _headers() -> Dict[str, str]:
+    return {'PRIVATE-TOKEN': GITLAB_TOKEN}
+
+
+def _check_configured() -> None:
+    if not GITLAB_TOKEN:
+        raise RuntimeError('GITLAB_TOKEN not configured')
+    # 故障注入点（staging验证git host故障下的调用方兜底，默认空操作）
+    from manager import chaos_manager
+    chaos_manager.maybe_inject('githost')
+
+
+def publish_commit_status(project_id: str, commit: str, gate_result: Dict) -> Dict:
+    """
+    将门禁结果以 commit status 形式推送到 GitLab
+    项目将该状态设为保护分支的 required status 后即可实现强制
+
+    参数:
+        project_id: GitLab 项目 ID（数字或 URL 编码的 path）
+        commit: commit hash
+        gate_result: gate_manager.evaluate_gate 的返回结果
+    """
+    _check_configured()
+
+    verdict = gate_result.get('verdict', '')
+    # advisory/disabled 在 GitLab 上都算 success，只是描述不同
+    state = 'failed' if verdict == 'failed' else 'success'
+
+    rate = gate_result.get('incremental_coverage_rate')
+    threshold = gate_result.get('threshold', 0)
+    if rate is not None:
+        description = f'diff coverage {rate:.1f}% (threshold {threshold}%), verdict: {verdict}'
+    else:
+        description = f'verdict: {verdict}'
+
+    url = f'{GITLAB_URL}/api/v4/projects/{project_id}/statuses/{commit}'
+    response = requests.post(
+        url,
+        headers=_headers(),
+        json={
+            'state': state,
+            'name': GATE_STATUS_NAME,
+            'description': description[:255]
+        },
+        timeout=REQUEST_TIMEOUT
+    )
+
+    if response.status_code not in (200, 201):
+        raise RuntimeError(
+            f'Failed to publish commit status: HTTP {response.status_code}, {response.text[:200]}'
+        )
+
+    logger.info(
+        f"Published gate status to GitLab: project={project_id}, "
+        f"commit={commit}, state={state}"
+    )
+    return {'state': state, 'name': GATE_STATUS_NAME, 'description': description}
+
+
+def _find_approval_rule(project_id: str, mr_iid: int) -> Optional[Dict]:
+    """查找平台管理的MR审批规则（按名称匹配）"""
+    url = f'{GITLAB_URL}/api/v4/projects/{project_id}/merge_requests/{mr_iid}/approval_rules'
+    response = requests.get(url, headers=_headers(), timeout=REQUEST_TIMEOUT)
+    if response.status_code != 200:
+        raise RuntimeError(
+            f'Failed to list approval rules: HTTP {response.status_code}'
+        )
+    for rule in response.json():
+        if rule.get('name') == APPROVAL_RULE_NAME:
+            return rule
+    return None
+
+
+def ensure_approval_rule(project_id: str, mr_iid: int, gate_result: Dict) -> Dict:
+    """
+    根据门禁结果创建/更新 MR 审批规则
+    门禁失败时要求1个额外审批（阻塞合并），通过时将要求降为0
+
+    参数:
+        project_id: GitLab 项目 ID
+        mr_iid: MR 的 iid
+        gate_result: gate_manager.evaluate_gate 的返回结果
+    """
+    _check_configured()
+
+    verdict = gate_result.get('verdict', '')
+    approvals_required = 1 if verdict == 'failed' else 0
+
+    existing = _find_approval_rule(project_id, mr_iid)
+
+    if existing:
+        url = (
+            f'{GITLAB_URL}/api/v4/projects/{project_id}'
+            f'/merge_requests/{mr_iid}/approval_rules/{existing["id"]}'
+        )
+        response = requests.put(
+            url,
+            headers=_headers(),
+            json={
+                'name': APPROVAL_RULE_NAME,
+                'approvals_required': approvals_required
+            },
+            timeout=REQUEST_TIMEOUT
+        )
+    else:
+        url = f'{GITLAB_URL}/api/v4/projects/{project_id}/merge_requests/{mr_iid}/approval_rules'
+        response = requests.post(
+            url,
+            headers=_headers(),
+            json={
+                'name': APPROVAL_RULE_NAME,
+                'approvals_required': approvals_required
+            },
+            timeout=REQUEST_TIMEOUT
+        )
+
+    if response.status_code not in (200, 201):
+        raise RuntimeError(
+            f'Failed to upsert approval rule: HTTP {response.status_code}, {response.text[:200]}'
+        )
+
+    logger.info(
+        f"Ensured approval rule on GitLab: project={project_id}, mr={mr_iid}, "
+        f"approvals_required={approvals_required}"
+    )
+    return {
+        'name': APPROVAL_RULE_NAME,
+        'approvals_required': approvals_required,
+        'updated': existing is not None
+    }
+
+
+def create_issue(project_id: str, title: str, description: str,
+                 labels: str = '') -> Dict:
+    """
+    在项目下创建 issue（测试缺口跟踪等自动建单用）
+
+    参数:
+        project_id: GitLab 项目 ID
+        title: issue 标题
+        description: issue 描述（markdown）
+        labels: 逗号分隔的标签（可选）
+    """
+    _check_configured()
+
+    url = f'{GITLAB_URL}/api/v4/projects/{project_id}/issues'
+    response = requests.post(
+        url,
+        headers=_headers(),
+        json={'title': title, 'description': description, 'labels': labels},
+        timeout=REQUEST_TIMEOUT
+    )
+    if response.status_code != 201:
+        raise RuntimeError(
+            f'Failed to create issue: HTTP {response.status_code}, {response.text[:200]}'
+        )
+
+    issue_iid = response.json().get('iid')
+    logger.info(f"Created GitLab issue: project={project_id}, iid={issue_iid}")
+    return {'iid': issue_iid, 'web_url': response.json().get('web_url')}
+
+
+def close_issue(project_id: str, issue_iid: int) -> Dict:
+    """关闭项目下的 issue"""
+    _check_configured()
+
+    url = f'{GITLAB_URL}/api/v4/projects/{project_id}/issues/{issue_iid}'
+    response = requests.put(
+        url,
+        headers=_headers(),
+        json={'state_event': 'close'},
+        timeout=REQUEST_TIMEOUT
+    )
+    if response.status_code != 200:
+        raise RuntimeError(
+            f'Failed to close issue: HTTP {response.status_code}, {response.text[:200]}'
+        )
+
+    logger.info(f"Closed GitLab issue: project={project_id}, iid={issue_iid}")
+    return {'iid': issue_iid, 'state': response.json().get('state')}
+
+
+def post_mr_note(project_id: str, mr_iid: int, body: str) -> Dict:
+    """
+    在 MR 下追加一条评论（配额提醒等非阻塞通知用）
+
+    参数:
+        project_id: GitLab 项目 ID
+        mr_iid: MR 的 iid
+        body: 评论内容（markdown）
+    """
+    _check_configured()
+
+    url = f'{GITLAB_URL}/api/v4/projects/{project_id}/merge_requests/{mr_iid}/notes'
+    response = requests.post(
+        url,
+        headers=_headers(),
+        json={'body': body},
+        timeout=REQUEST_TIMEOUT
+    )
+    if response.status_code != 201:
+        raise RuntimeError(
+            f'Failed to post MR note: HTTP {response.status_code}, {response.text[:200]}'
+        )
+
+    logger.info(f"Posted MR note on GitLab: project={project_id}, mr={mr_iid}")
+
+    # 领域事件（回放日志，失败不影响主流程）
+    from manager import eventlog_manager
+    eventlog_manager.append_event(
+        eventlog_manager.EVENT_NOTIFICATION_SENT,
+        payload={'channel': 'gitlab_mr_note',
+                 'project_id': project_id, 'mr_iid': mr_iid}
+    )
+
+    return {'id': response.json().get('id'), 'mr_iid': mr_iid}
@@ -0,0 +1,172 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+覆盖率目标管理模块
+维护仓库级的阶段性覆盖率目标（OKR），进度对照快照趋势自动计算，
+替代人工维护的表格
+"""
+
+import logging
+import time
+from typing import Dict, List, Optional
+
+from models import CoverageGoal, CoverageSnapshot, get_db_session
+from manager import precision
+
+logger = logging.getLogger(__name__)
+
+GOAL_STATUS_ACTIVE = 'active'
+GOAL_STATUS_ACHIEVED = 'achieved'
+GOAL_STATUS_MISSED = 'missed'
+
+
+def _latest_snapshot(db, repo_id: str, branch: str) -> Optional[CoverageSnapshot]:
+    return db.query(CoverageSnapshot).filter(
+        CoverageSnapshot.repo_id == repo_id,
+        CoverageSnapshot.branch == branch
+    ).order_by(CoverageSnapshot.created_at.desc()).first()
+
+
+def create_goal(repo_id: str, target_rate: float, deadline: int,
+                branch: str = 'master', description: str = '',
+                created_by: str = '') -> Dict:
+    """
+    创建覆盖率目标
+
+    参数:
+        repo_id: 仓库唯一标识
+        target_rate: 目标覆盖率（百分比，如 80.0）
+        deadline: 截止时间（毫秒时间戳）
+        branch: 分支名（默认master）
+        description: 目标说明（如"Q3 OKR"）
+        created_by: 创建人
+    """
+    db = get_db_session()
+    try:
+        now = int(time.time() * 1000)
+
+        # 创建时记录基线覆盖率，用于计算应有进度
+        latest = _latest_snapshot(db, repo_id, branch)
+        baseline_rate = latest.coverage_rate if latest else 0
+
+        goal = CoverageGoal(
+            repo_id=repo_id,
+            branch=branch,
+            target_rate=int(target_rate * 100),
+            deadline=deadline,
+            description=description,
+            created_by=created_by,
+            baseline_rate=baseline_rate,
+            status=GOAL_STATUS_ACTIVE,
+            created_at=now,
+            updated_at=now
+        )
+        db.add(goal)
+        db.commit()
+        return goal.to_dict()
+    except Exception as e:
+        db.rollback()
+        raise
+    finally:
+        db.close()
+
+
+def get_goal_progress(goal: CoverageGoal, db) -> Dict:
+    """
+    计算目标进度
+
+    返回的字段:
+        current_rate: 当前覆盖率
+        expected_rate: 按基线到目标线性推进，此刻应达到的覆盖率
+        on_track: 当前是否不落后于应有进度
+        remaining_days: 距截止日剩余天数（可为负）
+    """
+    now = int(time.time() * 1000)
+
+    latest = _latest_snapshot(db, goal.repo_id, goal.branch)
+    current_rate = latest.coverage_rate / 100.0 if latest else 0.0
+    target_rate = goal.target_rate / 100.0
+    baseline_rate = goal.baseline_rate / 100.0
+
+    # 基线到截止日线性推进的期望进度
+    if goal.deadline > goal.created_at:
+        elapsed = min(max(now - goal.created_at, 0), goal.deadline - goal.created_at)
+        progress_ratio = elapsed / (goal.deadline - goal.created_at)
+    else:
+        progress_ratio = 1.0
+    expected_rate = baseline_rate + (target_rate - baseline_rate) * progress_ratio
+
+    return {
+        'current_rate': precision.apply_policy(current_rate),
+        'expected_rate': precision.apply_policy(expected_rate),
+        'on_track': current_rate >= expected_rate,
+        'remaining_days': (goal.deadline - now) // (24 * 3600 * 1000)
+    }
+
+
+def refresh_goal_status(goal: CoverageGoal, db) -> None:
+    """按当前覆盖率与截止时间刷新目标状态（原地修改，不提交）"""
+    if goal.status != GOAL_STATUS_ACTIVE:
+        return
+
+    now = int(time.time() * 1000)
+    latest = _latest_snapshot(db, goal.repo_id, goal.branch)
+    current = latest.coverage_rate if latest else 0
+
+    if current >= goal.target_rate:
+        goal.status = GOAL_STATUS_ACHIEVED
+        goal.updated_at = now
+    elif now > goal.deadline:
+        goal.status = GOAL_STATUS_MISSED
+        goal.updated_at = now
+
+
+def list_goals(repo_id: Optional[str] = None,
+               status: Optional[str] = None) -> List[Dict]:
+    """
+    查询目标列表（附带进度）
+    查询时会顺带刷新 achieved/missed 状态
+    """
+    db = get_db_session()
+    try:
+        query = db.query(CoverageGoal)
+        if repo_id:
+            query = query.filter(CoverageGoal.repo_id == repo_id)
+        goals = query.order_by(CoverageGoal.deadline.asc()).all()
+
+        for goal in goals:
+            refresh_goal_status(goal, db)
+        db.commit()
+
+        if status:
+            goals = [g for g in goals if g.status == status]
+
+        result = []
+        for goal in goals:
+            item = goal.to_dict()
+            item['progress'] = get_goal_progress(goal, db)
+            result.append(item)
+        return result
+    except Exception:
+        db.rollback()
+        raise
+    finally:
+        db.close()
+
+
+def delete_goal(goal_id: int) -> bool:
+    """删除目标"""
+    db = get_db_session()
+    try:
+        goal = db.query(CoverageGoal).filter(CoverageGoal.id == goal_id).first()
+        if not goal:
+            return False
+        db.delete(goal)
+        db.commit()
+        return True
+    except Exception:
+        db.rollback()
+        raise
+    finally:
+        db.close()
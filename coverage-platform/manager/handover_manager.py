@@ -0,0 +1,192 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+消费者交接协议管理模块
+基于数据库租约实现消费者滚动升级的协调交接：同一队列同一时刻只有一个
+消费者持有租约在拉消息；新消费者启动后先请求交接，旧消费者发现交接
+请求后停止拉取并排空在途消息再释放租约，新消费者接管——避免新旧版本
+并发处理同一积压
+
+协议流程:
+    1. 新消费者启动，尝试获取租约
+    2. 租约被存活的旧消费者持有时，写入handover_requested_by并轮询等待
+    3. 旧消费者心跳时发现交接请求，stop_consuming排空在途消息后释放租约
+    4. 新消费者获取租约，开始消费
+    旧消费者崩溃（心跳超过TTL未更新）时租约视为过期，可直接抢占
+
+环境变量:
+    CONSUMER_HANDOVER_ENABLED: 是否启用交接协议（默认关闭，
+        关闭时消费者行为与原来完全一致）
+    CONSUMER_LEASE_TTL_SECONDS: 租约TTL，心跳超时视为持有者已死（默认30）
+    CONSUMER_HANDOVER_POLL_SECONDS: 心跳/等待轮询间隔（默认5）
+    CONSUMER_HANDOVER_WAIT_SECONDS: 新消费者等待交接的超时（默认120）
+    CONSUMER_VERSION: 消费者版本标识，记录在租约上便于排查（可选）
+"""
+
+import logging
+import os
+import socket
+import time
+import uuid
+from typing import Optional
+
+from sqlalchemy.exc import IntegrityError
+
+from models import ConsumerLease, get_db_session
+
+logger = logging.getLogger(__name__)
+
+HANDOVER_ENABLED = os.environ.get(
+    'CONSUMER_HANDOVER_ENABLED', ''
+).lower() in ('1', 'true')
+LEASE_TTL_SECONDS = int(os.environ.get('CONSUMER_LEASE_TTL_SECONDS', '30'))
+POLL_SECONDS = int(os.environ.get('CONSUMER_HANDOVER_POLL_SECONDS', '5'))
+WAIT_SECONDS = int(os.environ.get('CONSUMER_HANDOVER_WAIT_SECONDS', '120'))
+CONSUMER_VERSION = os.environ.get('CONSUMER_VERSION', 'unknown')
+
+
+class HandoverTimeout(Exception):
+    """等待旧消费者交接超时"""
+    pass
+
+
+def make_holder_id() -> str:
+    """生成本进程的持有者标识（主机名-进程号-随机后缀）"""
+    return f'{socket.gethostname()}-{os.getpid()}-{uuid.uuid4().hex[:8]}'
+
+
+def _is_expired(lease: ConsumerLease, now: int) -> bool:
+    """心跳超过TTL未更新视为持有者已死"""
+    return now - lease.heartbeat_at > LEASE_TTL_SECONDS * 1000
+
+
+def _try_acquire(queue: str, holder: str) -> bool:
+    """
+    尝试获取一次租约
+
+    租约不存在或已过期时获取成功；被存活的持有者占用时写入
+    handover_requested_by（幂等）并返回 False
+    """
+    now = int(time.time() * 1000)
+    db = get_db_session()
+    try:
+        lease = db.query(ConsumerLease).filter(
+            ConsumerLease.queue == queue
+        ).first()
+
+        if lease and not _is_expired(lease, now):
+            # 持有者存活，登记交接请求后等下一轮
+            if lease.handover_requested_by != holder:
+                lease.handover_requested_by = holder
+                db.commit()
+                logger.info(
+                    f"Lease for {queue} held by {lease.holder} "
+                    f"(version {lease.version}), handover requested"
+                )
+            return False
+
+        if lease:
+            # 持有者已死，直接抢占
+            logger.warning(
+                f"Lease for {queue} held by dead consumer {lease.holder}, "
+                f"taking over"
+            )
+            lease.holder = holder
+            lease.version = CONSUMER_VERSION
+            lease.acquired_at = now
+            lease.heartbeat_at = now
+            lease.handover_requested_by = None
+        else:
+            lease = ConsumerLease(
+                queue=queue,
+                holder=holder,
+                version=CONSUMER_VERSION,
+                acquired_at=now,
+                heartbeat_at=now,
+                created_at=now
+            )
+            db.add(lease)
+
+        db.commit()
+        return True
+    except IntegrityError:
+        # 与另一个新消费者并发插入，让对方先持有
+        db.rollback()
+        return False
+    except Exception:
+        db.rollback()
+        raise
+    finally:
+        db.close()
+
+
+def acquire_lease(queue: str, holder: str) -> None:
+    """
+    阻塞获取队列租约
+
+    租约被存活的旧消费者持有时登记交接请求并轮询等待，
+    超过CONSUMER_HANDOVER_WAIT_SECONDS仍未交接则抛出异常
+
+    异常:
+        HandoverTimeout: 等待交接超时
+    """
+    deadline = time.time() + WAIT_SECONDS
+    while True:
+        if _try_acquire(queue, holder):
+            logger.info(f"Acquired consumer lease for {queue} as {holder}")
+            return
+        if time.time() >= deadline:
+            raise HandoverTimeout(
+                f'timed out waiting for handover of queue {queue} '
+                f'after {WAIT_SECONDS}s'
+            )
+        time.sleep(POLL_SECONDS)
+
+
+def heartbeat(queue: str, holder: str) -> bool:
+    """
+    刷新租约心跳
+
+    返回:
+        bool: True表示有新消费者请求交接，持有者应停止拉取、
+              排空在途消息后调用 release_lease
+    """
+    now = int(time.time() * 1000)
+    db = get_db_session()
+    try:
+        lease = db.query(ConsumerLease).filter(
+            ConsumerLease.queue == queue,
+            ConsumerLease.holder == holder
+        ).first()
+        if not lease:
+            # 租约被抢占（如本进程被误判为已死），保守起见也让出消费
+            logger.warning(f"Lease for {queue} no longer held by {holder}")
+            return True
+
+        lease.heartbeat_at = now
+        db.commit()
+        return bool(lease.handover_requested_by)
+    except Exception as e:
+        db.rollback()
+        logger.warning(f"Failed to heartbeat lease for {queue}: {e}")
+        return False
+    finally:
+        db.close()
+
+
+def release_lease(queue: str, holder: str) -> None:
+    """释放租约（删除记录，等待中的新消费者下一轮即可获取）"""
+    db = get_db_session()
+    try:
+        db.query(ConsumerLease).filter(
+            ConsumerLease.queue == queue,
+            ConsumerLease.holder == holder
+        ).delete()
+        db.commit()
+        logger.info(f"Released consumer lease for {queue}")
+    except Exception as e:
+        db.rollback()
+        logger.warning(f"Failed to release lease for {queue}: {e}")
+    finally:
+        db.close()
@@ -0,0 +1,155 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+平台事故归并模块
+解析bug或broker故障会让大量仓库在短时间内同时覆盖率回退，
+这里把回退事件做跨仓库关联：窗口内回退仓库数超过阈值时
+归并成一条平台级事故，只发一条平台告警而不是几百条仓库级告警
+
+当前的"告警"为日志输出与状态接口展示，接入通知渠道时直接在
+_alert_incident / _alert_repo 处对接即可
+
+环境变量:
+    REGRESSION_DROP_THRESHOLD: 触发回退事件的下降幅度（百分点，默认5）
+    INCIDENT_REPO_THRESHOLD: 归并为平台事故的窗口内仓库数（默认5）
+    INCIDENT_WINDOW_MS: 关联窗口（毫秒，默认10分钟）
+"""
+
+import logging
+import os
+import time
+from typing import Dict, List, Optional
+
+from models import RegressionEvent, PlatformIncident, get_db_session
+
+logger = logging.getLogger(__name__)
+
+# 触发回退事件的覆盖率下降幅度（百分点）
+REGRESSION_DROP_THRESHOLD = float(os.getenv('REGRESSION_DROP_THRESHOLD', '5'))
+
+# 窗口内回退仓库数达到该值时归并为平台事故
+INCIDENT_REPO_THRESHOLD = int(os.getenv('INCIDENT_REPO_THRESHOLD', '5'))
+
+# 跨仓库关联窗口（毫秒）
+INCIDENT_WINDOW_MS = int(os.getenv('INCIDENT_WINDOW_MS', str(10 * 60 * 1000)))
+
+
+def _alert_incident(incident: PlatformIncident, repo_ids: List[str]) -> None:
+    """平台级事故告警（单条，替代各仓库的独立告警）"""
+    logger.error(
+        f"[平台事故] incident_id={incident.id}, 窗口内{incident.repo_count}个仓库"
+        f"同时覆盖率回退, 疑似平台侧问题(解析bug/broker故障): "
+        f"repos={','.join(sorted(repo_ids)[:20])}"
+    )
+
+
+def _alert_repo(repo_id: str, branch: str, drop: float) -> None:
+    """仓库级回退告警（未归并到平台事故时）"""
+    logger.warning(
+        f"[覆盖率回退] repo_id={repo_id}, branch={branch}, 下降{drop:.2f}个百分点"
+    )
+
+
+def record_regression(repo_id: str, branch: str, drop: float) -> None:
+    """
+    记录一次覆盖率回退并做跨仓库关联
+    下降幅度未达阈值时不做任何事
+    任何异常只记日志，不影响报告处理主流程
+    """
+    if drop < REGRESSION_DROP_THRESHOLD:
+        return
+
+    db = get_db_session()
+    try:
+        now = int(time.time() * 1000)
+        event = RegressionEvent(
+            repo_id=repo_id,
+            branch=branch,
+            drop_rate=int(drop * 100),
+            created_at=now
+        )
+        db.add(event)
+        db.flush()
+
+        # 关联窗口内的回退事件
+        window_start = now - INCIDENT_WINDOW_MS
+        window_events = db.query(RegressionEvent).filter(
+            RegressionEvent.created_at >= window_start
+        ).all()
+        repo_ids = {e.repo_id for e in window_events}
+
+        if len(repo_ids) < INCIDENT_REPO_THRESHOLD:
+            db.commit()
+            _alert_repo(repo_id, branch, drop)
+            return
+
+        # 达到阈值：挂到活跃事故上，没有就创建一条
+        incident = db.query(PlatformIncident).filter(
+            PlatformIncident.status == 'active',
+            PlatformIncident.last_event_at >= window_start
+        ).first()
+        is_new = incident is None
+        if is_new:
+            incident = PlatformIncident(
+                status='active',
+                detail=f'{len(repo_ids)} repos regressed within '
+                       f'{INCIDENT_WINDOW_MS // 60000} minutes',
+                started_at=now,
+                last_event_at=now
+            )
+            db.add(incident)
+            db.flush()
+
+        incident.repo_count = len(repo_ids)
+        incident.last_event_at = now
+        for e in window_events:
+            if not e.incident_id:
+                e.incident_id = incident.id
+        db.commit()
+
+        # 只在事故创建时发一条平台级告警，后续事件静默归并
+        if is_new:
+            _alert_incident(incident, list(repo_ids))
+    except Exception as e:
+        db.rollback()
+        logger.warning(f"Failed to record regression event: {e}")
+
+
+def list_incidents(status: Optional[str] = None, limit: int = 20) -> List[Dict]:
+    """
+    列出平台事故（倒序），附带各事故归并的事件
+    超过关联窗口没有新事件的活跃事故自动标记为 resolved
+    """
+    db = get_db_session()
+    try:
+        now = int(time.time() * 1000)
+
+        # 自动解除过期的活跃事故
+        stale = db.query(PlatformIncident).filter(
+            PlatformIncident.status == 'active',
+            PlatformIncident.last_event_at < now - INCIDENT_WINDOW_MS
+        ).all()
+        for incident in stale:
+            incident.status = 'resolved'
+        if stale:
+            db.commit()
+
+        query = db.query(PlatformIncident)
+        if status:
+            query = query.filter(PlatformIncident.status == status)
+        incidents = query.order_by(
+            PlatformIncident.started_at.desc()
+        ).limit(limit).all()
+
+        results = []
+        for incident in incidents:
+            entry = incident.to_dict()
+            events = db.query(RegressionEvent).filter(
+                RegressionEvent.incident_id == incident.id
+            ).order_by(RegressionEvent.created_at).all()
+            entry['events'] = [e.to_dict() for e in events]
+            results.append(entry)
+        return results
+    finally:
+        db.close()
@@ -0,0 +1,132 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+语言识别与分语言覆盖率模块
+按扩展名识别文件语言（映射可通过环境变量扩展），
+为混合仓库（如 Go 服务 + React 前端）分别给出各语言的覆盖率，
+避免不同语言混在一个数字里失去意义
+
+环境变量:
+    LANGUAGE_EXT_MAP: 追加/覆盖扩展名映射，格式 ".ext=lang;.ext2=lang2"
+"""
+
+import logging
+import os
+from typing import Dict, Optional
+
+from models import CoverageFile, CoverageRange, CoverageConfig, get_db_session
+from manager.manager import should_exclude_file
+from manager import precision
+
+logger = logging.getLogger(__name__)
+
+# 默认扩展名到语言的映射
+DEFAULT_EXT_LANGUAGES = {
+    '.go': 'go',
+    '.py': 'python',
+    '.java': 'java',
+    '.kt': 'kotlin',
+    '.js': 'javascript',
+    '.jsx': 'javascript',
+    '.ts': 'typescript',
+    '.tsx': 'typescript',
+    '.vue': 'vue',
+    '.rb': 'ruby',
+    '.rs': 'rust',
+    '.c': 'c',
+    '.h': 'c',
+    '.cc': 'cpp',
+    '.cpp': 'cpp',
+    '.cs': 'csharp',
+    '.php': 'php',
+}
+
+
+def _load_ext_languages() -> Dict[str, str]:
+    """合并默认映射和环境变量配置的映射"""
+    mapping = dict(DEFAULT_EXT_LANGUAGES)
+    raw = os.getenv('LANGUAGE_EXT_MAP', '')
+    for pair in raw.split(';'):
+        pair = pair.strip()
+        if not pair or '=' not in pair:
+            continue
+        ext, lang = pair.split('=', 1)
+        ext = ext.strip()
+        if not ext.startswith('.'):
+            ext = '.' + ext
+        mapping[ext.lower()] = lang.strip().lower()
+    return mapping
+
+
+EXT_LANGUAGES = _load_ext_languages()
+
+
+def detect_language(file_path: str) -> str:
+    """按扩展名识别文件语言，未知扩展名返回 'other'"""
+    _, ext = os.path.splitext(file_path)
+    return EXT_LANGUAGES.get(ext.lower(), 'other')
+
+
+def get_language_breakdown(repo_id: str, branch: str) -> Optional[Dict]:
+    """
+    计算仓库分支的分语言覆盖率
+
+    返回:
+        Optional[Dict]: {
+            'repo_id', 'branch',
+            'languages': {
+                '<lang>': {'files', 'total_statements',
+                           'covered_statements', 'coverage_rate'}
+            }
+        }
+        无覆盖率数据时 languages 为空
+    """
+    db = get_db_session()
+    try:
+        config = db.query(CoverageConfig).filter(
+            CoverageConfig.repo_id == repo_id
+        ).first()
+        exclude_dirs = config.exclude_dirs if config else ''
+        exclude_files = config.exclude_files if config else ''
+
+        files = db.query(CoverageFile).filter(
+            CoverageFile.repo_id == repo_id,
+            CoverageFile.branch == branch
+        ).all()
+
+        languages = {}
+        for file in files:
+            if should_exclude_file(file.file_path, exclude_dirs, exclude_files):
+                continue
+
+            lang = detect_language(file.file_path)
+            entry = languages.setdefault(lang, {
+                'files': 0,
+                'total_statements': 0,
+                'covered_statements': 0
+            })
+            entry['files'] += 1
+
+            ranges = db.query(CoverageRange).filter(
+                CoverageRange.file_id == file.id
+            ).all()
+            for r in ranges:
+                entry['total_statements'] += r.statements
+                if r.hit > 0:
+                    entry['covered_statements'] += r.statements
+
+        for entry in languages.values():
+            if entry['total_statements'] > 0:
+                rate = entry['covered_statements'] / entry['total_statements'] * 100
+            else:
+                rate = 0.0
+            entry['coverage_rate'] = precision.apply_policy(rate)
+
+        return {
+            'repo_id': repo_id,
+            'branch': branch,
+            'languages': languages
+        }
+    finally:
+        db.close()
@@ -0,0 +1,240 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+LDAP/SCIM 目录同步模块
+定期从目录服务同步用户和团队成员关系，自动映射RBAC角色
+目录中消失的用户标记为未激活，不做物理删除
+"""
+
+import logging
+import os
+import sys
+import time
+from typing import Dict, List, Optional
+
+# 支持直接运行本模块: python manager/ldap_sync.py
+sys.path.insert(0, os.path.dirname(os.path.dirname(os.path.abspath(__file__))))
+
+from models import User, TeamMembership, get_db_session
+
+logger = logging.getLogger(__name__)
+
+# LDAP 配置（支持环境变量）
+LDAP_URL = os.getenv('LDAP_URL', '')  # 如 ldap://ldap.example.com:389
+LDAP_BIND_DN = os.getenv('LDAP_BIND_DN', '')
+LDAP_BIND_PASSWORD = os.getenv('LDAP_BIND_PASSWORD', '')
+LDAP_USER_BASE_DN = os.getenv('LDAP_USER_BASE_DN', '')
+LDAP_GROUP_BASE_DN = os.getenv('LDAP_GROUP_BASE_DN', '')
+LDAP_USER_FILTER = os.getenv('LDAP_USER_FILTER', '(objectClass=person)')
+LDAP_GROUP_FILTER = os.getenv('LDAP_GROUP_FILTER', '(objectClass=groupOfNames)')
+
+# LDAP 组到 RBAC 角色的映射（分号分隔的 group=role 对）
+# 例如: "orbit-admins=admin;qa-leads=maintainer"
+LDAP_ROLE_MAPPING = os.getenv('LDAP_ROLE_MAPPING', '')
+
+# 同步间隔（秒），用于 daemon 模式
+LDAP_SYNC_INTERVAL = int(os.getenv('LDAP_SYNC_INTERVAL', '3600'))
+
+DEFAULT_ROLE = 'viewer'
+
+
+def parse_role_mapping(raw: str) -> Dict[str, str]:
+    """
+    解析组到角色的映射配置
+    格式: "group1=role1;group2=role2"
+    """
+    mapping = {}
+    for pair in raw.split(';'):
+        pair = pair.strip()
+        if not pair or '=' not in pair:
+            continue
+        group, role = pair.split('=', 1)
+        mapping[group.strip()] = role.strip()
+    return mapping
+
+
+def fetch_directory_entries() -> Optional[Dict]:
+    """
+    从 LDAP 目录获取用户和组信息
+
+    返回:
+        Optional[Dict]: {'users': [...], 'groups': {group_name: [username, ...]}}
+        未配置或连接失败时返回 None
+    """
+    if not LDAP_URL:
+        logger.warning("LDAP_URL not configured, skipping directory sync")
+        return None
+
+    try:
+        import ldap3
+    except ImportError:
+        logger.error("ldap3 package not installed, cannot sync directory")
+        return None
+
+    try:
+        server = ldap3.Server(LDAP_URL, get_info=ldap3.NONE)
+        conn = ldap3.Connection(
+            server, user=LDAP_BIND_DN, password=LDAP_BIND_PASSWORD,
+            auto_bind=True, read_only=True
+        )
+
+        # 查询用户
+        users = []
+        conn.search(
+            LDAP_USER_BASE_DN, LDAP_USER_FILTER,
+            attributes=['uid', 'cn', 'mail']
+        )
+        for entry in conn.entries:
+            username = str(entry.uid) if 'uid' in entry else ''
+            if not username:
+                continue
+            users.append({
+                'username': username,
+                'display_name': str(entry.cn) if 'cn' in entry else '',
+                'email': str(entry.mail) if 'mail' in entry else ''
+            })
+
+        # 查询组及其成员
+        groups = {}
+        if LDAP_GROUP_BASE_DN:
+            conn.search(
+                LDAP_GROUP_BASE_DN, LDAP_GROUP_FILTER,
+                attributes=['cn', 'member']
+            )
+            for entry in conn.entries:
+                group_name = str(entry.cn) if 'cn' in entry else ''
+                if not group_name:
+                    continue
+                members = []
+                if 'member' in entry:
+                    for member_dn in entry.member:
+                        # 从 member DN 中提取 uid/cn，例如 uid=alice,ou=people,...
+                        first_rdn = str(member_dn).split(',')[0]
+                        if '=' in first_rdn:
+                            members.append(first_rdn.split('=', 1)[1])
+                groups[group_name] = members
+
+        conn.unbind()
+        return {'users': users, 'groups': groups}
+    except Exception as e:
+        logger.error(f"Failed to fetch directory entries: {e}")
+        return None
+
+
+def sync_directory() -> Optional[Dict]:
+    """
+    执行一次目录同步
+
+    返回:
+        Optional[Dict]: 同步结果统计（创建/更新/停用的用户数等），失败返回 None
+    """
+    entries = fetch_directory_entries()
+    if entries is None:
+        return None
+
+    role_mapping = parse_role_mapping(LDAP_ROLE_MAPPING)
+
+    # 根据组成员关系计算每个用户的角色：
+    # 按 LDAP_ROLE_MAPPING 的声明顺序遍历（即配置即优先级），
+    # 每个用户取第一个命中的组，结果与目录返回的组顺序无关；
+    # admin 例外——无论声明顺序，命中 admin 组就生效且不被覆盖
+    user_roles = {}
+    for group_name, role in role_mapping.items():
+        for username in entries['groups'].get(group_name, []):
+            if user_roles.get(username) == 'admin':
+                continue
+            if username not in user_roles or role == 'admin':
+                user_roles[username] = role
+
+    db = get_db_session()
+    try:
+        now = int(time.time() * 1000)
+        created = 0
+        updated = 0
+        deactivated = 0
+
+        directory_usernames = set()
+        for entry in entries['users']:
+            username = entry['username']
+            directory_usernames.add(username)
+            role = user_roles.get(username, DEFAULT_ROLE)
+
+            user = db.query(User).filter(User.username == username).first()
+            if user:
+                user.display_name = entry['display_name']
+                user.email = entry['email']
+                user.role = role
+                user.active = 1
+                user.updated_at = now
+                updated += 1
+            else:
+                user = User(
+                    username=username,
+                    display_name=entry['display_name'],
+                    email=entry['email'],
+                    role=role,
+                    source='ldap',
+                    active=1,
+                    created_at=now,
+                    updated_at=now
+                )
+                db.add(user)
+                created += 1
+
+        # 目录中不存在的 LDAP 用户标记为未激活
+        stale_users = db.query(User).filter(
+            User.source == 'ldap',
+            User.active == 1
+        ).all()
+        for user in stale_users:
+            if user.username not in directory_usernames:
+                user.active = 0
+                user.updated_at = now
+                deactivated += 1
+
+        # 团队成员关系全量重建（目录是唯一数据源）
+        db.query(TeamMembership).delete()
+        memberships = 0
+        for group_name, members in entries['groups'].items():
+            for username in members:
+                db.add(TeamMembership(
+                    team_name=group_name,
+                    username=username,
+                    created_at=now
+                ))
+                memberships += 1
+
+        db.commit()
+
+        result = {
+            'created': created,
+            'updated': updated,
+            'deactivated': deactivated,
+            'teams': len(entries['groups']),
+            'memberships': memberships
+        }
+        logger.info(f"Directory sync completed: {result}")
+        return result
+    except Exception as e:
+        logger.error(f"Failed to sync directory: {e}")
+        db.rollback()
+        return None
+    finally:
+        db.close()
+
+
+def main():
+    """daemon 模式：按固定间隔循环同步"""
+    logging.basicConfig(
+        level=logging.INFO,
+        format='%(asctime)s - %(name)s - %(levelname)s - %(message)s'
+    )
+    logger.info(f"Starting LDAP sync daemon, interval={LDAP_SYNC_INTERVAL}s")
+    while True:
+        sync_directory()
+        time.sleep(LDAP_SYNC_INTERVAL)
+
+
+if __name__ == '__main__':
+    main()
@@ -0,0 +1,100 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+报告血缘业务逻辑管理层
+回答"这个commit展示的数字是怎么来的"：哪些原始报告参与了、
+同commit合并过几次（多格式/分片/重试）、有多少语句是从分支
+已有数据沿用（carry-forward）而非本次测量的、时间戳是否被
+时钟信任策略修正过、有没有相关的审计事件——用户可以自助审计
+可疑的总数而不用提工单
+"""
+
+import logging
+from typing import Dict, Optional
+
+from models import (
+    CoverageReport, CoverageSnapshot, CoverageFileSnapshot, AuditLog,
+    get_db_session
+)
+
+logger = logging.getLogger(__name__)
+
+# 审计日志最多返回的条数
+LINEAGE_AUDIT_LIMIT = 50
+
+
+def get_report_lineage(report_id: int) -> Optional[Dict]:
+    """
+    获取报告的血缘信息
+
+    返回:
+        Optional[Dict]: {
+            'report': 本报告（原始输入：格式、CI流水线、metadata）,
+            'contributing_reports': 同repo/branch/commit的全部报告
+                （多格式合并、分片上报、重试都会产生多条，按时间升序）,
+            'snapshots': 该commit的全部快照（每次处理产生一条，
+                created_at为报告声明时间、received_at为平台收到时间，
+                两者差异大说明经过时钟信任策略修正或CI时钟漂移）,
+            'carry_forward': {
+                'measured_statements': 最新快照中本次实际测量的语句数,
+                'carried_statements': 从分支已有数据沿用的语句数
+                    （分片scope之外的文件，>0说明总数包含历史数据）,
+                'total_statements': 快照总语句数
+            },
+            'audit_events': 涉及该commit的审计事件（如provenance_rejected）
+        }
+        报告不存在返回 None
+    """
+    db = get_db_session()
+    try:
+        report = db.query(CoverageReport).filter(
+            CoverageReport.id == report_id
+        ).first()
+        if not report:
+            return None
+
+        contributing = db.query(CoverageReport).filter(
+            CoverageReport.repo_id == report.repo_id,
+            CoverageReport.branch == report.branch,
+            CoverageReport.commit == report.commit
+        ).order_by(CoverageReport.created_at.asc()).all()
+
+        snapshots = db.query(CoverageSnapshot).filter(
+            CoverageSnapshot.repo_id == report.repo_id,
+            CoverageSnapshot.branch == report.branch,
+            CoverageSnapshot.commit == report.commit
+        ).order_by(CoverageSnapshot.created_at.asc()).all()
+
+        # carry-forward拆解：快照总数 - 本commit文件级快照之和
+        # 即分片scope之外沿用分支已有数据计入的语句数
+        carry_forward = None
+        if snapshots:
+            latest = snapshots[-1]
+            file_snapshots = db.query(CoverageFileSnapshot).filter(
+                CoverageFileSnapshot.repo_id == report.repo_id,
+                CoverageFileSnapshot.branch == report.branch,
+                CoverageFileSnapshot.commit == report.commit,
+                CoverageFileSnapshot.created_at == latest.created_at
+            ).all()
+            measured = sum(f.total_statements for f in file_snapshots)
+            carry_forward = {
+                'measured_statements': measured,
+                'carried_statements': max(latest.total_statements - measured, 0),
+                'total_statements': latest.total_statements
+            }
+
+        audit_events = db.query(AuditLog).filter(
+            AuditLog.repo_id == report.repo_id,
+            AuditLog.detail.like(f'%{report.commit}%')
+        ).order_by(AuditLog.created_at.desc()).limit(LINEAGE_AUDIT_LIMIT).all()
+
+        return {
+            'report': report.to_dict(),
+            'contributing_reports': [r.to_dict() for r in contributing],
+            'snapshots': [s.to_dict() for s in snapshots],
+            'carry_forward': carry_forward,
+            'audit_events': [a.to_dict() for a in audit_events]
+        }
+    finally:
+        db.close()
@@ -6,17 +6,27 @@
 提供覆盖率报告处理、解析等业务逻辑
 """
 
+import base64
+import json
 import logging
 import os
-import time
 from typing import Dict, List, Optional
 from sqlalchemy import func
 
 from models import (
     CoverageReport, CoverageFile, CoverageRange, CoverageConfig,
-    get_db_session
+    CoverageSnapshot, CoverageFileSnapshot, AuditLog, get_db_session
 )
 from manager.repo_manager import clone_or_update_repo
+from manager import precision
+from manager.deadline import Deadline, DeadlineExceeded
+from manager import incident_manager
+from manager import provenance_manager
+from manager import migration_manager
+from manager import clock_policy
+from manager import chaos_manager
+from manager import determinism
+from manager import profiling_manager
 
 
 def extract_repo_name(repo_url):
@@ -86,6 +96,17 @@ def should_exclude_file(file_path, exclude_dirs, exclude_files):
 logger = logging.getLogger(__name__)
 
 
+def metadata_like_pattern(key: str, value: str) -> str:
+    """
+    构造按自定义metadata过滤的LIKE模式
+    metadata按JSON文本存储，用序列化片段做子串匹配（仅支持字符串值的精确匹配）
+    """
+    return '%{}: {}%'.format(
+        json.dumps(key, ensure_ascii=False),
+        json.dumps(str(value), ensure_ascii=False)
+    )
+
+
 # 消息结构
 class CoverageReportMessage:
     """覆盖率报告消息结构"""
@@ -96,8 +117,23 @@ class CoverageReportMessage:
         self.commit = data.get('commit', '')
         self.ci = data.get('ci', {})
         self.coverage = data.get('coverage', {})
+        # 多语言 monorepo：一条消息可携带多个不同格式的覆盖率载荷
+        # coverages 存在时优先生效，每项结构与 coverage 相同（{format, raw}）
+        self.coverages = data.get('coverages', [])
+        # 分片CI：声明本分片负责的路径前缀列表
+        # 非空时 scope 之外的文件视为"未测量"，快照沿用库中已有数据
+        self.scope = data.get('scope', [])
+        # 自定义key/value元数据（测试套件名、特性开关状态等）
+        # 原样存储，list/trend查询可按其过滤，平台不约束内容
+        self.metadata = data.get('metadata', {}) or {}
         self.timestamp = data.get('timestamp', 0)
 
+    def get_coverage_payloads(self) -> List[dict]:
+        """返回本条消息的所有覆盖率载荷（单载荷消息归一为单元素列表）"""
+        if self.coverages:
+            return self.coverages
+        return [self.coverage]
+
 
 def parse_goc_coverage(raw: str) -> Dict[str, List[Dict]]:
     """
@@ -186,17 +222,146 @@ def parse_pyca_coverage(raw: str) -> Dict[str, List[Dict]]:
     return parse_goc_coverage(raw)
 
 
-def process_coverage_report(msg: CoverageReportMessage) -> None:
+def parse_orbit_agg_coverage(raw: str) -> Dict[str, List[Dict]]:
+    """
+    解析orbit-agg格式的覆盖率数据（客户端预聚合格式）
+    由 orbit_upload.py 在 CI 侧解析原始报告后生成，只包含每个文件的行位图，
+    体积远小于原始报告，服务端解析开销也更低
+
+    orbit-agg格式（JSON）:
+    {
+      "files": {
+        "<file_path>": {
+          "tracked": "<base64 位图，置位表示该行是可覆盖行>",
+          "covered": "<base64 位图，置位表示该行已覆盖>"
+        }
+      }
+    }
+    位图第 n 位对应第 n+1 行（bit 0 = 第1行）
+
+    行位图还原为行级 ranges：连续且覆盖状态相同的可覆盖行合并为一个 range，
+    statements 取行数，列号为 0（与 pyca 的行级语义一致）
+    """
+    data = json.loads(raw)
+    if not isinstance(data, dict) or not isinstance(data.get('files', {}), dict):
+        raise ValueError('orbit-agg payload must be a JSON object with a "files" object')
+    result = {}
+
+    for file_path, info in data.get('files', {}).items():
+        if not isinstance(info, dict):
+            raise ValueError(f'invalid orbit-agg file entry: {file_path}')
+        try:
+            tracked = base64.b64decode(info.get('tracked', '') or '')
+            covered = base64.b64decode(info.get('covered', '') or '')
+        except (TypeError, ValueError) as e:
+            raise ValueError(f'invalid orbit-agg bitmap for {file_path}: {e}')
+
+        ranges = []
+        run_start = 0
+        run_covered = False
+        prev_line = 0
+        for line in range(1, len(tracked) * 8 + 1):
+            byte_idx, bit_idx = (line - 1) // 8, (line - 1) % 8
+            if not tracked[byte_idx] & (1 << bit_idx):
+                continue
+            is_covered = (
+                byte_idx < len(covered) and bool(covered[byte_idx] & (1 << bit_idx))
+            )
+            if run_start and line == prev_line + 1 and is_covered == run_covered:
+                prev_line = line
+                continue
+            if run_start:
+                ranges.append({
+                    'start_line': run_start, 'start_col': 0,
+                    'end_line': prev_line, 'end_col': 0,
+                    'statements': prev_line - run_start + 1,
+                    'hit': 1 if run_covered else 0
+                })
+            run_start = line
+            run_covered = is_covered
+            prev_line = line
+        if run_start:
+            ranges.append({
+                'start_line': run_start, 'start_col': 0,
+                'end_line': prev_line, 'end_col': 0,
+                'statements': prev_line - run_start + 1,
+                'hit': 1 if run_covered else 0
+            })
+
+        if ranges:
+            result[file_path] = ranges
+
+    return result
+
+
+# 支持的覆盖率格式（pca 为 pyca 的历史别名，orbit-agg 为客户端预聚合格式）
+SUPPORTED_COVERAGE_FORMATS = ['goc', 'pyca', 'pca', 'jacoco', 'orbit-agg']
+
+
+def parse_coverage_payload(coverage_format: str, raw: str) -> Dict[str, List[Dict]]:
+    """
+    按格式分发到对应的解析器
+
+    参数:
+        coverage_format: 覆盖率格式（goc/pyca/jacoco）
+        raw: 原始覆盖率数据
+
+    返回:
+        Dict[str, List[Dict]]: 文件路径 -> ranges 列表
+
+    不支持的格式抛出 ValueError
+    """
+    if coverage_format == 'goc':
+        return parse_goc_coverage(raw)
+    elif coverage_format == 'pyca' or coverage_format == 'pca':  # 支持向后兼容
+        return parse_pyca_coverage(raw)
+    elif coverage_format == 'jacoco':
+        # jacoco格式与goc格式相同，直接复用goc的解析逻辑
+        return parse_goc_coverage(raw)
+    elif coverage_format == 'orbit-agg':
+        return parse_orbit_agg_coverage(raw)
+    else:
+        raise ValueError(f"unsupported coverage format: {coverage_format}")
+
+
+def merge_file_coverage(merged: Dict[str, List[Dict]],
+                        file_coverage: Dict[str, List[Dict]]) -> None:
+    """
+    将一个载荷的解析结果合并进汇总结果（原地修改 merged）
+    不同语言的载荷覆盖不同文件；同一文件出现在多个载荷时 ranges 追加
+    """
+    for file_path, ranges in file_coverage.items():
+        if file_path in merged:
+            merged[file_path].extend(ranges)
+        else:
+            merged[file_path] = ranges
+
+
+def process_coverage_report(msg: CoverageReportMessage,
+                            processing_deadline: Optional[Deadline] = None) -> None:
     """
     处理覆盖率报告
     将消息解析并存储到数据库
     对于repo_id+branch相同的数据，更新而不是插入新记录
     对于coverage_files，repo_id+branch+file_path相同的数据，更新updated_at而不是插入
+    processing_deadline 非空时在各阶段边界检查处理预算，
+    超出预算抛出 DeadlineExceeded 并把报告标记为 failed
     """
+    def check_deadline(stage):
+        if processing_deadline:
+            processing_deadline.check(stage)
+
+    # 故障注入点（staging验证消费侧重试/丢弃兜底，默认空操作）
+    chaos_manager.maybe_inject('storage')
+
+    # 各阶段耗时剖析（处理成功后落库，慢仓库排查用）
+    timing = profiling_manager.StageTimer()
+    timing.enter('prepare')
+
     db = get_db_session()
     try:
         # 开始事务
-        now = int(time.time() * 1000)  # 毫秒时间戳
+        now = determinism.now_ms()  # 毫秒时间戳
         
         # 检查仓库是否在配置表中
         config = db.query(CoverageConfig).filter(
@@ -210,11 +375,21 @@ def process_coverage_report(msg: CoverageReportMessage) -> None:
             )
             # 不存储覆盖率数据
             return
+
+        # 多载荷消息的 format 按分号拼接、raw 按换行拼接后存储
+        payloads = msg.get_coverage_payloads()
+        combined_format = ';'.join(p.get('format', 'goc') for p in payloads)
+        combined_raw = '\n'.join(p.get('raw', '') for p in payloads)
         
         # 从配置表获取repo_name
         repo_name = config.repo_name
         logger.info(f"Found repo in config: repo_id={msg.repo_id}, repo_name={repo_name}")
-        
+
+        # 自定义元数据原样序列化存储（报告和快照各存一份，趋势过滤用）
+        metadata_json = (
+            json.dumps(msg.metadata, ensure_ascii=False) if msg.metadata else None
+        )
+
         # 检查是否存在相同repo_id+branch的报告
         existing_report = db.query(CoverageReport).filter(
             CoverageReport.repo_id == msg.repo_id,
@@ -233,8 +408,9 @@ def process_coverage_report(msg: CoverageReportMessage) -> None:
             report.ci_provider = msg.ci.get('provider', '')
             report.ci_pipeline_id = msg.ci.get('pipeline_id', '')
             report.ci_job_id = msg.ci.get('job_id', '')
-            report.coverage_format = msg.coverage.get('format', 'goc')
-            report.coverage_raw = msg.coverage.get('raw', '')
+            report.coverage_format = combined_format
+            report.coverage_raw = combined_raw
+            report.report_metadata = metadata_json
             report.status = 'processing'
             report.updated_at = now
             # created_at保持不变
@@ -261,8 +437,9 @@ def process_coverage_report(msg: CoverageReportMessage) -> None:
                 ci_provider=msg.ci.get('provider', ''),
                 ci_pipeline_id=msg.ci.get('pipeline_id', ''),
                 ci_job_id=msg.ci.get('job_id', ''),
-                coverage_format=msg.coverage.get('format', 'goc'),
-                coverage_raw=msg.coverage.get('raw', ''),
+                coverage_format=combined_format,
+                coverage_raw=combined_raw,
+                report_metadata=metadata_json,
                 status='processing',
                 created_at=now,
                 updated_at=now
@@ -272,50 +449,33 @@ def process_coverage_report(msg: CoverageReportMessage) -> None:
         db.flush()  # 获取ID
         
         # 解析覆盖率数据
-        coverage_format = msg.coverage.get('format', 'goc')
-        if coverage_format == 'goc':
-            try:
-                file_coverage = parse_goc_coverage(msg.coverage.get('raw', ''))
-            except Exception as e:
-                update_now = int(time.time() * 1000)
-                report.status = 'failed'
-                report.error_message = str(e)
-                report.updated_at = update_now
-                db.commit()
-                raise
-        elif coverage_format == 'pyca' or coverage_format == 'pca':  # 支持向后兼容
-            try:
-                file_coverage = parse_pyca_coverage(msg.coverage.get('raw', ''))
-            except Exception as e:
-                update_now = int(time.time() * 1000)
-                report.status = 'failed'
-                report.error_message = str(e)
-                report.updated_at = update_now
-                db.commit()
-                raise
-        elif coverage_format == 'jacoco':
+        # 多载荷消息（monorepo 多语言）逐个解析后合并为一个 commit 视图
+        timing.enter('parse')
+        file_coverage = {}
+        for payload in msg.get_coverage_payloads():
+            check_deadline('parse')
+            coverage_format = payload.get('format', 'goc')
             try:
-                # jacoco格式与goc格式相同，直接复用goc的解析逻辑
-                file_coverage = parse_goc_coverage(msg.coverage.get('raw', ''))
+                payload_coverage = parse_coverage_payload(
+                    coverage_format, payload.get('raw', '')
+                )
             except Exception as e:
-                update_now = int(time.time() * 1000)
+                update_now = determinism.now_ms()
                 report.status = 'failed'
                 report.error_message = str(e)
                 report.updated_at = update_now
                 db.commit()
                 raise
-        else:
-            err_msg = f"unsupported coverage format: {coverage_format}"
-            update_now = int(time.time() * 1000)
-            report.status = 'failed'
-            report.error_message = err_msg
-            report.updated_at = update_now
-            db.commit()
-            raise ValueError(err_msg)
+            merge_file_coverage(file_coverage, payload_coverage)
         
         # 存储文件和数据
+        timing.enter('store')
         # 在开始处理文件前获取时间戳，用于ranges的created_at
-        ranges_timestamp = int(time.time() * 1000)  # 毫秒时间戳
+        # 消息携带timestamp时按时钟信任策略决定是否采用
+        # （历史回填需要保留原始时间，但CI时钟漂移不能扭曲趋势图）
+        received_at = determinism.now_ms()  # 毫秒时间戳
+        reported_ms = int(msg.timestamp) * 1000 if msg.timestamp else 0  # 秒转毫秒
+        ranges_timestamp = clock_policy.effective_timestamp(reported_ms, received_at)
         
         # 获取当前repo_id+branch下的所有文件，用于后续清理不再存在的文件
         existing_files = db.query(CoverageFile).filter(
@@ -324,15 +484,19 @@ def process_coverage_report(msg: CoverageReportMessage) -> None:
         ).all()
         existing_file_paths = {f.file_path: f for f in existing_files}
         processed_file_paths = set()
-        
+        stored_files = []  # 本次报告涉及的文件对象（复杂度计算用）
+
         for file_path, ranges in file_coverage.items():
+            check_deadline('store')
             processed_file_paths.add(file_path)
-            
+
             # 检查是否存在相同repo_id+branch+file_path的文件
             if file_path in existing_file_paths:
                 # 更新现有文件
                 file = existing_file_paths[file_path]
                 file.updated_at = ranges_timestamp
+                # 有新的行级数据进来，降采样标记作废
+                file.downsampled = 0
                 # created_at保持不变
                 logger.info(f"Updating existing file: {file_path}")
             else:
@@ -348,7 +512,8 @@ def process_coverage_report(msg: CoverageReportMessage) -> None:
                 logger.info(f"Creating new file: {file_path}")
             
             db.flush()  # 获取ID
-            
+            stored_files.append(file)
+
             # 删除该文件的所有旧ranges
             db.query(CoverageRange).filter(
                 CoverageRange.file_id == file.id
@@ -372,6 +537,8 @@ def process_coverage_report(msg: CoverageReportMessage) -> None:
         # 这里我们选择保留旧文件，只更新存在的文件
         
         # 拉取代码仓库（异步或同步，这里选择同步但可以优化为异步）
+        check_deadline('git')
+        timing.enter('git')
         repo_cloned = False
         try:
             logger.info(f"Cloning/updating repository: repo={msg.repo}, repo_id={msg.repo_id}, branch={msg.branch}, commit={msg.commit}")
@@ -386,6 +553,7 @@ def process_coverage_report(msg: CoverageReportMessage) -> None:
             # 代码拉取失败不影响覆盖率数据的存储
         
         # 如果仓库拉取成功且 base_commit 为空，尝试计算 base_commit
+        check_deadline('git')
         if repo_cloned and (not report.base_commit or report.base_commit == ''):
             try:
                 from manager.diff_manager import get_base_commit_from_git
@@ -439,19 +607,200 @@ def process_coverage_report(msg: CoverageReportMessage) -> None:
                 logger.warning(f"Failed to ensure base commit code: {e}, base_commit={report.base_commit}")
                 # base_commit 代码拉取失败不影响主流程
         
+        # 记录base_commit到本次commit之间的文件重命名（历史回溯用）
+        # 依赖bare仓库，放在拉取代码之后；失败不影响主流程
+        if repo_cloned and report.base_commit:
+            try:
+                from manager import rename_manager
+                rename_manager.record_renames(
+                    db, msg.repo_id, msg.branch, msg.repo,
+                    report.base_commit, msg.commit
+                )
+            except Exception as e:
+                logger.warning(f"Failed to record file renames: {e}")
+
+        # 入库时为本次报告涉及的Go文件估算圈复杂度（四象限数据用）
+        # 依赖源码，放在仓库拉取之后；失败不影响主流程
+        timing.enter('complexity')
+        if repo_cloned:
+            try:
+                from manager import complexity_manager
+                complexity_manager.update_files_complexity(
+                    db, msg.repo, msg.commit, stored_files
+                )
+            except Exception as e:
+                logger.warning(f"Failed to update file complexity: {e}")
+
+        # 基准分支报告的来源校验（可选，合规报表数据硬化）
+        # 放在拉取代码之后执行，签名检查依赖 commit 已存在于 bare 仓库
+        if provenance_manager.is_enabled() and msg.branch == (config.base_branch or 'master'):
+            verification = provenance_manager.verify_report(msg.repo, msg.commit, msg.ci)
+            if not verification['verified']:
+                reasons = '; '.join(verification['failures'])
+                logger.warning(
+                    f"Provenance verification failed, rejecting report: "
+                    f"repo_id={msg.repo_id}, commit={msg.commit}, {reasons}"
+                )
+                update_now = determinism.now_ms()
+                db.add(AuditLog(
+                    action='provenance_rejected',
+                    repo_id=msg.repo_id,
+                    detail=f'branch={msg.branch}, commit={msg.commit}, {reasons}',
+                    created_at=update_now
+                ))
+                report.status = 'failed'
+                report.error_message = f'provenance verification failed: {reasons}'
+                report.updated_at = update_now
+                db.commit()
+                return
+
         # 更新报告状态为完成
         # 使用ranges的时间戳，确保coverage_reports.updated_at和coverage_ranges.created_at一致
         # 这样即使中间有耗时操作（如拉取代码），时间戳也能保持一致
         report.status = 'completed'
         report.updated_at = ranges_timestamp
-        
+
+        # 记录覆盖率快照（报告是原地更新的，趋势数据只能从快照表查询）
+        # 直接从本次解析的数据计算，避免重新查询数据库
+        check_deadline('snapshot')
+        timing.enter('snapshot')
+        regression_drop = None
+        try:
+            # 取上一次快照，用于提交后做回退检测
+            previous_snapshot = db.query(CoverageSnapshot).filter(
+                CoverageSnapshot.repo_id == report.repo_id,
+                CoverageSnapshot.branch == report.branch
+            ).order_by(CoverageSnapshot.created_at.desc()).first()
+
+            snapshot_total = 0
+            snapshot_covered = 0
+            for file_path, ranges in file_coverage.items():
+                if should_exclude_file(file_path, config.exclude_dirs, config.exclude_files):
+                    continue
+                file_total = 0
+                file_covered = 0
+                for r in ranges:
+                    file_total += r['statements']
+                    if r['hit'] > 0:
+                        file_covered += r['statements']
+                snapshot_total += file_total
+                snapshot_covered += file_covered
+
+                # 文件级快照（文件历史查询用）
+                file_rate = (file_covered / file_total * 100) if file_total > 0 else 0.0
+                db.add(CoverageFileSnapshot(
+                    repo_id=report.repo_id,
+                    branch=report.branch,
+                    commit=report.commit,
+                    file_path=file_path,
+                    total_statements=file_total,
+                    covered_statements=file_covered,
+                    coverage_rate=int(file_rate * 100),
+                    created_at=ranges_timestamp
+                ))
+
+            # 分片报告：scope之外的文件是"未测量"而不是0%
+            # 沿用库中已有的覆盖率数据计入快照，避免分片CI造成的覆盖率假性下降
+            if msg.scope:
+                for file in existing_files:
+                    if file.file_path in processed_file_paths:
+                        continue
+                    if should_exclude_file(file.file_path, config.exclude_dirs,
+                                           config.exclude_files):
+                        continue
+                    file_total = 0
+                    file_covered = 0
+                    for r in db.query(CoverageRange).filter(
+                        CoverageRange.file_id == file.id
+                    ).all():
+                        file_total += r.statements
+                        if r.hit > 0:
+                            file_covered += r.statements
+                    if file_total == 0:
+                        continue
+                    snapshot_total += file_total
+                    snapshot_covered += file_covered
+
+            snapshot_rate = (snapshot_covered / snapshot_total * 100) if snapshot_total > 0 else 0.0
+            snapshot = CoverageSnapshot(
+                repo_id=report.repo_id,
+                branch=report.branch,
+                commit=report.commit,
+                total_statements=snapshot_total,
+                covered_statements=snapshot_covered,
+                coverage_rate=int(snapshot_rate * 100),
+                created_at=ranges_timestamp,
+                received_at=received_at,
+                report_metadata=metadata_json
+            )
+            db.add(snapshot)
+
+            if previous_snapshot:
+                regression_drop = (
+                    previous_snapshot.coverage_rate - int(snapshot_rate * 100)
+                ) / 100.0
+        except Exception as e:
+            logger.warning(f"Failed to record coverage snapshot: {e}")
+            # 快照记录失败不影响主流程
+
         # 提交事务
+        timing.enter('commit')
         db.commit()
-        
+
         logger.info(
             f"Successfully processed coverage report: repo={msg.repo}, "
             f"repo_id={msg.repo_id}, branch={msg.branch}, commit={msg.commit}"
         )
+
+        # 耗时落库（独立事务，失败不影响主流程）
+        profiling_manager.record_timing(
+            msg.repo_id, msg.branch, msg.commit, timing
+        )
+
+        # 领域事件（回放日志，失败不影响主流程）
+        from manager import eventlog_manager
+        eventlog_manager.append_event(
+            eventlog_manager.EVENT_REPORT_INGESTED,
+            repo_id=msg.repo_id,
+            payload={'branch': msg.branch, 'commit': msg.commit,
+                     'files': len(file_coverage)}
+        )
+        if msg.scope or len(payloads) > 1:
+            # 分片/多载荷报告合并进了分支视图
+            eventlog_manager.append_event(
+                eventlog_manager.EVENT_COVERAGE_MERGED,
+                repo_id=msg.repo_id,
+                payload={'branch': msg.branch, 'commit': msg.commit,
+                         'scope': msg.scope or '',
+                         'payloads': len(payloads)}
+            )
+
+        # 回退检测放在主事务提交之后（事件记录走自己的事务）
+        if regression_drop is not None and regression_drop > 0:
+            incident_manager.record_regression(
+                msg.repo_id, msg.branch, regression_drop
+            )
+
+        # 存储迁移双写：主库提交后把该分支镜像到目标库（失败只记日志）
+        if migration_manager.dual_write_enabled():
+            migration_manager.mirror_branch(msg.repo_id, msg.branch)
+    except DeadlineExceeded as e:
+        db.rollback()
+        # 超时原因要落到报告上，API侧才能看到失败原因
+        try:
+            timed_out_report = db.query(CoverageReport).filter(
+                CoverageReport.repo_id == msg.repo_id,
+                CoverageReport.branch == msg.branch
+            ).first()
+            if timed_out_report:
+                timed_out_report.status = 'failed'
+                timed_out_report.error_message = str(e)
+                timed_out_report.updated_at = determinism.now_ms()
+                db.commit()
+        except Exception as mark_err:
+            db.rollback()
+            logger.warning(f"Failed to mark timed out report: {mark_err}")
+        raise
     except Exception as e:
         db.rollback()
         raise
@@ -514,12 +863,12 @@ def get_report_statistics(report: CoverageReport) -> Dict:
         coverage_rate = 0.0
         if total_statements > 0:
             coverage_rate = (covered_statements / total_statements) * 100
-        
+
         return {
             'total_files': total_files,
             'total_statements': total_statements,
             'covered_statements': covered_statements,
-            'coverage_rate': coverage_rate
+            'coverage_rate': precision.apply_policy(coverage_rate)
         }
     finally:
         db.close()
@@ -549,6 +898,27 @@ def get_file_summary(file: CoverageFile) -> Optional[Dict]:
             logger.debug(f"Excluding file from summary: {file.file_path}")
             return None
         
+        # 行级数据被降采样删除的文件：从最近一次文件级快照取摘要
+        if file.downsampled:
+            snapshot = db.query(CoverageFileSnapshot).filter(
+                CoverageFileSnapshot.repo_id == file.repo_id,
+                CoverageFileSnapshot.branch == file.branch,
+                CoverageFileSnapshot.file_path == file.file_path
+            ).order_by(CoverageFileSnapshot.created_at.desc()).first()
+            return {
+                'id': file.id,
+                'repo_id': file.repo_id,
+                'branch': file.branch,
+                'file': file.file_path,
+                'total_statements': snapshot.total_statements if snapshot else 0,
+                'covered_statements': snapshot.covered_statements if snapshot else 0,
+                'coverage_rate': precision.apply_policy(
+                    snapshot.coverage_rate / 100.0 if snapshot else 0.0
+                ),
+                'downsampled': True,
+                'created_at': file.created_at
+            }
+
         # 查询所有 ranges
         all_ranges = db.query(CoverageRange).filter(
             CoverageRange.file_id == file.id
@@ -577,7 +947,7 @@ def get_file_summary(file: CoverageFile) -> Optional[Dict]:
             'file': file.file_path,
             'total_statements': total_statements,
             'covered_statements': covered_statements,
-            'coverage_rate': coverage_rate,
+            'coverage_rate': precision.apply_policy(coverage_rate),
             'created_at': file.created_at
         }
     finally:
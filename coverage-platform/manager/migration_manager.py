@@ -0,0 +1,253 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+存储后端迁移模块
+支持向新存储后端（如 Postgres）双写与校验/回填，
+实现不停机的存储迁移：
+  1. 配置 MIGRATION_TARGET_DSN 并开启 MIGRATION_DUAL_WRITE，
+     consumer 每处理完一条报告就把该 repo+branch 的数据镜像到目标库
+  2. 用 storage_migration.py backfill 回填历史数据
+  3. 用 storage_migration.py verify 对比两边的行数与语句数，确认一致后切换
+
+镜像以 repo+branch 为单位做"删除后重插"，保证目标库与源库最终一致；
+双写失败只记日志，绝不影响源库的正常写入
+
+环境变量:
+    MIGRATION_TARGET_DSN: 目标库 DSN（如 postgresql+psycopg2://...）
+    MIGRATION_DUAL_WRITE: 设为 1 开启处理后的自动镜像
+"""
+
+import logging
+import os
+from typing import Dict, List, Optional
+
+from sqlalchemy import create_engine, func
+from sqlalchemy.orm import sessionmaker
+
+from models import (
+    Base, CoverageReport, CoverageFile, CoverageRange, CoverageConfig,
+    CoverageSnapshot, CoverageFileSnapshot, get_db_session
+)
+
+logger = logging.getLogger(__name__)
+
+MIGRATION_TARGET_DSN = os.getenv('MIGRATION_TARGET_DSN', '')
+MIGRATION_DUAL_WRITE = os.getenv('MIGRATION_DUAL_WRITE', '0') == '1'
+
+_target_engine = None
+_TargetSession = None
+
+
+def dual_write_enabled() -> bool:
+    """是否开启双写"""
+    return MIGRATION_DUAL_WRITE and bool(MIGRATION_TARGET_DSN)
+
+
+def get_target_session():
+    """获取目标库会话（首次调用时建表），未配置目标库返回 None"""
+    global _target_engine, _TargetSession
+    if not MIGRATION_TARGET_DSN:
+        return None
+    if _TargetSession is None:
+        _target_engine = create_engine(
+            MIGRATION_TARGET_DSN, pool_pre_ping=True, echo=False
+        )
+        Base.metadata.create_all(bind=_target_engine)
+        _TargetSession = sessionmaker(bind=_target_engine)
+    return _TargetSession()
+
+
+def _clone(obj):
+    """按列复制一条记录（保留主键，镜像库与源库同构）"""
+    data = {c.name: getattr(obj, c.name) for c in obj.__table__.columns}
+    return type(obj)(**data)
+
+
+def _delete_branch_data(tgt, repo_id: str, branch: str) -> None:
+    """删除目标库中该 repo+branch 的全部覆盖率数据"""
+    file_ids = [
+        f.id for f in tgt.query(CoverageFile).filter(
+            CoverageFile.repo_id == repo_id,
+            CoverageFile.branch == branch
+        ).all()
+    ]
+    if file_ids:
+        tgt.query(CoverageRange).filter(
+            CoverageRange.file_id.in_(file_ids)
+        ).delete(synchronize_session=False)
+    tgt.query(CoverageFile).filter(
+        CoverageFile.repo_id == repo_id,
+        CoverageFile.branch == branch
+    ).delete(synchronize_session=False)
+    tgt.query(CoverageReport).filter(
+        CoverageReport.repo_id == repo_id,
+        CoverageReport.branch == branch
+    ).delete(synchronize_session=False)
+    tgt.query(CoverageSnapshot).filter(
+        CoverageSnapshot.repo_id == repo_id,
+        CoverageSnapshot.branch == branch
+    ).delete(synchronize_session=False)
+    tgt.query(CoverageFileSnapshot).filter(
+        CoverageFileSnapshot.repo_id == repo_id,
+        CoverageFileSnapshot.branch == branch
+    ).delete(synchronize_session=False)
+
+
+def mirror_branch(repo_id: str, branch: str) -> bool:
+    """
+    把 repo+branch 的覆盖率数据镜像到目标库（删除后重插）
+    失败只记日志并返回 False，不向调用方抛出
+    """
+    tgt = get_target_session()
+    if tgt is None:
+        return False
+
+    src = get_db_session()
+    try:
+        _delete_branch_data(tgt, repo_id, branch)
+
+        for report in src.query(CoverageReport).filter(
+            CoverageReport.repo_id == repo_id,
+            CoverageReport.branch == branch
+        ).all():
+            tgt.add(_clone(report))
+
+        files = src.query(CoverageFile).filter(
+            CoverageFile.repo_id == repo_id,
+            CoverageFile.branch == branch
+        ).all()
+        for file in files:
+            tgt.add(_clone(file))
+            for r in src.query(CoverageRange).filter(
+                CoverageRange.file_id == file.id
+            ).all():
+                tgt.add(_clone(r))
+
+        for snapshot in src.query(CoverageSnapshot).filter(
+            CoverageSnapshot.repo_id == repo_id,
+            CoverageSnapshot.branch == branch
+        ).all():
+            tgt.add(_clone(snapshot))
+        for snapshot in src.query(CoverageFileSnapshot).filter(
+            CoverageFileSnapshot.repo_id == repo_id,
+            CoverageFileSnapshot.branch == branch
+        ).all():
+            tgt.add(_clone(snapshot))
+
+        # 配置表顺带镜像（行数少，保持目标库可独立服务）
+        config = src.query(CoverageConfig).filter(
+            CoverageConfig.repo_id == repo_id
+        ).first()
+        if config:
+            tgt.query(CoverageConfig).filter(
+                CoverageConfig.repo_id == repo_id
+            ).delete(synchronize_session=False)
+            tgt.add(_clone(config))
+
+        tgt.commit()
+        logger.info(f"Mirrored branch to target store: repo_id={repo_id}, branch={branch}")
+        return True
+    except Exception as e:
+        tgt.rollback()
+        logger.warning(
+            f"Failed to mirror branch to target store: repo_id={repo_id}, "
+            f"branch={branch}, error={e}"
+        )
+        return False
+    finally:
+        tgt.close()
+
+
+def _list_branches(repo_id: Optional[str] = None) -> List:
+    """列出源库中的全部 (repo_id, branch)"""
+    src = get_db_session()
+    try:
+        query = src.query(
+            CoverageReport.repo_id, CoverageReport.branch
+        ).distinct()
+        if repo_id:
+            query = query.filter(CoverageReport.repo_id == repo_id)
+        return query.all()
+    finally:
+        src.close()
+
+
+def backfill(repo_id: Optional[str] = None) -> Dict:
+    """
+    回填历史数据到目标库
+
+    返回:
+        Dict: {'total': 分支数, 'mirrored': 成功数, 'failed': [(repo_id, branch)]}
+    """
+    branches = _list_branches(repo_id)
+    mirrored = 0
+    failed = []
+    for rid, branch in branches:
+        if mirror_branch(rid, branch):
+            mirrored += 1
+        else:
+            failed.append({'repo_id': rid, 'branch': branch})
+    return {'total': len(branches), 'mirrored': mirrored, 'failed': failed}
+
+
+def _branch_digest(db, repo_id: str, branch: str) -> Dict:
+    """计算 repo+branch 数据的对账摘要（行数 + 语句数合计）"""
+    file_ids = [
+        f.id for f in db.query(CoverageFile).filter(
+            CoverageFile.repo_id == repo_id,
+            CoverageFile.branch == branch
+        ).all()
+    ]
+    range_count = 0
+    statement_sum = 0
+    if file_ids:
+        range_count = db.query(func.count(CoverageRange.id)).filter(
+            CoverageRange.file_id.in_(file_ids)
+        ).scalar() or 0
+        statement_sum = db.query(func.sum(CoverageRange.statements)).filter(
+            CoverageRange.file_id.in_(file_ids)
+        ).scalar() or 0
+    snapshot_count = db.query(func.count(CoverageSnapshot.id)).filter(
+        CoverageSnapshot.repo_id == repo_id,
+        CoverageSnapshot.branch == branch
+    ).scalar() or 0
+    return {
+        'files': len(file_ids),
+        'ranges': int(range_count),
+        'statements': int(statement_sum),
+        'snapshots': int(snapshot_count)
+    }
+
+
+def verify(repo_id: Optional[str] = None) -> Dict:
+    """
+    对比源库与目标库的数据一致性
+
+    返回:
+        Dict: {'total': 分支数, 'matched': 一致数,
+               'mismatched': [{'repo_id', 'branch', 'source', 'target'}]}
+    """
+    branches = _list_branches(repo_id)
+    matched = 0
+    mismatched = []
+    for rid, branch in branches:
+        src = get_db_session()
+        tgt = get_target_session()
+        try:
+            source_digest = _branch_digest(src, rid, branch)
+            target_digest = _branch_digest(tgt, rid, branch)
+        finally:
+            src.close()
+            tgt.close()
+
+        if source_digest == target_digest:
+            matched += 1
+        else:
+            mismatched.append({
+                'repo_id': rid,
+                'branch': branch,
+                'source': source_digest,
+                'target': target_digest
+            })
+    return {'total': len(branches), 'matched': matched, 'mismatched': mismatched}
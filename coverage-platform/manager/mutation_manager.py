@@ -0,0 +1,217 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+变异测试结果管理模块
+接收 go-mutesting / pitest 格式的变异测试报告，与覆盖率数据关联
+核心输出："已覆盖但未被变异杀死"的行，比裸覆盖率更能反映测试质量
+"""
+
+import json
+import logging
+import time
+import xml.etree.ElementTree as ET
+from typing import Dict, List, Optional
+
+from models import (
+    CoverageFile, CoverageRange, MutationRecord, get_db_session
+)
+
+logger = logging.getLogger(__name__)
+
+# 支持的变异报告格式
+MUTATION_FORMAT_GO_MUTESTING = 'go-mutesting'
+MUTATION_FORMAT_PITEST = 'pitest'
+
+VALID_STATUSES = ('killed', 'survived', 'timeout', 'skipped')
+
+
+def parse_go_mutesting_report(raw: str) -> List[Dict]:
+    """
+    解析 go-mutesting 的 JSON 输出
+    期望结构: {"stats": {...}, "mutations": [{"file": ..., "line": ..., "mutator": ..., "status": ...}]}
+    """
+    data = json.loads(raw)
+    records = []
+    for m in data.get('mutations', []):
+        file_path = m.get('file', '')
+        line = m.get('line', 0)
+        status = m.get('status', '').lower()
+        if not file_path or not line or status not in VALID_STATUSES:
+            continue
+        records.append({
+            'file_path': file_path,
+            'line': int(line),
+            'mutator': m.get('mutator', ''),
+            'status': status
+        })
+    return records
+
+
+def parse_pitest_report(raw: str) -> List[Dict]:
+    """
+    解析 pitest 的 mutations.xml 输出
+    期望结构: <mutations><mutation status="KILLED"><sourceFile>...</sourceFile>
+              <lineNumber>...</lineNumber><mutator>...</mutator></mutation></mutations>
+    """
+    root = ET.fromstring(raw)
+    records = []
+    for mutation in root.findall('mutation'):
+        status = (mutation.get('status') or '').lower()
+        # pitest 的 NO_COVERAGE 等状态归入 survived
+        if status not in VALID_STATUSES:
+            if status in ('no_coverage', 'memory_error', 'run_error'):
+                status = 'survived'
+            else:
+                continue
+
+        source_file = mutation.findtext('sourceFile', '')
+        mutated_class = mutation.findtext('mutatedClass', '')
+        line_text = mutation.findtext('lineNumber', '0')
+        mutator = mutation.findtext('mutator', '')
+
+        try:
+            line = int(line_text)
+        except ValueError:
+            continue
+        if not source_file or not line:
+            continue
+
+        # 用包路径还原文件相对路径: com.foo.Bar + Bar.java -> com/foo/Bar.java
+        file_path = source_file
+        if mutated_class and '.' in mutated_class:
+            package = mutated_class.rsplit('.', 1)[0]
+            file_path = package.replace('.', '/') + '/' + source_file
+
+        records.append({
+            'file_path': file_path,
+            'line': line,
+            'mutator': mutator,
+            'status': status
+        })
+    return records
+
+
+def process_mutation_report(data: Dict) -> None:
+    """
+    处理变异测试报告消息
+    消息结构:
+        {
+            "type": "mutation",
+            "repo_id": ..., "branch": ..., "commit": ...,
+            "mutation": {"format": "go-mutesting"|"pitest", "raw": ...}
+        }
+    同 repo_id+branch 的旧记录会被整体替换
+    """
+    repo_id = data.get('repo_id', '')
+    branch = data.get('branch', '')
+    commit = data.get('commit', '')
+    mutation = data.get('mutation', {})
+    mutation_format = mutation.get('format', '')
+    raw = mutation.get('raw', '')
+
+    if not repo_id or not branch:
+        raise ValueError('Missing repo_id or branch in mutation message')
+
+    if mutation_format == MUTATION_FORMAT_GO_MUTESTING:
+        records = parse_go_mutesting_report(raw)
+    elif mutation_format == MUTATION_FORMAT_PITEST:
+        records = parse_pitest_report(raw)
+    else:
+        raise ValueError(f'unsupported mutation format: {mutation_format}')
+
+    db = get_db_session()
+    try:
+        now = int(time.time() * 1000)
+
+        # 旧记录整体替换（与覆盖率数据一样按 repo_id+branch 维度保留最新）
+        db.query(MutationRecord).filter(
+            MutationRecord.repo_id == repo_id,
+            MutationRecord.branch == branch
+        ).delete()
+
+        for r in records:
+            db.add(MutationRecord(
+                repo_id=repo_id,
+                branch=branch,
+                commit=commit,
+                file_path=r['file_path'],
+                line=r['line'],
+                mutator=r['mutator'],
+                status=r['status'],
+                created_at=now
+            ))
+
+        db.commit()
+        logger.info(
+            f"Processed mutation report: repo_id={repo_id}, branch={branch}, "
+            f"commit={commit}, records={len(records)}"
+        )
+    except Exception:
+        db.rollback()
+        raise
+    finally:
+        db.close()
+
+
+def get_covered_unkilled_lines(repo_id: str, branch: str) -> List[Dict]:
+    """
+    获取"已覆盖但存在存活变异体"的行
+    这些行被测试执行过，但测试没有断言出行为变化，是测试质量的薄弱点
+
+    返回:
+        List[Dict]: 按文件分组的薄弱行列表
+    """
+    db = get_db_session()
+    try:
+        survived = db.query(MutationRecord).filter(
+            MutationRecord.repo_id == repo_id,
+            MutationRecord.branch == branch,
+            MutationRecord.status == 'survived'
+        ).all()
+
+        if not survived:
+            return []
+
+        # 按文件分组存活变异体
+        file_to_mutants = {}
+        for m in survived:
+            file_to_mutants.setdefault(m.file_path, []).append(m)
+
+        result = []
+        for file_path, mutants in file_to_mutants.items():
+            file = db.query(CoverageFile).filter(
+                CoverageFile.repo_id == repo_id,
+                CoverageFile.branch == branch,
+                CoverageFile.file_path == file_path
+            ).first()
+            if not file:
+                continue
+
+            ranges = db.query(CoverageRange).filter(
+                CoverageRange.file_id == file.id,
+                CoverageRange.hit > 0
+            ).all()
+
+            weak_lines = []
+            for m in mutants:
+                # 变异体所在行被某个已覆盖的 block 包含 → 已覆盖但未杀死
+                for r in ranges:
+                    if r.start_line <= m.line <= r.end_line:
+                        weak_lines.append({
+                            'line': m.line,
+                            'mutator': m.mutator
+                        })
+                        break
+
+            if weak_lines:
+                weak_lines.sort(key=lambda x: x['line'])
+                result.append({
+                    'file': file_path,
+                    'covered_unkilled_lines': weak_lines,
+                    'total': len(weak_lines)
+                })
+
+        return result
+    finally:
+        db.close()
@@ -0,0 +1,91 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+OPA 策略门禁模块
+把门禁决策委托给 OPA（Open Policy Agent）：平台构造结构化的输入文档
+（增量覆盖率、总量、阈值、跳过标记等），由各组织自己维护的 Rego 策略
+给出 allow/deny，实现复杂的组织级规则而不用fork平台的规则引擎
+
+通过 OPA 的 REST API 评估（POST /v1/data/<policy_path>），
+策略期望返回 {'allow': bool, 'reason': str(可选)}
+OPA 不可用或返回不合法时回退到内置阈值规则（fail-open到内置规则而不是阻塞合并）
+
+环境变量:
+    OPA_URL: OPA 服务地址（如 http://opa:8181），未设置时不启用
+    OPA_POLICY_PATH: 策略路径（默认 orbit/gate，对应 data.orbit.gate）
+"""
+
+import logging
+import os
+from typing import Dict, Optional
+
+import requests
+
+logger = logging.getLogger(__name__)
+
+OPA_URL = os.getenv('OPA_URL', '').rstrip('/')
+OPA_POLICY_PATH = os.getenv('OPA_POLICY_PATH', 'orbit/gate').strip('/')
+
+REQUEST_TIMEOUT = 10
+
+
+def is_enabled() -> bool:
+    """是否启用 OPA 门禁"""
+    return bool(OPA_URL)
+
+
+def build_gate_input(repo_id: str, branch: str, commit: str, threshold: int,
+                     rate: Optional[float], total_new_lines: int,
+                     statistics: Dict, skip_marker: bool) -> Dict:
+    """
+    构造 OPA 输入文档
+    字段视为对外契约：Rego 策略通过 input.* 引用这些字段
+    """
+    return {
+        'repo_id': repo_id,
+        'branch': branch,
+        'commit': commit,
+        'threshold': threshold,
+        'incremental_coverage_rate': rate,
+        'total_new_lines': total_new_lines,
+        'total_statements': statistics.get('total_statements', 0),
+        'covered_statements': statistics.get('covered_statements', 0),
+        'coverage_rate': statistics.get('coverage_rate', 0.0),
+        'skip_marker': skip_marker
+    }
+
+
+def evaluate(input_doc: Dict) -> Optional[Dict]:
+    """
+    用 OPA 评估门禁输入
+
+    返回:
+        Optional[Dict]: {'allow': bool, 'reason': str}
+        OPA 不可用或返回不合法时返回 None（调用方回退到内置规则）
+    """
+    try:
+        response = requests.post(
+            f'{OPA_URL}/v1/data/{OPA_POLICY_PATH}',
+            json={'input': input_doc},
+            timeout=REQUEST_TIMEOUT
+        )
+        if response.status_code != 200:
+            logger.warning(
+                f"OPA evaluation failed: HTTP {response.status_code}, "
+                f"{response.text[:200]}"
+            )
+            return None
+
+        result = response.json().get('result')
+        if not isinstance(result, dict) or 'allow' not in result:
+            logger.warning(f"OPA returned invalid result: {str(result)[:200]}")
+            return None
+
+        return {
+            'allow': bool(result['allow']),
+            'reason': str(result.get('reason', ''))
+        }
+    except Exception as e:
+        logger.warning(f"OPA evaluation error: {e}")
+        return None
@@ -0,0 +1,60 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+百分比精度策略模块
+统一门禁、徽章、API 响应中覆盖率百分比的舍入规则，
+避免 "79.996% 算不算 80%" 这类在各处行为不一致引起的争议
+
+策略通过环境变量配置:
+    COVERAGE_ROUNDING: round（四舍五入，默认）/ floor（向下取整）/ ceil（向上取整）
+    COVERAGE_DECIMAL_PLACES: 小数位数（默认2）
+"""
+
+import logging
+import math
+import os
+
+logger = logging.getLogger(__name__)
+
+ROUNDING_ROUND = 'round'
+ROUNDING_FLOOR = 'floor'
+ROUNDING_CEIL = 'ceil'
+
+SUPPORTED_ROUNDINGS = [ROUNDING_ROUND, ROUNDING_FLOOR, ROUNDING_CEIL]
+
+ROUNDING_POLICY = os.getenv('COVERAGE_ROUNDING', ROUNDING_ROUND)
+DECIMAL_PLACES = int(os.getenv('COVERAGE_DECIMAL_PLACES', '2'))
+
+if ROUNDING_POLICY not in SUPPORTED_ROUNDINGS:
+    logger.warning(
+        f"Invalid COVERAGE_ROUNDING: {ROUNDING_POLICY}, falling back to {ROUNDING_ROUND}"
+    )
+    ROUNDING_POLICY = ROUNDING_ROUND
+
+
+def apply_policy(rate: float) -> float:
+    """
+    按配置的策略对百分比取精度
+    所有对外输出和阈值比较都应使用该函数，保证规则全局一致
+    """
+    factor = 10 ** DECIMAL_PLACES
+    if ROUNDING_POLICY == ROUNDING_FLOOR:
+        return math.floor(rate * factor) / factor
+    elif ROUNDING_POLICY == ROUNDING_CEIL:
+        return math.ceil(rate * factor) / factor
+    else:
+        return round(rate, DECIMAL_PLACES)
+
+
+def format_rate(rate: float) -> str:
+    """按配置的小数位数格式化百分比（不带%号）"""
+    return f'{apply_policy(rate):.{DECIMAL_PLACES}f}'
+
+
+def get_policy() -> dict:
+    """返回当前生效的精度策略（用于状态/调试接口）"""
+    return {
+        'rounding': ROUNDING_POLICY,
+        'decimal_places': DECIMAL_PLACES
+    }
@@ -0,0 +1,213 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+启动前置检查模块
+在服务启动时（或通过preflight.py手工执行）验证各依赖是否就绪：
+数据库连通性与表结构、broker拓扑权限、git host token有效性，
+问题在启动时就以可操作的报错暴露，而不是等第一条真实报告进来才炸
+
+每项检查返回 {'name', 'ok', 'detail'}，未配置的可选依赖记为跳过（ok=True）
+
+环境变量:
+    PREFLIGHT_ON_STARTUP: 设为1/true时服务启动即执行检查，失败则拒绝启动
+"""
+
+import logging
+import os
+from typing import Dict, List
+
+from sqlalchemy import inspect
+
+logger = logging.getLogger(__name__)
+
+PREFLIGHT_ON_STARTUP = os.getenv(
+    'PREFLIGHT_ON_STARTUP', ''
+).lower() in ('1', 'true')
+
+
+class PreflightError(Exception):
+    """前置检查失败（消息中汇总各失败项）"""
+    pass
+
+
+def check_database() -> Dict:
+    """检查数据库连通性与表结构（缺表时提示先执行init_db）"""
+    try:
+        from models import Base, engine
+
+        inspector = inspect(engine)
+        existing = set(inspector.get_table_names())
+        expected = set(Base.metadata.tables.keys())
+        missing = sorted(expected - existing)
+        if missing:
+            return {
+                'name': 'database',
+                'ok': False,
+                'detail': (
+                    f"missing tables: {', '.join(missing)} "
+                    "(run init_db() or start the service once with a privileged DB user)"
+                )
+            }
+        return {'name': 'database', 'ok': True, 'detail': f'{len(expected)} tables present'}
+    except Exception as e:
+        return {
+            'name': 'database',
+            'ok': False,
+            'detail': f'cannot connect to database: {e} (check DATABASE_URL)'
+        }
+
+
+def check_broker() -> Dict:
+    """
+    检查broker连通性与拓扑权限
+    幂等声明交换机/队列并绑定，configure/write权限不足会在这里报错
+    """
+    try:
+        import pika
+        from manager import publish_manager
+
+        queue = os.getenv('COVERAGE_QUEUE', 'coverage_queue')
+        connection = pika.BlockingConnection(
+            publish_manager._connection_parameters()
+        )
+        try:
+            channel = connection.channel()
+            channel.exchange_declare(
+                exchange=publish_manager.EXCHANGE_NAME,
+                exchange_type='topic',
+                durable=True
+            )
+            channel.queue_declare(queue=queue, durable=True, passive=True)
+            channel.queue_bind(
+                exchange=publish_manager.EXCHANGE_NAME,
+                queue=queue,
+                routing_key=publish_manager.ROUTING_KEY
+            )
+        finally:
+            connection.close()
+        return {
+            'name': 'broker',
+            'ok': True,
+            'detail': (
+                f'exchange={publish_manager.EXCHANGE_NAME}, queue={queue}, '
+                f'routing_key={publish_manager.ROUTING_KEY}'
+            )
+        }
+    except Exception as e:
+        return {
+            'name': 'broker',
+            'ok': False,
+            'detail': (
+                f'broker topology check failed: {e} '
+                '(check RABBITMQ_URL/vhost permissions and that the consumer '
+                'has declared the queue; COVERAGE_EXCHANGE/COVERAGE_QUEUE must '
+                'match across publisher and consumer)'
+            )
+        }
+
+
+def check_githost() -> Dict:
+    """
+    检查git host token有效性与权限范围
+    GITLAB_TOKEN未配置时视为跳过（git host集成是可选功能）
+    """
+    from manager.gitlab_manager import GITLAB_URL, GITLAB_TOKEN
+
+    if not GITLAB_TOKEN:
+        return {
+            'name': 'githost',
+            'ok': True,
+            'detail': 'skipped (GITLAB_TOKEN not configured)'
+        }
+
+    try:
+        import requests
+
+        response = requests.get(
+            f'{GITLAB_URL}/api/v4/personal_access_tokens/self',
+            headers={'PRIVATE-TOKEN': GITLAB_TOKEN},
+            timeout=15
+        )
+        if response.status_code == 401:
+            return {
+                'name': 'githost',
+                'ok': False,
+                'detail': 'GITLAB_TOKEN is invalid or expired'
+            }
+        if response.status_code == 200:
+            scopes = response.json().get('scopes', [])
+            if 'api' not in scopes:
+                return {
+                    'name': 'githost',
+                    'ok': False,
+                    'detail': (
+                        f"token scopes {scopes} lack 'api' "
+                        '(commit status and approval rule sync will fail)'
+                    )
+                }
+            return {'name': 'githost', 'ok': True, 'detail': f'token scopes: {scopes}'}
+
+        # 旧版GitLab没有token自省接口，退化为验证token能访问API
+        response = requests.get(
+            f'{GITLAB_URL}/api/v4/user',
+            headers={'PRIVATE-TOKEN': GITLAB_TOKEN},
+            timeout=15
+        )
+        if response.status_code == 200:
+            return {
+                'name': 'githost',
+                'ok': True,
+                'detail': 'token valid (scope introspection unavailable)'
+            }
+        return {
+            'name': 'githost',
+            'ok': False,
+            'detail': f'git host returned HTTP {response.status_code} (check GITLAB_URL/GITLAB_TOKEN)'
+        }
+    except Exception as e:
+        return {
+            'name': 'githost',
+            'ok': False,
+            'detail': f'cannot reach git host: {e} (check GITLAB_URL)'
+        }
+
+
+def run_preflight(include_broker: bool = True) -> Dict:
+    """
+    执行全部前置检查
+
+    参数:
+        include_broker: 是否检查broker（纯查询场景可跳过）
+
+    返回:
+        Dict: {'ok': 全部通过, 'checks': [各项结果]}
+    """
+    checks: List[Dict] = [check_database()]
+    if include_broker:
+        checks.append(check_broker())
+    checks.append(check_githost())
+
+    for check in checks:
+        level = logging.INFO if check['ok'] else logging.ERROR
+        logger.log(level, f"[preflight] {check['name']}: "
+                          f"{'ok' if check['ok'] else 'FAILED'} - {check['detail']}")
+
+    return {'ok': all(c['ok'] for c in checks), 'checks': checks}
+
+
+def run_startup_preflight(include_broker: bool = True) -> None:
+    """
+    启动时执行前置检查（PREFLIGHT_ON_STARTUP开启时生效）
+    任一检查失败抛PreflightError，让服务fail fast
+    """
+    if not PREFLIGHT_ON_STARTUP:
+        return
+
+    result = run_preflight(include_broker=include_broker)
+    if not result['ok']:
+        failures = '; '.join(
+            f"{c['name']}: {c['detail']}"
+            for c in result['checks'] if not c['ok']
+        )
+        raise PreflightError(f'preflight checks failed: {failures}')
@@ -0,0 +1,173 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+报告处理耗时剖析业务逻辑管理层
+消费端处理每份报告时按阶段（解析/存储/git/快照等）计时并落库，
+管理端接口按天聚合找出最慢的报告和仓库——优化入库耗时时
+有数据可依，而不是靠猜时间花在哪
+
+环境变量:
+    INGEST_PROFILING_ENABLED: 是否记录耗时（默认1开启）
+"""
+
+import json
+import logging
+import os
+import time
+from typing import Dict, Optional
+
+from models import CoverageConfig, IngestTiming, get_db_session
+
+logger = logging.getLogger(__name__)
+
+PROFILING_ENABLED = os.environ.get('INGEST_PROFILING_ENABLED', '1') == '1'
+
+
+class StageTimer:
+    """
+    阶段计时器
+    enter(阶段名)切换到新阶段并结算上一阶段耗时，
+    finish()结算最后一个阶段；同名阶段多次进入时耗时累加
+    """
+
+    def __init__(self):
+        self.stages = {}  # 阶段名 -> 累计毫秒
+        self._current = None
+        self._stage_start = 0.0
+        self._total_start = time.perf_counter()
+
+    def _close_current(self):
+        if self._current is not None:
+            elapsed_ms = int((time.perf_counter() - self._stage_start) * 1000)
+            self.stages[self._current] = (
+                self.stages.get(self._current, 0) + elapsed_ms
+            )
+
+    def enter(self, stage: str):
+        """进入新阶段（自动结算上一阶段）"""
+        self._close_current()
+        self._current = stage
+        self._stage_start = time.perf_counter()
+
+    def finish(self) -> int:
+        """结束计时，返回总耗时（毫秒）"""
+        self._close_current()
+        self._current = None
+        return int((time.perf_counter() - self._total_start) * 1000)
+
+
+def record_timing(repo_id: str, branch: str, commit: str,
+                  timer: StageTimer) -> None:
+    """
+    把一次报告处理的耗时落库（独立事务，失败只记日志不影响主流程）
+    """
+    if not PROFILING_ENABLED:
+        return
+
+    total_ms = timer.finish()
+    db = get_db_session()
+    try:
+        db.add(IngestTiming(
+            repo_id=repo_id,
+            branch=branch,
+            commit=commit,
+            total_ms=total_ms,
+            stages=json.dumps(timer.stages),
+            created_at=int(time.time() * 1000)
+        ))
+        db.commit()
+    except Exception as e:
+        db.rollback()
+        logger.warning(f"Failed to record ingest timing: {e}")
+    finally:
+        db.close()
+
+
+def get_slow_reports(date: Optional[str] = None, limit: int = 10) -> Dict:
+    """
+    获取某天最慢的报告处理记录和最慢的仓库
+
+    参数:
+        date: 日期（YYYY-MM-DD，默认今天，按服务器本地时区）
+        limit: 报告/仓库各返回的条数上限
+
+    返回:
+        Dict: {
+            'date',
+            'reports': [耗时记录（stages已解析为字典），按total_ms降序],
+            'repos': [{'repo_id', 'repo_name', 'reports',
+                       'max_total_ms', 'avg_total_ms'}，按max_total_ms降序]
+        }
+
+    异常:
+        ValueError: 日期格式不合法
+    """
+    if date:
+        try:
+            day_struct = time.strptime(date, '%Y-%m-%d')
+        except ValueError:
+            raise ValueError(f'invalid date (expect YYYY-MM-DD): {date}')
+    else:
+        day_struct = time.localtime()
+        date = time.strftime('%Y-%m-%d', day_struct)
+
+    day_start = int(time.mktime((
+        day_struct.tm_year, day_struct.tm_mon, day_struct.tm_mday,
+        0, 0, 0, 0, 0, -1
+    )) * 1000)
+    day_end = day_start + 24 * 3600 * 1000
+
+    db = get_db_session()
+    try:
+        rows = db.query(IngestTiming).filter(
+            IngestTiming.created_at >= day_start,
+            IngestTiming.created_at < day_end
+        ).all()
+
+        reports = []
+        by_repo = {}
+        for row in rows:
+            entry = row.to_dict()
+            try:
+                entry['stages'] = json.loads(row.stages) if row.stages else {}
+            except (ValueError, TypeError):
+                entry['stages'] = {}
+            reports.append(entry)
+
+            stat = by_repo.setdefault(row.repo_id, {
+                'repo_id': row.repo_id,
+                'reports': 0,
+                'max_total_ms': 0,
+                '_sum_total_ms': 0
+            })
+            stat['reports'] += 1
+            stat['max_total_ms'] = max(stat['max_total_ms'], row.total_ms)
+            stat['_sum_total_ms'] += row.total_ms
+
+        reports.sort(key=lambda e: e['total_ms'], reverse=True)
+
+        repos = []
+        for stat in by_repo.values():
+            stat['avg_total_ms'] = stat['_sum_total_ms'] // stat['reports']
+            del stat['_sum_total_ms']
+            repos.append(stat)
+        repos.sort(key=lambda s: s['max_total_ms'], reverse=True)
+        repos = repos[:limit]
+
+        # 补上repo_name方便人工核对
+        names = {
+            c.repo_id: c.repo_name for c in db.query(CoverageConfig).filter(
+                CoverageConfig.repo_id.in_([s['repo_id'] for s in repos])
+            ).all()
+        } if repos else {}
+        for stat in repos:
+            stat['repo_name'] = names.get(stat['repo_id'])
+
+        return {
+            'date': date,
+            'reports': reports[:limit],
+            'repos': repos
+        }
+    finally:
+        db.close()
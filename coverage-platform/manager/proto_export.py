@@ -0,0 +1,156 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+覆盖率模型 protobuf 导出模块
+按 proto/coverage.proto 的定义把归一化覆盖率模型编码为 protobuf 二进制，
+供发布看板、风险引擎等内部服务直接消费
+
+编码器按 protobuf wire format 手写（varint + length-delimited），
+不引入 protobuf 运行时依赖；字段号与 .proto 文件必须保持同步
+"""
+
+import logging
+import struct
+import time
+from typing import Dict, List, Optional
+
+from models import (
+    CoverageReport, CoverageFile, CoverageRange, CoverageConfig, get_db_session
+)
+from manager.manager import should_exclude_file
+from manager import precision
+
+logger = logging.getLogger(__name__)
+
+# wire type
+_WIRE_VARINT = 0
+_WIRE_FIXED64 = 1
+_WIRE_LEN = 2
+
+
+def _varint(value: int) -> bytes:
+    """编码无符号varint"""
+    out = bytearray()
+    while True:
+        byte = value & 0x7F
+        value >>= 7
+        if value:
+            out.append(byte | 0x80)
+        else:
+            out.append(byte)
+            return bytes(out)
+
+
+def _tag(field: int, wire_type: int) -> bytes:
+    return _varint((field << 3) | wire_type)
+
+
+def _uint(field: int, value: int) -> bytes:
+    """uint字段，proto3省略零值"""
+    if not value:
+        return b''
+    return _tag(field, _WIRE_VARINT) + _varint(value)
+
+
+def _string(field: int, value: str) -> bytes:
+    if not value:
+        return b''
+    encoded = value.encode('utf-8')
+    return _tag(field, _WIRE_LEN) + _varint(len(encoded)) + encoded
+
+
+def _double(field: int, value: float) -> bytes:
+    if not value:
+        return b''
+    return _tag(field, _WIRE_FIXED64) + struct.pack('<d', value)
+
+
+def _message(field: int, body: bytes) -> bytes:
+    return _tag(field, _WIRE_LEN) + _varint(len(body)) + body
+
+
+def _encode_range(r: CoverageRange) -> bytes:
+    """编码 CoverageRange 消息"""
+    return (
+        _uint(1, r.start_line) +
+        _uint(2, r.start_col) +
+        _uint(3, r.end_line) +
+        _uint(4, r.end_col) +
+        _uint(5, r.statements) +
+        _uint(6, r.hit)
+    )
+
+
+def _encode_file(file_path: str, ranges: List[CoverageRange]) -> bytes:
+    """编码 FileCoverage 消息"""
+    total = 0
+    covered = 0
+    body = _string(1, file_path)
+    for r in ranges:
+        body += _message(2, _encode_range(r))
+        total += r.statements
+        if r.hit > 0:
+            covered += r.statements
+
+    rate = precision.apply_policy(covered / total * 100) if total > 0 else 0.0
+    body += _uint(3, total) + _uint(4, covered) + _double(5, rate)
+    return body
+
+
+def export_repo_coverage(repo_id: str, branch: str) -> Optional[bytes]:
+    """
+    导出仓库分支的覆盖率模型为 RepoCoverage 二进制
+
+    返回:
+        Optional[bytes]: protobuf 编码的 RepoCoverage，仓库未配置返回 None
+    """
+    db = get_db_session()
+    try:
+        config = db.query(CoverageConfig).filter(
+            CoverageConfig.repo_id == repo_id
+        ).first()
+        if not config:
+            return None
+
+        report = db.query(CoverageReport).filter(
+            CoverageReport.repo_id == repo_id,
+            CoverageReport.branch == branch
+        ).first()
+
+        files = db.query(CoverageFile).filter(
+            CoverageFile.repo_id == repo_id,
+            CoverageFile.branch == branch
+        ).all()
+
+        body = (
+            _string(1, repo_id) +
+            _string(2, branch) +
+            _string(3, report.commit if report else '')
+        )
+
+        total = 0
+        covered = 0
+        for file in files:
+            if should_exclude_file(file.file_path, config.exclude_dirs,
+                                   config.exclude_files):
+                continue
+            ranges = db.query(CoverageRange).filter(
+                CoverageRange.file_id == file.id
+            ).all()
+            body += _message(4, _encode_file(file.file_path, ranges))
+            for r in ranges:
+                total += r.statements
+                if r.hit > 0:
+                    covered += r.statements
+
+        rate = precision.apply_policy(covered / total * 100) if total > 0 else 0.0
+        body += (
+            _uint(5, total) +
+            _uint(6, covered) +
+            _double(7, rate) +
+            _uint(8, int(time.time() * 1000))
+        )
+        return body
+    finally:
+        db.close()
@@ -0,0 +1,126 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+提交签名与CI来源校验模块
+基准分支的覆盖率数据会进入合规报表，可选地在入库前校验：
+  1. commit 是否带签名（本地 git 校验，bare 仓库需已 fetch 该 commit）
+  2. 消息声明的 pipeline 是否真实存在且属于该仓库（GitLab API）
+校验只对 branch == 基准分支 的报告生效，未开启时完全不影响处理流程
+
+环境变量:
+    PROVENANCE_VERIFY: 设为 1 开启校验（默认关闭）
+    PROVENANCE_REQUIRE_SIGNATURE: 设为 0 可跳过签名校验（默认开启）
+    PROVENANCE_REQUIRE_PIPELINE: 设为 0 可跳过 pipeline 校验（默认开启）
+"""
+
+import logging
+import os
+import subprocess
+from typing import Dict, List
+from urllib.parse import quote
+
+import requests
+
+from manager.repo_manager import get_bare_repo_path
+
+logger = logging.getLogger(__name__)
+
+PROVENANCE_VERIFY = os.getenv('PROVENANCE_VERIFY', '0') == '1'
+PROVENANCE_REQUIRE_SIGNATURE = os.getenv('PROVENANCE_REQUIRE_SIGNATURE', '1') == '1'
+PROVENANCE_REQUIRE_PIPELINE = os.getenv('PROVENANCE_REQUIRE_PIPELINE', '1') == '1'
+
+GITLAB_URL = os.getenv('GITLAB_URL', 'https://gitlab.com').rstrip('/')
+GITLAB_TOKEN = os.getenv('GITLAB_TOKEN', '')
+
+REQUEST_TIMEOUT = 15
+
+
+def is_enabled() -> bool:
+    """是否开启来源校验"""
+    return PROVENANCE_VERIFY
+
+
+def commit_is_signed(repo_url: str, commit: str) -> bool:
+    """
+    检查 commit 是否携带签名
+    只检查签名是否存在（%G? 非 N），不验证签名者身份——
+    平台侧没有各团队的公钥环，密钥校验由 git 服务端的推送规则保证
+    """
+    try:
+        bare_repo_path = get_bare_repo_path(repo_url)
+        result = subprocess.run(
+            ['git', 'log', '-1', '--format=%G?', commit],
+            cwd=bare_repo_path,
+            capture_output=True,
+            text=True,
+            timeout=30
+        )
+        if result.returncode != 0:
+            logger.warning(
+                f"Failed to check commit signature: commit={commit}, "
+                f"stderr={result.stderr.strip()[:200]}"
+            )
+            return False
+        status = result.stdout.strip()
+        return status not in ('', 'N')
+    except Exception as e:
+        logger.warning(f"Failed to check commit signature: {e}")
+        return False
+
+
+def _project_path_from_url(repo_url: str) -> str:
+    """从仓库URL提取 GitLab project path（owner/repo）"""
+    url = repo_url.rstrip('/')
+    if url.endswith('.git'):
+        url = url[:-4]
+    if url.startswith('git@'):
+        # git@host:owner/repo
+        _, _, path = url.partition(':')
+    else:
+        # http(s)://host/owner/repo
+        parts = url.split('/', 3)
+        path = parts[3] if len(parts) > 3 else ''
+    return path
+
+
+def pipeline_belongs_to_repo(repo_url: str, pipeline_id: str) -> bool:
+    """通过 GitLab API 校验 pipeline 存在且属于该仓库"""
+    if not GITLAB_TOKEN:
+        logger.warning("GITLAB_TOKEN not configured, cannot verify pipeline")
+        return False
+    project_path = _project_path_from_url(repo_url)
+    if not project_path or not pipeline_id:
+        return False
+    try:
+        url = (
+            f'{GITLAB_URL}/api/v4/projects/{quote(project_path, safe="")}'
+            f'/pipelines/{pipeline_id}'
+        )
+        response = requests.get(
+            url, headers={'PRIVATE-TOKEN': GITLAB_TOKEN}, timeout=REQUEST_TIMEOUT
+        )
+        return response.status_code == 200
+    except Exception as e:
+        logger.warning(f"Failed to verify pipeline: {e}")
+        return False
+
+
+def verify_report(repo_url: str, commit: str, ci: Dict) -> Dict:
+    """
+    对一条报告做来源校验
+
+    返回:
+        Dict: {'verified': bool, 'failures': [失败原因]}
+    """
+    failures: List[str] = []
+
+    if PROVENANCE_REQUIRE_SIGNATURE and not commit_is_signed(repo_url, commit):
+        failures.append(f'commit not signed: {commit}')
+
+    if PROVENANCE_REQUIRE_PIPELINE:
+        pipeline_id = str(ci.get('pipeline_id', '') or '')
+        if not pipeline_belongs_to_repo(repo_url, pipeline_id):
+            failures.append(f'pipeline not found or not owned by repo: {pipeline_id}')
+
+    return {'verified': not failures, 'failures': failures}
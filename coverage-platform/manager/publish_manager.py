@@ -0,0 +1,350 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+消息发布模块
+供 API 侧和 CLI 把覆盖率报告消息发布到消息队列（与 consumer 使用相同的拓扑）
+支持本地 spool：broker 不可达时消息落盘，下次调用时优先补发，
+保证 CI 侧的覆盖率数据不因瞬时故障丢失
+
+另提供基于本地WAL的"精确一次"发布（publish_with_wal）：
+消息先追加到WAL，开启publisher confirms发布，broker确认后才标记done；
+CI任务重试会命中done标记直接跳过（不重复），发布中途崩溃/broker抖动
+则下次重放未done的条目（不丢失）
+"""
+
+import hashlib
+import json
+import logging
+import os
+
+import pika
+
+from manager import determinism
+
+logger = logging.getLogger(__name__)
+
+# spool目录路径（在coverage-platform目录下，与repos目录同级）
+SPOOL_BASE_DIR = os.path.join(
+    os.path.dirname(os.path.dirname(os.path.abspath(__file__))), 'spool'
+)
+
+# WAL目录路径（精确一次发布用，可用ORBIT_WAL_DIR指到CI的持久化目录）
+WAL_BASE_DIR = os.getenv('ORBIT_WAL_DIR') or os.path.join(
+    os.path.dirname(os.path.dirname(os.path.abspath(__file__))), 'wal'
+)
+# done标记的保留时长，超过后由compact_wal清理（默认7天）
+WAL_RETENTION_HOURS = int(os.getenv('ORBIT_WAL_RETENTION_HOURS', '168'))
+
+# RabbitMQ 配置（与 coverage-consumer 保持一致）
+RABBITMQ_HOST = os.getenv('RABBITMQ_HOST', 'localhost')
+RABBITMQ_PORT = os.getenv('RABBITMQ_PORT', '5672')
+RABBITMQ_USER = os.getenv('RABBITMQ_USER', 'coverage')
+RABBITMQ_PASSWORD = os.getenv('RABBITMQ_PASSWORD', 'coverage123')
+RABBITMQ_VHOST = os.getenv('RABBITMQ_VHOST', '/')
+RABBITMQ_URL = os.getenv(
+    'RABBITMQ_URL',
+    f"amqp://{RABBITMQ_USER}:{RABBITMQ_PASSWORD}@{RABBITMQ_HOST}:{RABBITMQ_PORT}{RABBITMQ_VHOST}"
+)
+# RabbitMQ集群：逗号分隔的多个AMQP地址，按顺序尝试连接（failover）
+# 设置后优先于RABBITMQ_URL生效
+RABBITMQ_URLS = os.getenv('RABBITMQ_URLS', '')
+
+
+def _connection_parameters() -> list:
+    """
+    构建连接参数列表（配置了RABBITMQ_URLS时支持多节点failover）
+    每次调用重新构建，保证主机名每次连接都重新解析DNS
+    """
+    if RABBITMQ_URLS:
+        urls = [u.strip() for u in RABBITMQ_URLS.split(',') if u.strip()]
+    else:
+        urls = [RABBITMQ_URL]
+    return [pika.URLParameters(url) for url in urls]
+
+# 队列拓扑命名（多环境共用一个broker集群时按环境区分，需与consumer一致）
+EXCHANGE_NAME = os.getenv('COVERAGE_EXCHANGE', 'coverage_exchange')
+ROUTING_KEY = os.getenv('COVERAGE_ROUTING_KEY', 'coverage.report')
+
+
+def publish_coverage_message(message: dict) -> bool:
+    """
+    发布一条覆盖率报告消息
+
+    参数:
+        message: 消息体（dict，与消费端消息格式一致）
+
+    返回:
+        bool: 是否发布成功
+    """
+    try:
+        # 故障注入点（staging验证spool落盘补发，默认空操作）
+        from manager import chaos_manager
+        chaos_manager.maybe_inject('publish')
+
+        # pika支持传入parameters列表，按顺序尝试直到连接成功
+        connection = pika.BlockingConnection(_connection_parameters())
+        try:
+            channel = connection.channel()
+            channel.exchange_declare(
+                exchange=EXCHANGE_NAME,
+                exchange_type='topic',
+                durable=True
+            )
+            channel.basic_publish(
+                exchange=EXCHANGE_NAME,
+                routing_key=ROUTING_KEY,
+                body=json.dumps(message).encode('utf-8'),
+                properties=pika.BasicProperties(
+                    content_type='application/json',
+                    delivery_mode=2  # 持久化消息
+                )
+            )
+            return True
+        finally:
+            connection.close()
+    except Exception as e:
+        logger.error(f"Failed to publish coverage message: {e}")
+        return False
+
+
+def spool_message(message: dict) -> str:
+    """
+    将消息写入本地 spool 目录（broker 不可达时调用）
+
+    返回:
+        str: spool 文件路径
+    """
+    os.makedirs(SPOOL_BASE_DIR, exist_ok=True)
+    # 时间戳前缀保证补发顺序与产生顺序一致
+    name = f'{determinism.now_ms()}_{determinism.new_id()}.json'
+    path = os.path.join(SPOOL_BASE_DIR, name)
+    with open(path, 'w', encoding='utf-8') as f:
+        json.dump(message, f)
+    logger.warning(f"Spooled coverage message to {path}")
+    return path
+
+
+def flush_spool() -> int:
+    """
+    补发 spool 目录中的消息（按文件名时间顺序）
+    遇到第一条发布失败的消息即停止（broker 仍不可达，保留剩余消息）
+
+    返回:
+        int: 本次成功补发的消息数
+    """
+    if not os.path.isdir(SPOOL_BASE_DIR):
+        return 0
+
+    flushed = 0
+    for name in sorted(os.listdir(SPOOL_BASE_DIR)):
+        if not name.endswith('.json'):
+            continue
+        path = os.path.join(SPOOL_BASE_DIR, name)
+        try:
+            with open(path, 'r', encoding='utf-8') as f:
+                message = json.load(f)
+        except (OSError, ValueError) as e:
+            logger.error(f"Dropping unreadable spool file {path}: {e}")
+            try:
+                os.remove(path)
+            except OSError:
+                pass
+            continue
+
+        if not publish_coverage_message(message):
+            break
+        try:
+            os.remove(path)
+        except OSError:
+            pass
+        flushed += 1
+
+    if flushed:
+        logger.info(f"Flushed {flushed} spooled coverage messages")
+    return flushed
+
+
+def publish_or_spool(message: dict) -> bool:
+    """
+    发布消息；broker 不可达时落盘到 spool，等待下次调用补发
+
+    返回:
+        bool: True=已发布, False=已落盘待补发
+    """
+    # 先补发历史积压，保证消息顺序
+    flush_spool()
+
+    if publish_coverage_message(message):
+        return True
+
+    spool_message(message)
+    return False
+
+
+def _message_key(message: dict) -> str:
+    """
+    计算消息的去重键：同一CI任务重跑产生的相同消息得到相同的键
+    取repo_id/commit/CI任务标识+消息体哈希，避免把不同内容误判为重复
+    """
+    ci = message.get('ci') or {}
+    ident = '|'.join([
+        str(message.get('repo_id', '')),
+        str(message.get('commit', '')),
+        str(ci.get('pipeline_id', '')),
+        str(ci.get('job_id', ''))
+    ])
+    body_hash = hashlib.sha256(
+        json.dumps(message, sort_keys=True, ensure_ascii=False).encode('utf-8')
+    ).hexdigest()[:16]
+    return hashlib.sha256(ident.encode('utf-8')).hexdigest()[:16] + '_' + body_hash
+
+
+def _publish_confirmed(message: dict, message_id: str) -> bool:
+    """开启publisher confirms发布一条消息，broker确认后才返回True"""
+    try:
+        from manager import chaos_manager
+        chaos_manager.maybe_inject('publish')
+
+        connection = pika.BlockingConnection(_connection_parameters())
+        try:
+            channel = connection.channel()
+            channel.exchange_declare(
+                exchange=EXCHANGE_NAME,
+                exchange_type='topic',
+                durable=True
+            )
+            # confirms模式下basic_publish在nack/未确认时抛异常
+            channel.confirm_delivery()
+            channel.basic_publish(
+                exchange=EXCHANGE_NAME,
+                routing_key=ROUTING_KEY,
+                body=json.dumps(message).encode('utf-8'),
+                properties=pika.BasicProperties(
+                    content_type='application/json',
+                    delivery_mode=2,  # 持久化消息
+                    message_id=message_id
+                )
+            )
+            return True
+        finally:
+            connection.close()
+    except Exception as e:
+        logger.error(f"Failed to publish confirmed message: {e}")
+        return False
+
+
+def publish_with_wal(message: dict) -> dict:
+    """
+    基于本地WAL的精确一次发布
+
+    流程:
+        1. 计算去重键；已有done标记（CI重跑）直接跳过
+        2. 消息追加到WAL（先落盘再发布，崩溃不丢）
+        3. 开启publisher confirms发布，broker确认后写done标记
+        4. 顺带重放WAL中其他未done的条目（补上次崩溃/broker抖动欠的账）
+
+    返回:
+        dict: {'key', 'status'}，status为
+            published=本次发布成功 / duplicate=重复消息已跳过 /
+            pending=broker未确认，已留在WAL等待重放
+    """
+    os.makedirs(WAL_BASE_DIR, exist_ok=True)
+    key = _message_key(message)
+    entry_path = os.path.join(WAL_BASE_DIR, f'{key}.json')
+    done_path = os.path.join(WAL_BASE_DIR, f'{key}.done')
+
+    if os.path.exists(done_path):
+        logger.info(f"Skipping duplicate message (already acked): {key}")
+        return {'key': key, 'status': 'duplicate'}
+
+    # 先写WAL再发布；重复写同一条目是幂等的
+    with open(entry_path, 'w', encoding='utf-8') as f:
+        json.dump(message, f)
+
+    # 先重放历史未完成条目，保证顺序
+    replay_wal(exclude_key=key)
+
+    if not _publish_confirmed(message, key):
+        logger.warning(f"Broker did not ack, message kept in WAL: {key}")
+        return {'key': key, 'status': 'pending'}
+
+    with open(done_path, 'w', encoding='utf-8') as f:
+        f.write(str(determinism.now_ms()))
+    return {'key': key, 'status': 'published'}
+
+
+def replay_wal(exclude_key: str = None) -> int:
+    """
+    重放WAL中未确认的条目（按文件修改时间顺序）
+    遇到第一条未被确认的即停止（broker仍不可达，保留剩余条目）
+
+    返回:
+        int: 本次成功补发的条数
+    """
+    if not os.path.isdir(WAL_BASE_DIR):
+        return 0
+
+    pending = []
+    for name in os.listdir(WAL_BASE_DIR):
+        if not name.endswith('.json'):
+            continue
+        key = name[:-len('.json')]
+        if key == exclude_key:
+            continue
+        if os.path.exists(os.path.join(WAL_BASE_DIR, f'{key}.done')):
+            continue
+        path = os.path.join(WAL_BASE_DIR, name)
+        pending.append((os.path.getmtime(path), key, path))
+
+    replayed = 0
+    for _, key, path in sorted(pending):
+        try:
+            with open(path, 'r', encoding='utf-8') as f:
+                message = json.load(f)
+        except (OSError, ValueError) as e:
+            logger.error(f"Dropping unreadable WAL entry {path}: {e}")
+            try:
+                os.remove(path)
+            except OSError:
+                pass
+            continue
+
+        if not _publish_confirmed(message, key):
+            break
+        with open(os.path.join(WAL_BASE_DIR, f'{key}.done'), 'w',
+                  encoding='utf-8') as f:
+            f.write(str(determinism.now_ms()))
+        replayed += 1
+
+    if replayed:
+        logger.info(f"Replayed {replayed} WAL entries")
+    return replayed
+
+
+def compact_wal() -> int:
+    """清理超过保留期的已确认条目（entry+done成对删除），返回清理条数"""
+    if not os.path.isdir(WAL_BASE_DIR):
+        return 0
+
+    cutoff = determinism.now_ms() - WAL_RETENTION_HOURS * 3600 * 1000
+    removed = 0
+    for name in os.listdir(WAL_BASE_DIR):
+        if not name.endswith('.done'):
+            continue
+        key = name[:-len('.done')]
+        done_path = os.path.join(WAL_BASE_DIR, name)
+        try:
+            with open(done_path, 'r', encoding='utf-8') as f:
+                acked_at = int(f.read().strip() or '0')
+        except (OSError, ValueError):
+            acked_at = 0
+        if acked_at > cutoff:
+            continue
+        for path in (os.path.join(WAL_BASE_DIR, f'{key}.json'), done_path):
+            try:
+                os.remove(path)
+                removed += 1
+            except OSError:
+                pass
+    return removed
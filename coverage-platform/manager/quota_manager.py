@@ -0,0 +1,109 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+存储配额模块
+按仓库统计快照存量与近期上报量，接近配额时给出软性提醒
+（MR评论、配额查询接口），让团队在摄入被拒绝之前就知道该清理或调限额
+
+配额是软性的：本模块只产出警告文案，不拒绝任何摄入
+
+环境变量:
+    REPO_SNAPSHOT_QUOTA: 每仓库的快照条数配额（默认 50000，0=不限制）
+    REPO_DAILY_REPORT_QUOTA: 每仓库每日上报条数配额（默认 2000，0=不限制）
+    QUOTA_WARN_RATIO: 触发提醒的用量比例（默认 0.8）
+"""
+
+import logging
+import os
+import time
+from typing import Dict, Optional
+
+from sqlalchemy import func
+
+from models import CoverageSnapshot, get_db_session
+
+logger = logging.getLogger(__name__)
+
+REPO_SNAPSHOT_QUOTA = int(os.getenv('REPO_SNAPSHOT_QUOTA', '50000'))
+REPO_DAILY_REPORT_QUOTA = int(os.getenv('REPO_DAILY_REPORT_QUOTA', '2000'))
+QUOTA_WARN_RATIO = float(os.getenv('QUOTA_WARN_RATIO', '0.8'))
+
+DAY_MS = 24 * 3600 * 1000
+
+
+def get_quota_status(repo_id: str) -> Dict:
+    """
+    获取仓库的配额用量
+
+    返回:
+        Dict: {
+            'repo_id',
+            'snapshots': {'used', 'quota', 'ratio'},
+            'daily_reports': {'used', 'quota', 'ratio'},
+            'approaching': 是否接近任一配额,
+            'warnings': [提醒文案]
+        }
+    """
+    db = get_db_session()
+    try:
+        snapshot_count = db.query(func.count(CoverageSnapshot.id)).filter(
+            CoverageSnapshot.repo_id == repo_id
+        ).scalar() or 0
+
+        day_ago = int(time.time() * 1000) - DAY_MS
+        daily_count = db.query(func.count(CoverageSnapshot.id)).filter(
+            CoverageSnapshot.repo_id == repo_id,
+            CoverageSnapshot.received_at > day_ago
+        ).scalar() or 0
+    finally:
+        db.close()
+
+    def usage(used, quota):
+        ratio = (used / quota) if quota > 0 else 0.0
+        return {'used': used, 'quota': quota, 'ratio': round(ratio, 4)}
+
+    snapshots = usage(snapshot_count, REPO_SNAPSHOT_QUOTA)
+    daily = usage(daily_count, REPO_DAILY_REPORT_QUOTA)
+
+    warnings = []
+    if snapshots['ratio'] >= QUOTA_WARN_RATIO:
+        warnings.append(
+            f"存储配额：已使用 {snapshots['used']}/{snapshots['quota']} 条快照"
+            f"（{snapshots['ratio'] * 100:.0f}%），接近上限后历史趋势会被截断，"
+            "建议缩短保留期或联系平台调整配额"
+        )
+    if daily['ratio'] >= QUOTA_WARN_RATIO:
+        warnings.append(
+            f"上报配额：近24小时已上报 {daily['used']}/{daily['quota']} 次"
+            f"（{daily['ratio'] * 100:.0f}%），超限后新报告可能被延迟处理，"
+            "建议检查CI是否存在重复上报"
+        )
+
+    return {
+        'repo_id': repo_id,
+        'snapshots': snapshots,
+        'daily_reports': daily,
+        'approaching': bool(warnings),
+        'warnings': warnings
+    }
+
+
+def build_mr_notice(repo_id: str) -> Optional[str]:
+    """
+    生成MR评论用的配额提醒文案（markdown）
+    未接近配额返回 None；统计失败也返回 None（提醒绝不阻塞主流程）
+    """
+    try:
+        status = get_quota_status(repo_id)
+    except Exception as e:
+        logger.warning(f"Failed to get quota status for {repo_id}: {e}")
+        return None
+
+    if not status['approaching']:
+        return None
+
+    lines = ['> :warning: **覆盖率平台配额提醒**（不影响本次合并）']
+    for warning in status['warnings']:
+        lines.append(f'> - {warning}')
+    return '\n'.join(lines)
@@ -0,0 +1,231 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+队列-存储对账模块
+通过RabbitMQ management API读取队列的累计ack计数，与同一时间窗口内
+落盘的报告条数比对，差值超阈值时告警——路由键写错、worker崩溃等
+导致的静默丢消息靠这里自动发现，而不是等用户来报"数据少了"
+
+对账是近似的：
+    - coverage消息按快照表received_at计数（每条成功处理插一条快照）
+    - mutation/test_results消息按同一批次created_at去重计数
+    - 消费失败重发的消息会ack原消息再重新入队，broker侧会多计一次
+差值阈值RECONCILE_GAP_THRESHOLD用于吸收这些毛刺
+
+环境变量:
+    RABBITMQ_MGMT_URL: management API地址（如 http://rabbitmq:15672），未设置时不启用
+    RABBITMQ_MGMT_USER/RABBITMQ_MGMT_PASSWORD: management API账号
+    RABBITMQ_MGMT_VHOST: 队列所在vhost（默认 /）
+    RECONCILE_QUEUE: 对账的队列名（默认 coverage_queue）
+    RECONCILE_GAP_THRESHOLD: 允许的差值上限，超过才告警（默认 5）
+"""
+
+import logging
+import os
+import time
+from typing import Dict, List, Optional
+from urllib.parse import quote
+
+import requests
+from sqlalchemy import distinct, func
+
+from models import (
+    CoverageSnapshot, MutationRecord, TestRunResult, ReconciliationCheck,
+    get_db_session
+)
+from manager.status_manager import record_heartbeat
+
+logger = logging.getLogger(__name__)
+
+RABBITMQ_MGMT_URL = os.getenv('RABBITMQ_MGMT_URL', '').rstrip('/')
+RABBITMQ_MGMT_USER = os.getenv('RABBITMQ_MGMT_USER', 'coverage')
+RABBITMQ_MGMT_PASSWORD = os.getenv('RABBITMQ_MGMT_PASSWORD', 'coverage123')
+RABBITMQ_MGMT_VHOST = os.getenv('RABBITMQ_MGMT_VHOST', '/')
+# 对账的队列名默认跟随consumer的队列命名配置
+RECONCILE_QUEUE = os.getenv(
+    'RECONCILE_QUEUE', os.getenv('COVERAGE_QUEUE', 'coverage_queue')
+)
+RECONCILE_GAP_THRESHOLD = int(os.getenv('RECONCILE_GAP_THRESHOLD', '5'))
+
+REQUEST_TIMEOUT = 30
+
+STATUS_BASELINE = 'baseline'  # 首轮采样，无可比窗口
+STATUS_OK = 'ok'
+STATUS_GAP = 'gap'
+STATUS_RESET = 'reset'  # broker计数回退（重启），跳过本窗口比对
+
+
+def is_enabled() -> bool:
+    """是否启用对账（需要配置management API地址）"""
+    return bool(RABBITMQ_MGMT_URL)
+
+
+def get_broker_ack_total() -> Optional[int]:
+    """
+    读取队列的累计ack计数
+    失败返回None（management API不可达、队列不存在等）
+    """
+    try:
+        response = requests.get(
+            f'{RABBITMQ_MGMT_URL}/api/queues/'
+            f'{quote(RABBITMQ_MGMT_VHOST, safe="")}/{quote(RECONCILE_QUEUE)}',
+            auth=(RABBITMQ_MGMT_USER, RABBITMQ_MGMT_PASSWORD),
+            timeout=REQUEST_TIMEOUT
+        )
+        if response.status_code != 200:
+            logger.warning(
+                f"Failed to query broker queue stats: HTTP {response.status_code}"
+            )
+            return None
+        stats = response.json().get('message_stats') or {}
+        # 队列从未有过消息时message_stats可能整体缺失
+        return int(stats.get('ack', 0))
+    except Exception as e:
+        logger.warning(f"Error querying broker queue stats: {e}")
+        return None
+
+
+def count_persisted(window_start: int, window_end: int) -> int:
+    """
+    统计窗口内落盘的报告数（按消息批次近似）
+    窗口区间为左开右闭：(window_start, window_end]
+    """
+    db = get_db_session()
+    try:
+        snapshots = db.query(func.count(CoverageSnapshot.id)).filter(
+            CoverageSnapshot.received_at > window_start,
+            CoverageSnapshot.received_at <= window_end
+        ).scalar() or 0
+
+        # mutation/test_results一条消息落多行，但同事务内created_at相同，
+        # 按created_at去重近似成消息数
+        mutation_batches = db.query(
+            func.count(distinct(MutationRecord.created_at))
+        ).filter(
+            MutationRecord.created_at > window_start,
+            MutationRecord.created_at <= window_end
+        ).scalar() or 0
+
+        test_batches = db.query(
+            func.count(distinct(TestRunResult.created_at))
+        ).filter(
+            TestRunResult.created_at > window_start,
+            TestRunResult.created_at <= window_end
+        ).scalar() or 0
+
+        return int(snapshots) + int(mutation_batches) + int(test_batches)
+    finally:
+        db.close()
+
+
+def run_reconciliation() -> Optional[Dict]:
+    """
+    执行一轮对账
+
+    流程:
+        1. 采样broker累计ack计数
+        2. 与上一轮采样做差得到窗口内broker确认数
+        3. 统计同窗口内落盘条数，差值超阈值则告警
+    首轮只记录基线；broker计数回退（重启清零）时跳过本窗口比对
+
+    返回:
+        Optional[Dict]: 本轮对账结果，未启用或broker不可达返回None
+    """
+    if not is_enabled():
+        logger.info("Reconciliation disabled (RABBITMQ_MGMT_URL not set)")
+        return None
+
+    ack_total = get_broker_ack_total()
+    if ack_total is None:
+        record_heartbeat('reconciliation', status='error',
+                         detail='broker management API unreachable')
+        return None
+
+    now = int(time.time() * 1000)
+
+    db = get_db_session()
+    try:
+        last = db.query(ReconciliationCheck).order_by(
+            ReconciliationCheck.created_at.desc()
+        ).first()
+
+        if last is None:
+            check = ReconciliationCheck(
+                status=STATUS_BASELINE,
+                window_start=now,
+                window_end=now,
+                broker_acked=0,
+                persisted_count=0,
+                gap=0,
+                broker_ack_total=ack_total,
+                created_at=now
+            )
+        elif ack_total < last.broker_ack_total:
+            # broker重启后计数清零，本窗口无法比对，重新建立基线
+            logger.warning(
+                f"Broker ack counter went backwards "
+                f"({last.broker_ack_total} -> {ack_total}), resetting baseline"
+            )
+            check = ReconciliationCheck(
+                status=STATUS_RESET,
+                window_start=last.window_end,
+                window_end=now,
+                broker_acked=0,
+                persisted_count=0,
+                gap=0,
+                broker_ack_total=ack_total,
+                created_at=now
+            )
+        else:
+            broker_acked = ack_total - last.broker_ack_total
+            persisted = count_persisted(last.window_end, now)
+            gap = broker_acked - persisted
+            check = ReconciliationCheck(
+                status=STATUS_GAP if gap > RECONCILE_GAP_THRESHOLD else STATUS_OK,
+                window_start=last.window_end,
+                window_end=now,
+                broker_acked=broker_acked,
+                persisted_count=persisted,
+                gap=gap,
+                broker_ack_total=ack_total,
+                created_at=now
+            )
+
+        db.add(check)
+        db.commit()
+        result = check.to_dict()
+    except Exception as e:
+        db.rollback()
+        logger.error(f"Failed to run reconciliation: {e}")
+        raise
+    finally:
+        db.close()
+
+    if result['status'] == STATUS_GAP:
+        logger.error(
+            f"[对账告警] 窗口内broker确认{result['broker_acked']}条消息, "
+            f"仅落盘{result['persisted_count']}条, 差值{result['gap']}超过阈值"
+            f"{RECONCILE_GAP_THRESHOLD}, 可能存在静默丢消息"
+        )
+        record_heartbeat(
+            'reconciliation', status='error',
+            detail=f"gap={result['gap']} in window "
+                   f"{result['window_start']}-{result['window_end']}"
+        )
+    else:
+        record_heartbeat('reconciliation')
+
+    return result
+
+
+def list_checks(limit: int = 50) -> List[Dict]:
+    """列出最近的对账记录（从新到旧）"""
+    db = get_db_session()
+    try:
+        checks = db.query(ReconciliationCheck).order_by(
+            ReconciliationCheck.created_at.desc()
+        ).limit(limit).all()
+        return [c.to_dict() for c in checks]
+    finally:
+        db.close()
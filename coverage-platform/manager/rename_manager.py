@@ -0,0 +1,152 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+文件重命名跟踪业务逻辑管理层
+入库时用git的重命名检测（percent-changed相似度启发式）找出
+base_commit到本次commit之间的重命名，落库old_path->new_path映射；
+文件历史查询沿映射链回溯旧名，重命名后的文件不会在趋势里
+变成一个历史为空的"新文件"
+
+diff覆盖率侧parse_git_diff本身带-M重命名检测，重命名文件只有
+实际改动的行会被算作新增行，无需在此处理
+
+环境变量:
+    RENAME_TRACKING_ENABLED: 是否跟踪重命名（默认1开启）
+    RENAME_SIMILARITY_THRESHOLD: 相似度阈值百分比（默认50，
+        对应git diff -M50%，调高可减少误判）
+"""
+
+import logging
+import os
+import subprocess
+import time
+from typing import Dict, List, Optional
+
+from models import FileRename
+
+logger = logging.getLogger(__name__)
+
+RENAME_TRACKING_ENABLED = os.environ.get('RENAME_TRACKING_ENABLED', '1') == '1'
+RENAME_SIMILARITY_THRESHOLD = int(
+    os.environ.get('RENAME_SIMILARITY_THRESHOLD', '50')
+)
+
+# 回溯重命名链的最大深度（防环）
+MAX_CHAIN_DEPTH = 5
+
+
+def detect_renames(bare_repo_path: str, base_commit: str,
+                   target_commit: str) -> Optional[List[Dict]]:
+    """
+    检测两个commit之间的文件重命名
+
+    返回:
+        Optional[List[Dict]]: [{'old_path', 'new_path', 'similarity'}]，
+        git diff失败返回 None
+    """
+    try:
+        result = subprocess.run(
+            ['git', 'diff', '--name-status', '--diff-filter=R',
+             f'-M{RENAME_SIMILARITY_THRESHOLD}%',
+             base_commit, target_commit],
+            cwd=bare_repo_path,
+            capture_output=True,
+            text=True,
+            timeout=60
+        )
+        if result.returncode != 0:
+            logger.warning(f"git diff failed: {result.stderr}")
+            return None
+
+        renames = []
+        for line in result.stdout.splitlines():
+            parts = line.split('\t')
+            # 格式: R<相似度>\t<旧路径>\t<新路径>
+            if len(parts) != 3 or not parts[0].startswith('R'):
+                continue
+            try:
+                similarity = int(parts[0][1:])
+            except ValueError:
+                similarity = 0
+            renames.append({
+                'old_path': parts[1],
+                'new_path': parts[2],
+                'similarity': similarity
+            })
+        return renames
+    except Exception as e:
+        logger.warning(f"Failed to detect renames: {e}")
+        return None
+
+
+def record_renames(db, repo_id: str, branch: str, repo_url: str,
+                   base_commit: str, commit: str) -> int:
+    """
+    检测并记录base_commit到commit之间的重命名（幂等，复用调用方事务）
+
+    返回:
+        int: 本次新记录的重命名数
+    """
+    if not RENAME_TRACKING_ENABLED:
+        return 0
+
+    from manager.repo_manager import get_bare_repo_path
+
+    bare_repo_path = get_bare_repo_path(repo_url)
+    renames = detect_renames(bare_repo_path, base_commit, commit)
+    if not renames:
+        return 0
+
+    now = int(time.time() * 1000)
+    recorded = 0
+    for rename in renames:
+        # CI重试会重复处理同一commit，已记录的跳过
+        existing = db.query(FileRename).filter(
+            FileRename.repo_id == repo_id,
+            FileRename.branch == branch,
+            FileRename.new_path == rename['new_path'],
+            FileRename.commit == commit
+        ).first()
+        if existing:
+            continue
+        db.add(FileRename(
+            repo_id=repo_id,
+            branch=branch,
+            old_path=rename['old_path'],
+            new_path=rename['new_path'],
+            similarity=rename['similarity'],
+            commit=commit,
+            created_at=now
+        ))
+        recorded += 1
+
+    if recorded:
+        logger.info(
+            f"Recorded {recorded} file renames: repo_id={repo_id}, "
+            f"branch={branch}, commit={commit}"
+        )
+    return recorded
+
+
+def history_paths(db, repo_id: str, branch: str, file_path: str) -> List[str]:
+    """
+    沿重命名链回溯文件的历史路径
+
+    返回:
+        List[str]: [当前路径, 上一个名字, ...]（最新的在前，含当前路径；
+        无重命名记录时只有当前路径）
+    """
+    paths = [file_path]
+    current = file_path
+    for _ in range(MAX_CHAIN_DEPTH):
+        rename = db.query(FileRename).filter(
+            FileRename.repo_id == repo_id,
+            FileRename.branch == branch,
+            FileRename.new_path == current
+        ).order_by(FileRename.created_at.desc()).first()
+        if not rename or rename.old_path in paths:
+            break
+        paths.append(rename.old_path)
+        current = rename.old_path
+    return paths
@@ -593,6 +593,10 @@ def clone_or_update_repo(repo_url: str, repo_id: str, branch: str, commit: str)
         bool: 是否成功
     """
     try:
+        # 故障注入点（staging验证git故障下的降级路径，默认空操作）
+        from manager import chaos_manager
+        chaos_manager.maybe_inject('git')
+
         ensure_repos_dir()
         project_name = extract_project_name(repo_url)
         
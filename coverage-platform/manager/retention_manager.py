@@ -0,0 +1,238 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+分支保留与清理模块
+消费分支删除webhook：数据先标记为stale（报告列表可过滤掉），
+到达保留期后再真正删除覆盖率数据，
+避免仓库视图被几千个已删除的feature分支淹没
+
+清理不自动后台执行，由定时任务调用清理接口触发（平台无常驻调度器）
+
+环境变量:
+    BRANCH_RETENTION_DAYS: 分支删除后数据保留天数（默认30）
+"""
+
+import logging
+import os
+import time
+from typing import Dict, List, Optional
+
+from models import (
+    StaleBranch, CoverageReport, CoverageFile, CoverageRange,
+    CoverageSnapshot, CoverageFileSnapshot, get_db_session
+)
+
+logger = logging.getLogger(__name__)
+
+# 分支删除后数据保留天数
+BRANCH_RETENTION_DAYS = int(os.getenv('BRANCH_RETENTION_DAYS', '30'))
+
+
+def mark_branch_deleted(repo_id: str, branch: str) -> Dict:
+    """
+    标记分支已删除，数据进入保留期
+    重复的webhook（同分支已标记且未清理）只刷新删除时间
+    """
+    db = get_db_session()
+    try:
+        now = int(time.time() * 1000)
+        purge_after = now + BRANCH_RETENTION_DAYS * 24 * 3600 * 1000
+
+        stale = db.query(StaleBranch).filter(
+            StaleBranch.repo_id == repo_id,
+            StaleBranch.branch == branch,
+            StaleBranch.status == 'stale'
+        ).first()
+        if stale:
+            stale.deleted_at = now
+            stale.purge_after = purge_after
+        else:
+            stale = StaleBranch(
+                repo_id=repo_id,
+                branch=branch,
+                status='stale',
+                deleted_at=now,
+                purge_after=purge_after
+            )
+            db.add(stale)
+        db.commit()
+
+        logger.info(
+            f"Marked branch as stale: repo_id={repo_id}, branch={branch}, "
+            f"purge_after={purge_after}"
+        )
+        return stale.to_dict()
+    except Exception as e:
+        db.rollback()
+        logger.error(f"Failed to mark branch deleted: {e}")
+        raise
+    finally:
+        db.close()
+
+
+def get_stale_branches(repo_id: Optional[str] = None) -> List[Dict]:
+    """列出处于保留期的分支（报告列表用它过滤死分支）"""
+    db = get_db_session()
+    try:
+        query = db.query(StaleBranch).filter(StaleBranch.status == 'stale')
+        if repo_id:
+            query = query.filter(StaleBranch.repo_id == repo_id)
+        return [s.to_dict() for s in query.order_by(StaleBranch.deleted_at.desc()).all()]
+    finally:
+        db.close()
+
+
+def _purge_branch_data(db, repo_id: str, branch: str) -> int:
+    """删除分支的全部覆盖率数据，返回删除的文件数"""
+    files = db.query(CoverageFile).filter(
+        CoverageFile.repo_id == repo_id,
+        CoverageFile.branch == branch
+    ).all()
+    for file in files:
+        db.query(CoverageRange).filter(
+            CoverageRange.file_id == file.id
+        ).delete()
+        db.delete(file)
+
+    db.query(CoverageReport).filter(
+        CoverageReport.repo_id == repo_id,
+        CoverageReport.branch == branch
+    ).delete()
+    db.query(CoverageSnapshot).filter(
+        CoverageSnapshot.repo_id == repo_id,
+        CoverageSnapshot.branch == branch
+    ).delete()
+    db.query(CoverageFileSnapshot).filter(
+        CoverageFileSnapshot.repo_id == repo_id,
+        CoverageFileSnapshot.branch == branch
+    ).delete()
+    return len(files)
+
+
+def prune_expired_branches() -> Dict:
+    """
+    清理保留期已过的失效分支数据
+
+    返回:
+        Dict: {'pruned': 清理的分支数, 'branches': [{'repo_id', 'branch', 'files'}]}
+    """
+    db = get_db_session()
+    try:
+        now = int(time.time() * 1000)
+        expired = db.query(StaleBranch).filter(
+            StaleBranch.status == 'stale',
+            StaleBranch.purge_after <= now
+        ).all()
+
+        pruned = []
+        for stale in expired:
+            file_count = _purge_branch_data(db, stale.repo_id, stale.branch)
+            stale.status = 'purged'
+            stale.purged_at = now
+            pruned.append({
+                'repo_id': stale.repo_id,
+                'branch': stale.branch,
+                'files': file_count
+            })
+            logger.info(
+                f"Pruned stale branch: repo_id={stale.repo_id}, "
+                f"branch={stale.branch}, files={file_count}"
+            )
+        db.commit()
+        return {'pruned': len(pruned), 'branches': pruned}
+    except Exception as e:
+        db.rollback()
+        logger.error(f"Failed to prune expired branches: {e}")
+        raise
+    finally:
+        db.close()
+
+
+# 行级数据降采样：受保护分支永远保留全量行级数据，
+# feature分支超过N天没有新报告后只保留文件级摘要
+DOWNSAMPLE_AFTER_DAYS = int(os.getenv('DOWNSAMPLE_AFTER_DAYS', '14'))
+# 除各仓库配置的base_branch外，额外视为受保护的分支（逗号分隔）
+DOWNSAMPLE_PROTECTED_BRANCHES = [
+    b.strip() for b in os.getenv(
+        'DOWNSAMPLE_PROTECTED_BRANCHES', 'master,main'
+    ).split(',') if b.strip()
+]
+
+
+def downsample_feature_branches() -> Dict:
+    """
+    降采样不活跃feature分支的行级数据
+
+    对每个仓库，base_branch和DOWNSAMPLE_PROTECTED_BRANCHES之外的分支，
+    若最近一次报告早于DOWNSAMPLE_AFTER_DAYS天前，删除其CoverageRange
+    行级数据并把文件标记为downsampled；文件级摘要（CoverageFileSnapshot）
+    保留，列表/趋势不受影响，只是看不到逐行详情
+    该分支后续再有新报告时行级数据会重新入库，标记自动作废
+
+    返回:
+        Dict: {'downsampled': 处理的分支数,
+               'branches': [{'repo_id', 'branch', 'files', 'ranges'}]}
+    """
+    from models import CoverageConfig
+
+    db = get_db_session()
+    try:
+        cutoff = int(time.time() * 1000) - DOWNSAMPLE_AFTER_DAYS * 24 * 3600 * 1000
+
+        protected_by_repo = {
+            config.repo_id: config.base_branch
+            for config in db.query(CoverageConfig).all()
+        }
+
+        # 候选：有未降采样文件的(repo, branch)组合
+        pairs = db.query(
+            CoverageFile.repo_id, CoverageFile.branch
+        ).filter(CoverageFile.downsampled == 0).distinct().all()
+
+        processed = []
+        for repo_id, branch in pairs:
+            if branch == protected_by_repo.get(repo_id):
+                continue
+            if branch in DOWNSAMPLE_PROTECTED_BRANCHES:
+                continue
+
+            # 分支最近一次报告仍在活跃窗口内则跳过
+            latest = db.query(CoverageReport).filter(
+                CoverageReport.repo_id == repo_id,
+                CoverageReport.branch == branch
+            ).order_by(CoverageReport.created_at.desc()).first()
+            if not latest or latest.created_at > cutoff:
+                continue
+
+            files = db.query(CoverageFile).filter(
+                CoverageFile.repo_id == repo_id,
+                CoverageFile.branch == branch,
+                CoverageFile.downsampled == 0
+            ).all()
+            range_count = 0
+            for file in files:
+                range_count += db.query(CoverageRange).filter(
+                    CoverageRange.file_id == file.id
+                ).delete()
+                file.downsampled = 1
+
+            processed.append({
+                'repo_id': repo_id,
+                'branch': branch,
+                'files': len(files),
+                'ranges': range_count
+            })
+            logger.info(
+                f"Downsampled feature branch: repo_id={repo_id}, "
+                f"branch={branch}, files={len(files)}, ranges={range_count}"
+            )
+
+        db.commit()
+        return {'downsampled': len(processed), 'branches': processed}
+    except Exception as e:
+        db.rollback()
+        logger.error(f"Failed to downsample feature branches: {e}")
+        raise
+    finally:
+        db.close()
@@ -0,0 +1,218 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+Reviewer建议模块
+为MR推荐"写过相关测试"的reviewer：找出覆盖被改代码的测试文件，
+对其git blame统计作者，按贡献行数排序——让懂这块测试的人参与review
+
+平台没有采集per-test粒度的覆盖率，测试文件按语言约定映射：
+    - Go: 同目录的 <文件名>_test.go 及同包其他 _test.go
+    - Python: 同目录/tests目录下的 test_<文件名>.py
+直接对应的测试文件权重更高（2倍），同包其他测试文件记1倍
+"""
+
+import logging
+import os
+import subprocess
+from typing import Dict, List, Optional
+
+from models import CoverageReport, CoverageConfig, get_db_session
+from manager.repo_manager import get_bare_repo_path
+from manager.diff_manager import get_base_branch_for_repo, get_base_commit_from_git
+from diff_coverage import parse_git_diff
+
+logger = logging.getLogger(__name__)
+
+# 直接对应的测试文件blame行数的权重倍数
+DIRECT_TEST_WEIGHT = 2
+
+# 单次建议最多blame的测试文件数（防止超大MR拖垮接口）
+MAX_TEST_FILES = 50
+
+
+def _is_test_file(file_path: str) -> bool:
+    """判断是否为测试文件（Go/Python约定）"""
+    name = os.path.basename(file_path)
+    return name.endswith('_test.go') or (
+        name.startswith('test_') and name.endswith('.py')
+    )
+
+
+def _list_tree_files(bare_repo_path: str, commit: str, directory: str) -> List[str]:
+    """列出commit下指定目录的文件（不递归），失败返回空列表"""
+    try:
+        target = f'{commit}:{directory}' if directory else commit
+        result = subprocess.run(
+            ['git', 'ls-tree', '--name-only', target],
+            cwd=bare_repo_path,
+            capture_output=True,
+            text=True,
+            timeout=30
+        )
+        if result.returncode != 0:
+            return []
+        prefix = f'{directory}/' if directory else ''
+        return [prefix + line.strip()
+                for line in result.stdout.splitlines() if line.strip()]
+    except Exception as e:
+        logger.warning(f"Failed to list tree {directory}@{commit}: {e}")
+        return []
+
+
+def _candidate_test_files(bare_repo_path: str, commit: str,
+                          file_path: str) -> List[Dict]:
+    """
+    找出覆盖指定源文件的候选测试文件
+
+    返回:
+        List[Dict]: [{'path': 测试文件路径, 'weight': 权重}]
+    """
+    directory = os.path.dirname(file_path)
+    base_name = os.path.basename(file_path)
+    candidates = []
+
+    if file_path.endswith('.go'):
+        direct = base_name[:-3] + '_test.go'
+        for sibling in _list_tree_files(bare_repo_path, commit, directory):
+            name = os.path.basename(sibling)
+            if not name.endswith('_test.go'):
+                continue
+            weight = DIRECT_TEST_WEIGHT if name == direct else 1
+            candidates.append({'path': sibling, 'weight': weight})
+    elif file_path.endswith('.py'):
+        direct = 'test_' + base_name
+        search_dirs = [directory]
+        search_dirs.append(os.path.join(directory, 'tests') if directory else 'tests')
+        for search_dir in search_dirs:
+            for sibling in _list_tree_files(bare_repo_path, commit, search_dir):
+                name = os.path.basename(sibling)
+                if name == direct:
+                    candidates.append({'path': sibling, 'weight': DIRECT_TEST_WEIGHT})
+
+    return candidates
+
+
+def _blame_authors(bare_repo_path: str, commit: str,
+                   file_path: str) -> Dict[str, Dict]:
+    """
+    git blame统计测试文件的各作者行数
+
+    返回:
+        Dict[str, Dict]: {email: {'author': 姓名, 'lines': 行数}}
+    """
+    try:
+        result = subprocess.run(
+            ['git', 'blame', '--line-porcelain', commit, '--', file_path],
+            cwd=bare_repo_path,
+            capture_output=True,
+            text=True,
+            timeout=60
+        )
+        if result.returncode != 0:
+            return {}
+    except Exception as e:
+        logger.warning(f"Failed to blame {file_path}@{commit}: {e}")
+        return {}
+
+    authors = {}
+    current_author = ''
+    for line in result.stdout.splitlines():
+        if line.startswith('author '):
+            current_author = line[len('author '):]
+        elif line.startswith('author-mail '):
+            email = line[len('author-mail '):].strip('<>')
+            entry = authors.setdefault(
+                email, {'author': current_author, 'lines': 0}
+            )
+            entry['lines'] += 1
+    return authors
+
+
+def suggest_reviewers(report_id: int, limit: int = 5) -> Optional[Dict]:
+    """
+    为报告对应的MR建议reviewer
+
+    返回:
+        Optional[Dict]: {
+            'report_id', 'base_commit', 'base_branch',
+            'suggestions': [{'author', 'email', 'score',
+                             'test_files': [测试文件路径]}]
+        }
+        score为blame行数按权重加权后的总和，降序排列
+    """
+    db = get_db_session()
+    try:
+        report = db.query(CoverageReport).filter(
+            CoverageReport.id == report_id
+        ).first()
+        if not report:
+            logger.error(f"Report not found: {report_id}")
+            return None
+        # 仓库URL在配置表里（报告上没有存）
+        config = db.query(CoverageConfig).filter(
+            CoverageConfig.repo_id == report.repo_id
+        ).first()
+        if not config:
+            logger.error(f"Config not found for repo: {report.repo_id}")
+            return None
+        repo_url = config.repo_url
+        repo_id = report.repo_id
+        target_commit = report.commit
+    finally:
+        db.close()
+
+    base_branch = get_base_branch_for_repo(repo_id)
+    bare_repo_path = get_bare_repo_path(repo_url)
+    if not bare_repo_path:
+        logger.error("Failed to get bare repo path")
+        return None
+
+    base_commit = get_base_commit_from_git(bare_repo_path, base_branch, target_commit)
+    if not base_commit:
+        logger.error("Failed to get base commit")
+        return None
+
+    diff_hunks = parse_git_diff(bare_repo_path, base_commit, target_commit)
+    changed_files = {h.file_path for h in diff_hunks
+                     if not _is_test_file(h.file_path)}
+
+    # 源文件 -> 候选测试文件（去重，同一测试文件取最高权重）
+    test_files = {}
+    for file_path in sorted(changed_files):
+        for candidate in _candidate_test_files(
+            bare_repo_path, target_commit, file_path
+        ):
+            existing = test_files.get(candidate['path'], 0)
+            test_files[candidate['path']] = max(existing, candidate['weight'])
+        if len(test_files) >= MAX_TEST_FILES:
+            break
+
+    # 按作者聚合blame行数
+    scores = {}
+    for test_path, weight in list(test_files.items())[:MAX_TEST_FILES]:
+        for email, info in _blame_authors(
+            bare_repo_path, target_commit, test_path
+        ).items():
+            entry = scores.setdefault(
+                email, {'author': info['author'], 'email': email,
+                        'score': 0, 'test_files': set()}
+            )
+            entry['score'] += info['lines'] * weight
+            entry['test_files'].add(test_path)
+
+    suggestions = sorted(scores.values(), key=lambda s: s['score'], reverse=True)
+    return {
+        'report_id': report_id,
+        'base_commit': base_commit,
+        'base_branch': base_branch,
+        'suggestions': [
+            {
+                'author': s['author'],
+                'email': s['email'],
+                'score': s['score'],
+                'test_files': sorted(s['test_files'])
+            }
+            for s in suggestions[:limit]
+        ]
+    }
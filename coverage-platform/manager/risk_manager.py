@@ -0,0 +1,164 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+风险评分模块
+将增量覆盖率、变更规模、历史覆盖率波动综合为单一风险分（0-100，越高越危险）
+权重可通过环境变量配置，供评审者快速分流用
+"""
+
+import logging
+import math
+import os
+from typing import Dict, Optional
+
+from models import CoverageReport, CoverageConfig, CoverageSnapshot, get_db_session
+from manager.diff_manager import calculate_diff_coverage
+
+logger = logging.getLogger(__name__)
+
+# 各分量权重（支持环境变量覆盖，和为1时结果落在0-100）
+WEIGHT_PATCH_COVERAGE = float(os.getenv('RISK_WEIGHT_PATCH_COVERAGE', '0.5'))
+WEIGHT_CHANGE_SIZE = float(os.getenv('RISK_WEIGHT_CHANGE_SIZE', '0.3'))
+WEIGHT_VOLATILITY = float(os.getenv('RISK_WEIGHT_VOLATILITY', '0.2'))
+
+# 变更规模归一化参考值：新增行数达到该值时规模分量记满分
+CHANGE_SIZE_REFERENCE_LINES = int(os.getenv('RISK_CHANGE_SIZE_REFERENCE', '1000'))
+
+# 波动率计算取最近的快照数量
+VOLATILITY_SNAPSHOT_COUNT = 10
+
+
+def _patch_coverage_component(summary: Dict) -> float:
+    """
+    增量覆盖率分量：覆盖率越低风险越高
+    返回 0-1
+    """
+    total_new_lines = summary.get('total_new_lines', 0)
+    if total_new_lines == 0:
+        # 没有新增可覆盖行，视为无风险
+        return 0.0
+    rate = summary.get('incremental_coverage_rate', 0.0)
+    return max(0.0, min(1.0, 1.0 - rate / 100.0))
+
+
+def _change_size_component(summary: Dict) -> float:
+    """
+    变更规模分量：新增行数对数缩放后归一化
+    返回 0-1
+    """
+    total_new_lines = summary.get('total_new_lines', 0)
+    if total_new_lines <= 0:
+        return 0.0
+    # 对数缩放，避免超大MR把分量直接打满
+    scaled = math.log1p(total_new_lines) / math.log1p(CHANGE_SIZE_REFERENCE_LINES)
+    return max(0.0, min(1.0, scaled))
+
+
+def _volatility_component(repo_id: str, branch: str) -> float:
+    """
+    历史波动分量：最近快照覆盖率的标准差归一化
+    没有缺陷密度数据，以覆盖率波动作为代码稳定性的近似信号
+    返回 0-1
+    """
+    db = get_db_session()
+    try:
+        snapshots = db.query(CoverageSnapshot).filter(
+            CoverageSnapshot.repo_id == repo_id,
+            CoverageSnapshot.branch == branch
+        ).order_by(
+            CoverageSnapshot.created_at.desc()
+        ).limit(VOLATILITY_SNAPSHOT_COUNT).all()
+
+        if len(snapshots) < 3:
+            return 0.0
+
+        rates = [s.coverage_rate / 100.0 for s in snapshots]
+        mean = sum(rates) / len(rates)
+        variance = sum((r - mean) ** 2 for r in rates) / len(rates)
+        stddev = math.sqrt(variance)
+
+        # 标准差达到10个百分点视为满分波动
+        return max(0.0, min(1.0, stddev / 10.0))
+    finally:
+        db.close()
+
+
+def calculate_risk_score(report_id: int) -> Optional[Dict]:
+    """
+    计算指定报告的风险评分
+
+    参数:
+        report_id: 报告 ID
+
+    返回:
+        Optional[Dict]: 风险评分结果（总分、各分量、权重），失败返回 None
+    """
+    db = get_db_session()
+    try:
+        report = db.query(CoverageReport).filter(
+            CoverageReport.id == report_id
+        ).first()
+        if not report:
+            logger.error(f"Report not found: {report_id}")
+            return None
+
+        config = db.query(CoverageConfig).filter(
+            CoverageConfig.repo_id == report.repo_id
+        ).first()
+        if not config:
+            logger.error(f"Config not found for repo: {report.repo_id}")
+            return None
+
+        repo_id = report.repo_id
+        branch = report.branch
+        commit = report.commit
+        repo_url = config.repo_url
+    finally:
+        db.close()
+
+    # 计算增量覆盖率（复用 diff_manager）
+    diff_result = calculate_diff_coverage(
+        repo_url=repo_url,
+        repo_id=repo_id,
+        target_branch=branch,
+        target_commit=commit
+    )
+    if not diff_result:
+        logger.error(f"Failed to calculate diff coverage for risk score: report={report_id}")
+        return None
+
+    summary = diff_result['summary']
+
+    patch_score = _patch_coverage_component(summary)
+    size_score = _change_size_component(summary)
+    volatility_score = _volatility_component(repo_id, branch)
+
+    total = (
+        patch_score * WEIGHT_PATCH_COVERAGE +
+        size_score * WEIGHT_CHANGE_SIZE +
+        volatility_score * WEIGHT_VOLATILITY
+    ) * 100
+
+    return {
+        'report_id': report_id,
+        'risk_score': round(total, 1),
+        'components': {
+            'patch_coverage': {
+                'score': round(patch_score * 100, 1),
+                'weight': WEIGHT_PATCH_COVERAGE,
+                'incremental_coverage_rate': summary.get('incremental_coverage_rate', 0.0)
+            },
+            'change_size': {
+                'score': round(size_score * 100, 1),
+                'weight': WEIGHT_CHANGE_SIZE,
+                'total_new_lines': summary.get('total_new_lines', 0)
+            },
+            'volatility': {
+                'score': round(volatility_score * 100, 1),
+                'weight': WEIGHT_VOLATILITY
+            }
+        },
+        'base_commit': diff_result.get('base_commit', ''),
+        'base_branch': diff_result.get('base_branch', '')
+    }
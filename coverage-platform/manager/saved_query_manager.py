@@ -0,0 +1,192 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+保存的查询管理模块
+把一组参数（仓库集合、分支、路径前缀、时间窗口）保存为命名报表，
+通过 API 按名称执行，周期性的管理报表不再需要每次手写脚本
+"""
+
+import logging
+import time
+from typing import Dict, List, Optional
+
+from models import (
+    SavedQuery, CoverageConfig, CoverageSnapshot, CoverageFile, CoverageRange,
+    get_db_session
+)
+from manager import precision
+
+logger = logging.getLogger(__name__)
+
+
+def create_saved_query(name: str, repo_ids: List[str], branch: str = '',
+                       path_prefix: str = '', days: int = 30,
+                       description: str = '', created_by: str = '') -> Optional[Dict]:
+    """
+    创建保存的查询，名称重复时返回 None
+    """
+    db = get_db_session()
+    try:
+        existing = db.query(SavedQuery).filter(
+            SavedQuery.name == name
+        ).first()
+        if existing:
+            logger.warning(f"Saved query already exists: {name}")
+            return None
+
+        now = int(time.time() * 1000)
+        query = SavedQuery(
+            name=name,
+            description=description,
+            repo_ids=','.join(repo_ids),
+            branch=branch,
+            path_prefix=path_prefix,
+            days=days,
+            created_by=created_by,
+            created_at=now,
+            updated_at=now
+        )
+        db.add(query)
+        db.commit()
+        return query.to_dict()
+    except Exception as e:
+        db.rollback()
+        logger.error(f"Failed to create saved query: {e}")
+        raise
+    finally:
+        db.close()
+
+
+def list_saved_queries() -> List[Dict]:
+    """列出全部保存的查询"""
+    db = get_db_session()
+    try:
+        queries = db.query(SavedQuery).order_by(SavedQuery.name).all()
+        return [q.to_dict() for q in queries]
+    finally:
+        db.close()
+
+
+def get_saved_query(query_id: int) -> Optional[Dict]:
+    """按ID获取保存的查询，不存在返回 None"""
+    db = get_db_session()
+    try:
+        query = db.query(SavedQuery).filter(SavedQuery.id == query_id).first()
+        return query.to_dict() if query else None
+    finally:
+        db.close()
+
+
+def delete_saved_query(query_id: int) -> bool:
+    """删除保存的查询，不存在返回 False"""
+    db = get_db_session()
+    try:
+        query = db.query(SavedQuery).filter(SavedQuery.id == query_id).first()
+        if not query:
+            return False
+        db.delete(query)
+        db.commit()
+        return True
+    except Exception as e:
+        db.rollback()
+        logger.error(f"Failed to delete saved query: {e}")
+        raise
+    finally:
+        db.close()
+
+
+def _path_filtered_rate(db, repo_id: str, branch: str,
+                        path_prefix: str) -> Optional[float]:
+    """计算仓库分支上指定路径前缀下的当前覆盖率（百分比）"""
+    files = db.query(CoverageFile).filter(
+        CoverageFile.repo_id == repo_id,
+        CoverageFile.branch == branch
+    ).all()
+
+    total = 0
+    covered = 0
+    for file in files:
+        if not file.file_path.startswith(path_prefix):
+            continue
+        ranges = db.query(CoverageRange).filter(
+            CoverageRange.file_id == file.id
+        ).all()
+        for r in ranges:
+            total += r.statements
+            if r.hit > 0:
+                covered += r.statements
+
+    if total == 0:
+        return None
+    return covered / total * 100
+
+
+def run_saved_query(query_id: int) -> Optional[Dict]:
+    """
+    执行保存的查询
+
+    返回:
+        Optional[Dict]: {
+            'query': 查询定义,
+            'generated_at': 执行时间（毫秒时间戳）,
+            'repos': [{'repo_id', 'branch', 'coverage_rate',
+                       'start_rate', 'delta', 'snapshot_count'}]
+        }
+        路径前缀非空时 coverage_rate 为该前缀下的当前覆盖率，
+        start_rate/delta 为 None（文件级历史不按前缀聚合）
+        查询不存在返回 None
+    """
+    db = get_db_session()
+    try:
+        query = db.query(SavedQuery).filter(SavedQuery.id == query_id).first()
+        if not query:
+            return None
+
+        now = int(time.time() * 1000)
+        window_start = now - query.days * 24 * 3600 * 1000
+
+        repos = []
+        for repo_id in [r for r in (query.repo_ids or '').split(',') if r]:
+            branch = query.branch
+            if not branch:
+                config = db.query(CoverageConfig).filter(
+                    CoverageConfig.repo_id == repo_id
+                ).first()
+                branch = config.base_branch if config else 'master'
+
+            snapshots = db.query(CoverageSnapshot).filter(
+                CoverageSnapshot.repo_id == repo_id,
+                CoverageSnapshot.branch == branch,
+                CoverageSnapshot.created_at >= window_start
+            ).order_by(CoverageSnapshot.created_at).all()
+
+            entry = {
+                'repo_id': repo_id,
+                'branch': branch,
+                'coverage_rate': None,
+                'start_rate': None,
+                'delta': None,
+                'snapshot_count': len(snapshots)
+            }
+
+            if query.path_prefix:
+                rate = _path_filtered_rate(db, repo_id, branch, query.path_prefix)
+                if rate is not None:
+                    entry['coverage_rate'] = precision.apply_policy(rate)
+            elif snapshots:
+                start = snapshots[0].coverage_rate / 100.0
+                end = snapshots[-1].coverage_rate / 100.0
+                entry['coverage_rate'] = precision.apply_policy(end)
+                entry['start_rate'] = precision.apply_policy(start)
+                entry['delta'] = precision.apply_policy(end - start)
+
+            repos.append(entry)
+
+        return {
+            'query': query.to_dict(),
+            'generated_at': now,
+            'repos': repos
+        }
+    finally:
+        db.close()
@@ -0,0 +1,158 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+只读分享链接模块
+生成限时、免认证的分享token，范围限定为单个commit的覆盖率视图，
+用于把结果给外部审计或开源贡献者看而不用开账号
+
+token明文只在创建时返回一次，库里只存SHA256散列（与API Key一致）
+视图数据来自快照表：该commit的总体快照 + 文件级快照，
+即使分支后续又上报了新数据，分享出去的内容也不会变
+
+环境变量:
+    SHARE_LINK_TTL_HOURS: 默认有效期（小时，默认 72）
+    SHARE_LINK_MAX_TTL_HOURS: 允许的最长有效期（默认 720，即30天）
+"""
+
+import hashlib
+import logging
+import os
+from typing import Dict, Optional
+
+from manager import determinism
+
+from models import (
+    CoverageSnapshot, CoverageFileSnapshot, CoverageConfig, ShareLink,
+    get_db_session
+)
+
+logger = logging.getLogger(__name__)
+
+SHARE_LINK_TTL_HOURS = int(os.getenv('SHARE_LINK_TTL_HOURS', '72'))
+SHARE_LINK_MAX_TTL_HOURS = int(os.getenv('SHARE_LINK_MAX_TTL_HOURS', '720'))
+
+
+def _hash_token(token: str) -> str:
+    """token只存SHA256散列，泄库不等于泄token"""
+    return hashlib.sha256(token.encode('utf-8')).hexdigest()
+
+
+def create_share_link(repo_id: str, branch: str, commit: str,
+                      ttl_hours: Optional[int] = None,
+                      created_by: str = '') -> Optional[Dict]:
+    """
+    创建分享链接
+
+    参数:
+        repo_id: 仓库唯一标识
+        branch: 分支名
+        commit: 提交SHA（必须已有覆盖率快照）
+        ttl_hours: 有效期（小时），缺省用默认值，超上限按上限截断
+        created_by: 创建人（审计用）
+
+    返回:
+        Optional[Dict]: 链接信息（含仅此一次返回的token明文）
+        该commit没有覆盖率快照时返回 None
+    """
+    if not ttl_hours or ttl_hours <= 0:
+        ttl_hours = SHARE_LINK_TTL_HOURS
+    ttl_hours = min(ttl_hours, SHARE_LINK_MAX_TTL_HOURS)
+
+    db = get_db_session()
+    try:
+        snapshot = db.query(CoverageSnapshot).filter(
+            CoverageSnapshot.repo_id == repo_id,
+            CoverageSnapshot.branch == branch,
+            CoverageSnapshot.commit == commit
+        ).first()
+        if not snapshot:
+            return None
+
+        now = determinism.now_ms()
+        token = 'orbit_share_' + determinism.token_hex(24)
+        link = ShareLink(
+            token_hash=_hash_token(token),
+            repo_id=repo_id,
+            branch=branch,
+            commit=commit,
+            created_by=created_by,
+            expires_at=now + ttl_hours * 3600 * 1000,
+            created_at=now
+        )
+        db.add(link)
+        db.commit()
+
+        result = link.to_dict()
+        result['token'] = token
+        return result
+    except Exception as e:
+        db.rollback()
+        logger.error(f"Failed to create share link: {e}")
+        raise
+    finally:
+        db.close()
+
+
+def resolve_share_link(token: str) -> Optional[Dict]:
+    """
+    按token解析分享视图
+
+    返回:
+        Optional[Dict]: {
+            'repo_name', 'branch', 'commit', 'expires_at',
+            'total_statements', 'covered_statements', 'coverage_rate',
+            'files': [{'file', 'total_statements', 'covered_statements',
+                       'coverage_rate'}]
+        }
+        token不存在返回 None；已过期返回 {'expired': True}
+    """
+    db = get_db_session()
+    try:
+        link = db.query(ShareLink).filter(
+            ShareLink.token_hash == _hash_token(token)
+        ).first()
+        if not link:
+            return None
+        if link.expires_at < determinism.now_ms():
+            return {'expired': True}
+
+        snapshot = db.query(CoverageSnapshot).filter(
+            CoverageSnapshot.repo_id == link.repo_id,
+            CoverageSnapshot.branch == link.branch,
+            CoverageSnapshot.commit == link.commit
+        ).order_by(CoverageSnapshot.created_at.desc()).first()
+        if not snapshot:
+            return None
+
+        config = db.query(CoverageConfig).filter(
+            CoverageConfig.repo_id == link.repo_id
+        ).first()
+
+        file_snapshots = db.query(CoverageFileSnapshot).filter(
+            CoverageFileSnapshot.repo_id == link.repo_id,
+            CoverageFileSnapshot.branch == link.branch,
+            CoverageFileSnapshot.commit == link.commit
+        ).order_by(CoverageFileSnapshot.file_path.asc()).all()
+
+        # 只暴露覆盖率数字，不暴露repo_url等内部信息
+        return {
+            'repo_name': config.repo_name if config else '',
+            'branch': link.branch,
+            'commit': link.commit,
+            'expires_at': link.expires_at,
+            'total_statements': snapshot.total_statements,
+            'covered_statements': snapshot.covered_statements,
+            'coverage_rate': snapshot.coverage_rate / 100.0,
+            'files': [
+                {
+                    'file': f.file_path,
+                    'total_statements': f.total_statements,
+                    'covered_statements': f.covered_statements,
+                    'coverage_rate': f.coverage_rate / 100.0
+                }
+                for f in file_snapshots
+            ]
+        }
+    finally:
+        db.close()
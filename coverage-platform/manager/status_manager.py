@@ -0,0 +1,113 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+平台状态模块
+汇总各接入通道的心跳与摄入延迟，供内部状态页消费，
+用户可以自查"慢是不是平台侧的问题"
+
+心跳由各通道在处理成功/失败后上报（见 record_heartbeat），
+上报失败只记日志，绝不影响正常的消息处理
+"""
+
+import logging
+import time
+from typing import Dict
+
+from models import ComponentHeartbeat, CoverageReport, get_db_session
+from manager import incident_manager
+
+logger = logging.getLogger(__name__)
+
+# 心跳超过该时长未更新视为 stale（毫秒）
+STALE_THRESHOLD_MS = 15 * 60 * 1000
+
+
+def record_heartbeat(component: str, status: str = 'ok', detail: str = '') -> None:
+    """
+    更新组件心跳（不存在则创建）
+    status 为 ok 时同时推进 last_success_at
+    任何异常都只记日志，不向调用方抛出
+    """
+    db = get_db_session()
+    try:
+        now = int(time.time() * 1000)
+        heartbeat = db.query(ComponentHeartbeat).filter(
+            ComponentHeartbeat.component == component
+        ).first()
+        if not heartbeat:
+            heartbeat = ComponentHeartbeat(
+                component=component, last_success_at=0, updated_at=now
+            )
+            db.add(heartbeat)
+
+        heartbeat.status = status
+        heartbeat.detail = detail[:500] if detail else ''
+        heartbeat.updated_at = now
+        if status == 'ok':
+            heartbeat.last_success_at = now
+        db.commit()
+    except Exception as e:
+        db.rollback()
+        logger.warning(f"Failed to record heartbeat for {component}: {e}")
+
+
+def get_system_status() -> Dict:
+    """
+    汇总平台状态
+
+    返回:
+        Dict: {
+            'generated_at': 生成时间（毫秒时间戳）,
+            'status': 'ok' | 'degraded',  # 任一组件error或stale即degraded
+            'components': [{'component', 'status', 'stale',
+                            'last_success_at', 'detail'}],
+            'ingestion': {
+                'last_report_at': 最近一份报告的更新时间,
+                'lag_ms': 距今延迟,
+                'reports_last_hour': 最近一小时处理的报告数
+            },
+            'active_incidents': 活跃的平台事故列表
+        }
+    """
+    db = get_db_session()
+    try:
+        now = int(time.time() * 1000)
+
+        components = []
+        overall_ok = True
+        for heartbeat in db.query(ComponentHeartbeat).order_by(
+            ComponentHeartbeat.component
+        ).all():
+            entry = heartbeat.to_dict()
+            entry['stale'] = now - heartbeat.updated_at > STALE_THRESHOLD_MS
+            if heartbeat.status != 'ok' or entry['stale']:
+                overall_ok = False
+            components.append(entry)
+
+        last_report = db.query(CoverageReport).order_by(
+            CoverageReport.updated_at.desc()
+        ).first()
+        last_report_at = last_report.updated_at if last_report else 0
+        hour_ago = now - 3600 * 1000
+        reports_last_hour = db.query(CoverageReport).filter(
+            CoverageReport.updated_at >= hour_ago
+        ).count()
+
+        active_incidents = incident_manager.list_incidents(status='active')
+        if active_incidents:
+            overall_ok = False
+
+        return {
+            'generated_at': now,
+            'status': 'ok' if overall_ok else 'degraded',
+            'components': components,
+            'ingestion': {
+                'last_report_at': last_report_at,
+                'lag_ms': now - last_report_at if last_report_at else None,
+                'reports_last_hour': reports_last_hour
+            },
+            'active_incidents': active_incidents
+        }
+    finally:
+        db.close()
@@ -0,0 +1,203 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+测试缺口自动建单业务逻辑管理层
+定时扫描基准分支上近期有改动且覆盖率低于阈值的文件，自动在
+跟踪系统里建issue；同一仓库+文件去重（只保留一条open记录），
+覆盖率修回阈值以上后自动关单——覆盖率缺口变成可跟踪的工作项，
+而不是报表里没人认领的数字
+
+当前实现GitLab issue（repo_id即GitLab项目ID）；tracker字段预留，
+Jira等其他跟踪系统可按同样的建单/关单接口扩展
+
+环境变量:
+    TEST_GAP_ENABLED: 是否启用自动建单（默认0关闭，会写外部系统）
+    TEST_GAP_COVERAGE_FLOOR: 覆盖率阈值百分比（默认50）
+    TEST_GAP_RECENT_DAYS: "近期有改动"的窗口天数（默认14）
+    TEST_GAP_MAX_PER_RUN: 单轮扫描最多新建的issue数（默认10，防风暴）
+"""
+
+import logging
+import os
+import time
+from typing import Dict
+
+from models import (
+    CoverageConfig, CoverageFileSnapshot, TestGapIssue, get_db_session
+)
+from manager import gitlab_manager
+from manager.status_manager import record_heartbeat
+
+logger = logging.getLogger(__name__)
+
+TEST_GAP_ENABLED = os.environ.get('TEST_GAP_ENABLED', '0') == '1'
+COVERAGE_FLOOR = int(os.environ.get('TEST_GAP_COVERAGE_FLOOR', '50'))
+RECENT_DAYS = int(os.environ.get('TEST_GAP_RECENT_DAYS', '14'))
+MAX_PER_RUN = int(os.environ.get('TEST_GAP_MAX_PER_RUN', '10'))
+
+TRACKER_GITLAB = 'gitlab'
+
+STATUS_OPEN = 'open'
+STATUS_CLOSED = 'closed'
+
+
+def _issue_title(file_path: str) -> str:
+    return f'[覆盖率] {file_path} 低于测试覆盖率底线'
+
+
+def _issue_description(file_path: str, rate: float, floor: int) -> str:
+    return (
+        f'文件 `{file_path}` 近期有改动，当前覆盖率 **{rate:.1f}%**，'
+        f'低于团队设定的底线 {floor}%。\n\n'
+        f'请为近期改动补充测试；覆盖率回到底线以上后本issue会自动关闭。\n\n'
+        f'> 本issue由覆盖率平台自动创建'
+    )
+
+
+def _latest_file_rates(db, repo_id: str, branch: str, since: int) -> Dict[str, float]:
+    """
+    近期窗口内每个文件最新一次快照的覆盖率（百分比）
+    """
+    rows = db.query(CoverageFileSnapshot).filter(
+        CoverageFileSnapshot.repo_id == repo_id,
+        CoverageFileSnapshot.branch == branch,
+        CoverageFileSnapshot.created_at >= since
+    ).order_by(CoverageFileSnapshot.created_at.asc()).all()
+
+    rates = {}
+    for row in rows:
+        # 按created_at升序遍历，同一文件后面的覆盖前面的
+        rates[row.file_path] = row.coverage_rate / 100.0
+    return rates
+
+
+def run_test_gap_scan() -> Dict:
+    """
+    执行一轮测试缺口扫描（由定时任务调用）
+
+    每个仓库基准分支上近期有快照且覆盖率低于底线的文件：
+    无open记录则建单（单轮上限MAX_PER_RUN），有则刷新扫描结果；
+    已有open记录但覆盖率回到底线以上的自动关单
+
+    返回:
+        Dict: {'enabled', 'filed', 'closed', 'updated',
+               'issues': [本轮新建的记录]}
+    """
+    if not TEST_GAP_ENABLED:
+        return {'enabled': False, 'filed': 0, 'closed': 0, 'updated': 0,
+                'issues': []}
+
+    db = get_db_session()
+    try:
+        now = int(time.time() * 1000)
+        since = now - RECENT_DAYS * 24 * 3600 * 1000
+        filed = 0
+        closed = 0
+        updated = 0
+        new_issues = []
+
+        for config in db.query(CoverageConfig).all():
+            branch = config.base_branch or 'master'
+            rates = _latest_file_rates(db, config.repo_id, branch, since)
+
+            open_issues = {
+                issue.file_path: issue
+                for issue in db.query(TestGapIssue).filter(
+                    TestGapIssue.repo_id == config.repo_id,
+                    TestGapIssue.status == STATUS_OPEN
+                ).all()
+            }
+
+            # 修复的自动关单（文件不再出现在近期窗口内的不动，
+            # 等它下次有改动再评估）
+            for file_path, issue in open_issues.items():
+                rate = rates.get(file_path)
+                if rate is None or rate < COVERAGE_FLOOR:
+                    continue
+                try:
+                    gitlab_manager.close_issue(
+                        config.repo_id, int(issue.issue_key)
+                    )
+                except Exception as e:
+                    logger.warning(
+                        f"Failed to close test gap issue: "
+                        f"repo_id={config.repo_id}, iid={issue.issue_key}, {e}"
+                    )
+                    continue
+                issue.status = STATUS_CLOSED
+                issue.coverage_rate = int(rate * 100)
+                issue.updated_at = now
+                closed += 1
+
+            # 低于底线的建单/刷新
+            for file_path, rate in rates.items():
+                if rate >= COVERAGE_FLOOR:
+                    continue
+                existing = open_issues.get(file_path)
+                if existing and existing.status == STATUS_OPEN:
+                    existing.coverage_rate = int(rate * 100)
+                    existing.updated_at = now
+                    updated += 1
+                    continue
+                if filed >= MAX_PER_RUN:
+                    continue
+                try:
+                    result = gitlab_manager.create_issue(
+                        config.repo_id,
+                        _issue_title(file_path),
+                        _issue_description(file_path, rate, COVERAGE_FLOOR),
+                        labels='coverage,test-gap'
+                    )
+                except Exception as e:
+                    logger.warning(
+                        f"Failed to file test gap issue: "
+                        f"repo_id={config.repo_id}, file={file_path}, {e}"
+                    )
+                    continue
+                issue = TestGapIssue(
+                    repo_id=config.repo_id,
+                    file_path=file_path,
+                    tracker=TRACKER_GITLAB,
+                    issue_key=str(result['iid']),
+                    status=STATUS_OPEN,
+                    coverage_rate=int(rate * 100),
+                    created_at=now,
+                    updated_at=now
+                )
+                db.add(issue)
+                filed += 1
+                new_issues.append(issue.to_dict())
+
+        db.commit()
+
+        logger.info(
+            f"Test gap scan finished: filed={filed}, closed={closed}, "
+            f"updated={updated}"
+        )
+        record_heartbeat(
+            'test_gap', status='ok',
+            detail=f'filed={filed}, closed={closed}'
+        )
+        return {'enabled': True, 'filed': filed, 'closed': closed,
+                'updated': updated, 'issues': new_issues}
+    except Exception:
+        db.rollback()
+        raise
+    finally:
+        db.close()
+
+
+def list_test_gap_issues(repo_id=None, status=None) -> Dict:
+    """列出建单记录（可按仓库/状态过滤）"""
+    db = get_db_session()
+    try:
+        query = db.query(TestGapIssue)
+        if repo_id:
+            query = query.filter(TestGapIssue.repo_id == repo_id)
+        if status:
+            query = query.filter(TestGapIssue.status == status)
+        rows = query.order_by(TestGapIssue.updated_at.desc()).all()
+        return {'data': [r.to_dict() for r in rows], 'total': len(rows)}
+    finally:
+        db.close()
@@ -0,0 +1,171 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+测试结果管理模块
+接收 JUnit XML 格式的测试结果，按 commit 与覆盖率数据关联
+覆盖率回落时可以据此区分"代码没测"和"测试失败被跳过"
+"""
+
+import logging
+import time
+import xml.etree.ElementTree as ET
+from typing import Dict, List, Optional
+
+from models import TestRunResult, CoverageSnapshot, get_db_session
+
+logger = logging.getLogger(__name__)
+
+
+def parse_junit_xml(raw: str) -> Dict:
+    """
+    解析 JUnit XML，汇总测试计数
+    兼容单个 <testsuite> 根节点和 <testsuites> 包裹的多套件结构
+    """
+    root = ET.fromstring(raw)
+
+    if root.tag == 'testsuite':
+        suites = [root]
+    elif root.tag == 'testsuites':
+        suites = root.findall('testsuite')
+    else:
+        raise ValueError(f'unexpected JUnit root element: {root.tag}')
+
+    total = 0
+    failed = 0
+    errors = 0
+    skipped = 0
+    duration = 0.0
+
+    for suite in suites:
+        total += int(suite.get('tests', 0))
+        failed += int(suite.get('failures', 0))
+        errors += int(suite.get('errors', 0))
+        skipped += int(suite.get('skipped', 0))
+        try:
+            duration += float(suite.get('time', 0))
+        except ValueError:
+            pass
+
+    passed = total - failed - errors - skipped
+
+    return {
+        'total_tests': total,
+        'passed': max(passed, 0),
+        'failed': failed,
+        'errors': errors,
+        'skipped': skipped,
+        'duration_ms': int(duration * 1000)
+    }
+
+
+def process_test_result_report(data: Dict) -> None:
+    """
+    处理测试结果消息
+    消息结构:
+        {
+            "type": "test_results",
+            "repo_id": ..., "branch": ..., "commit": ...,
+            "results": {"format": "junit", "raw": "<testsuites>...</testsuites>"}
+        }
+    测试结果按次追加（保留历史，用于与覆盖率快照按时间关联）
+    """
+    repo_id = data.get('repo_id', '')
+    branch = data.get('branch', '')
+    commit = data.get('commit', '')
+    results = data.get('results', {})
+    results_format = results.get('format', 'junit')
+    raw = results.get('raw', '')
+
+    if not repo_id or not branch:
+        raise ValueError('Missing repo_id or branch in test results message')
+    if results_format != 'junit':
+        raise ValueError(f'unsupported test results format: {results_format}')
+
+    summary = parse_junit_xml(raw)
+
+    db = get_db_session()
+    try:
+        now = int(time.time() * 1000)
+        run = TestRunResult(
+            repo_id=repo_id,
+            branch=branch,
+            commit=commit,
+            total_tests=summary['total_tests'],
+            passed=summary['passed'],
+            failed=summary['failed'],
+            errors=summary['errors'],
+            skipped=summary['skipped'],
+            duration_ms=summary['duration_ms'],
+            created_at=now
+        )
+        db.add(run)
+        db.commit()
+        logger.info(
+            f"Processed test results: repo_id={repo_id}, branch={branch}, "
+            f"commit={commit}, total={summary['total_tests']}, "
+            f"failed={summary['failed']}, skipped={summary['skipped']}"
+        )
+    except Exception:
+        db.rollback()
+        raise
+    finally:
+        db.close()
+
+
+def get_test_results(repo_id: str, branch: str, limit: int = 20) -> List[Dict]:
+    """获取指定仓库分支的测试结果历史（按时间倒序）"""
+    db = get_db_session()
+    try:
+        runs = db.query(TestRunResult).filter(
+            TestRunResult.repo_id == repo_id,
+            TestRunResult.branch == branch
+        ).order_by(TestRunResult.created_at.desc()).limit(limit).all()
+        return [r.to_dict() for r in runs]
+    finally:
+        db.close()
+
+
+def correlate_with_coverage(repo_id: str, branch: str, commit: str) -> Optional[Dict]:
+    """
+    将指定 commit 的测试结果与覆盖率快照关联
+    覆盖率相对上一个快照下降且存在失败/跳过的测试时，给出提示信息
+    """
+    db = get_db_session()
+    try:
+        run = db.query(TestRunResult).filter(
+            TestRunResult.repo_id == repo_id,
+            TestRunResult.branch == branch,
+            TestRunResult.commit == commit
+        ).order_by(TestRunResult.created_at.desc()).first()
+
+        if not run:
+            return None
+
+        result = {
+            'test_run': run.to_dict(),
+            'coverage_delta': None,
+            'hint': None
+        }
+
+        # 最近两个快照算覆盖率变化
+        snapshots = db.query(CoverageSnapshot).filter(
+            CoverageSnapshot.repo_id == repo_id,
+            CoverageSnapshot.branch == branch
+        ).order_by(CoverageSnapshot.created_at.desc()).limit(2).all()
+
+        if len(snapshots) == 2:
+            delta = (snapshots[0].coverage_rate - snapshots[1].coverage_rate) / 100.0
+            result['coverage_delta'] = delta
+
+            not_run = run.failed + run.errors + run.skipped
+            if delta < 0 and not_run > 0:
+                result['hint'] = (
+                    f'coverage dropped {abs(delta):.2f}pt while {not_run} tests '
+                    f'failed or were skipped; the drop may be caused by tests '
+                    f'not running rather than untested code'
+                )
+
+        return result
+    finally:
+        db.close()
@@ -0,0 +1,173 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+趋势管理模块
+基于覆盖率快照提供趋势查询
+支持两种排序:
+    - time: 按快照到达时间（默认）
+    - ancestry: 按分支 first-parent 提交顺序，rebase 和晚到的报告不会产生锯齿
+"""
+
+import logging
+import subprocess
+from typing import Dict, List, Optional
+
+from models import CoverageSnapshot, CoverageConfig, ReportNote, get_db_session
+from manager.repo_manager import get_bare_repo_path
+
+logger = logging.getLogger(__name__)
+
+ORDER_TIME = 'time'
+ORDER_ANCESTRY = 'ancestry'
+
+SUPPORTED_ORDERS = [ORDER_TIME, ORDER_ANCESTRY]
+
+# time 模式的时间轴：reported=报告声明时间（经信任策略修正），received=平台收到时间
+CLOCK_REPORTED = 'reported'
+CLOCK_RECEIVED = 'received'
+
+SUPPORTED_CLOCKS = [CLOCK_REPORTED, CLOCK_RECEIVED]
+
+# ancestry 模式最多回溯的提交数
+ANCESTRY_MAX_COMMITS = 2000
+
+
+def get_first_parent_commits(repo_url: str, branch: str) -> Optional[List[str]]:
+    """
+    获取分支的 first-parent 提交序列（从旧到新）
+    失败返回 None（仓库未克隆等情况）
+    """
+    try:
+        bare_repo_path = get_bare_repo_path(repo_url)
+        result = subprocess.run(
+            ['git', 'rev-list', '--first-parent', '--reverse',
+             f'--max-count={ANCESTRY_MAX_COMMITS}', branch],
+            cwd=bare_repo_path,
+            capture_output=True,
+            text=True,
+            timeout=60
+        )
+        if result.returncode != 0:
+            logger.warning(f"Failed to get first-parent history: {result.stderr}")
+            return None
+        return [line.strip() for line in result.stdout.splitlines() if line.strip()]
+    except Exception as e:
+        logger.warning(f"Error getting first-parent history: {e}")
+        return None
+
+
+def _attach_notes(db, repo_id: str, branch: str, points: List[Dict]) -> List[Dict]:
+    """为趋势点附加该commit的人工备注（趋势图tooltip展示用）"""
+    commits = {p['commit'] for p in points if p.get('commit')}
+    if not commits:
+        return points
+
+    notes = db.query(ReportNote).filter(
+        ReportNote.repo_id == repo_id,
+        ReportNote.branch == branch,
+        ReportNote.commit.in_(commits)
+    ).order_by(ReportNote.created_at.asc()).all()
+
+    by_commit = {}
+    for note in notes:
+        by_commit.setdefault(note.commit, []).append(note.to_dict())
+
+    for p in points:
+        p['notes'] = by_commit.get(p.get('commit'), [])
+    return points
+
+
+def get_coverage_trend(repo_id: str, branch: str, order: str = ORDER_TIME,
+                       limit: int = 100,
+                       clock: str = CLOCK_REPORTED,
+                       metadata_filters: Optional[Dict] = None) -> Optional[List[Dict]]:
+    """
+    获取覆盖率趋势
+
+    参数:
+        repo_id: 仓库唯一标识
+        branch: 分支名
+        order: 排序方式（time=按时间, ancestry=按first-parent提交顺序）
+        limit: 返回点数上限
+        clock: time模式的时间轴（reported=报告声明时间, received=平台收到时间）
+               CI时钟漂移导致图表异常时可切到received看真实到达顺序
+        metadata_filters: 自定义metadata过滤（{key: value}，全部命中才保留）
+
+    返回:
+        Optional[List[Dict]]: 趋势点列表（从旧到新）
+    """
+    from manager.manager import metadata_like_pattern
+
+    db = get_db_session()
+    try:
+        query = db.query(CoverageSnapshot).filter(
+            CoverageSnapshot.repo_id == repo_id,
+            CoverageSnapshot.branch == branch
+        )
+        for key, value in (metadata_filters or {}).items():
+            query = query.filter(
+                CoverageSnapshot.report_metadata.like(
+                    metadata_like_pattern(key, value)
+                )
+            )
+        snapshots = query.order_by(
+            CoverageSnapshot.created_at.desc()
+        ).limit(limit * 5).all()
+
+        if not snapshots:
+            return []
+
+        if order == ORDER_TIME:
+            # received轴：老数据received_at为0时退回created_at
+            def point_time(s):
+                if clock == CLOCK_RECEIVED:
+                    return s.received_at or s.created_at
+                return s.created_at
+
+            # 每个点按所选时间轴排序；同 commit 多次上报只保留最新一次
+            ordered = sorted(snapshots, key=point_time, reverse=True)
+            seen_commits = set()
+            points = []
+            for s in ordered:
+                if s.commit in seen_commits:
+                    continue
+                seen_commits.add(s.commit)
+                points.append(s)
+            points.reverse()
+            return _attach_notes(db, repo_id, branch,
+                                 [p.to_dict() for p in points[-limit:]])
+
+        if order == ORDER_ANCESTRY:
+            config = db.query(CoverageConfig).filter(
+                CoverageConfig.repo_id == repo_id
+            ).first()
+            if not config or not config.repo_url:
+                logger.warning(f"No repo_url for ancestry ordering: repo_id={repo_id}")
+                return None
+
+            commit_order = get_first_parent_commits(config.repo_url, branch)
+            if commit_order is None:
+                return None
+
+            commit_index = {c: i for i, c in enumerate(commit_order)}
+
+            # 只保留出现在 first-parent 链上的快照（rebase 掉的提交被剔除），
+            # 同 commit 取最新快照
+            by_commit = {}
+            for s in snapshots:
+                if s.commit not in commit_index:
+                    continue
+                existing = by_commit.get(s.commit)
+                if not existing or s.created_at > existing.created_at:
+                    by_commit[s.commit] = s
+
+            ordered = sorted(
+                by_commit.values(), key=lambda s: commit_index[s.commit]
+            )
+            return _attach_notes(db, repo_id, branch,
+                                 [s.to_dict() for s in ordered[-limit:]])
+
+        raise ValueError(f'unsupported trend order: {order}')
+    finally:
+        db.close()
@@ -0,0 +1,201 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+断点续传上传模块
+为超大覆盖率文件提供 Content-Range 分块上传能力，
+CI 网络中断后可查询已接收偏移量并从断点继续，不必整包重传
+
+上传会话以文件形式落在本地磁盘:
+    uploads/<upload_id>.data  已接收的数据
+    uploads/<upload_id>.json  会话元信息（manifest、总大小、创建时间）
+"""
+
+import json
+import logging
+import os
+from typing import Dict, Optional
+
+from manager import determinism
+
+logger = logging.getLogger(__name__)
+
+# uploads目录路径（在coverage-platform目录下，与repos目录同级）
+UPLOADS_BASE_DIR = os.path.join(
+    os.path.dirname(os.path.dirname(os.path.abspath(__file__))), 'uploads'
+)
+
+# 超过该时长未完成的会话视为过期，可被清理（毫秒）
+UPLOAD_SESSION_TTL_MS = int(os.getenv('UPLOAD_SESSION_TTL_MS', str(24 * 3600 * 1000)))
+
+
+def _data_path(upload_id: str) -> str:
+    return os.path.join(UPLOADS_BASE_DIR, f'{upload_id}.data')
+
+
+def _meta_path(upload_id: str) -> str:
+    return os.path.join(UPLOADS_BASE_DIR, f'{upload_id}.json')
+
+
+def _load_meta(upload_id: str) -> Optional[Dict]:
+    try:
+        with open(_meta_path(upload_id), 'r', encoding='utf-8') as f:
+            return json.load(f)
+    except (OSError, ValueError):
+        return None
+
+
+def create_upload(manifest: Dict, total_size: int) -> str:
+    """
+    创建上传会话
+
+    参数:
+        manifest: 报告元信息（repo, repo_id, branch, commit, ci, format 等）
+        total_size: 完整载荷的字节数
+
+    返回:
+        str: upload_id
+    """
+    os.makedirs(UPLOADS_BASE_DIR, exist_ok=True)
+    upload_id = determinism.new_id()
+
+    meta = {
+        'upload_id': upload_id,
+        'manifest': manifest,
+        'total_size': total_size,
+        'created_at': determinism.now_ms()
+    }
+    with open(_meta_path(upload_id), 'w', encoding='utf-8') as f:
+        json.dump(meta, f)
+    # 预创建空数据文件
+    open(_data_path(upload_id), 'wb').close()
+
+    logger.info(f"Created upload session: {upload_id}, total_size={total_size}")
+    return upload_id
+
+
+def get_upload_status(upload_id: str) -> Optional[Dict]:
+    """
+    查询上传会话状态（客户端断线后据此决定续传位置）
+
+    返回:
+        Optional[Dict]: {'upload_id', 'received', 'total_size'}，会话不存在返回 None
+    """
+    meta = _load_meta(upload_id)
+    if not meta:
+        return None
+    try:
+        received = os.path.getsize(_data_path(upload_id))
+    except OSError:
+        received = 0
+    return {
+        'upload_id': upload_id,
+        'received': received,
+        'total_size': meta['total_size']
+    }
+
+
+def append_chunk(upload_id: str, start: int, chunk: bytes) -> Optional[Dict]:
+    """
+    追加一个分块
+
+    参数:
+        upload_id: 会话 ID
+        start: 该分块在完整载荷中的起始偏移（必须等于已接收字节数）
+        chunk: 分块数据
+
+    返回:
+        Optional[Dict]: 追加后的会话状态；会话不存在返回 None
+
+    偏移不匹配时抛出 ValueError（客户端应查询状态后重试）
+    """
+    meta = _load_meta(upload_id)
+    if not meta:
+        return None
+
+    data_path = _data_path(upload_id)
+    try:
+        received = os.path.getsize(data_path)
+    except OSError:
+        received = 0
+
+    if start != received:
+        raise ValueError(f'offset mismatch: expected {received}, got {start}')
+    if received + len(chunk) > meta['total_size']:
+        raise ValueError(
+            f'chunk exceeds declared total size: '
+            f'{received + len(chunk)} > {meta["total_size"]}'
+        )
+
+    with open(data_path, 'ab') as f:
+        f.write(chunk)
+
+    return get_upload_status(upload_id)
+
+
+def complete_upload(upload_id: str) -> Optional[Dict]:
+    """
+    完成上传会话，返回组装好的覆盖率报告消息（由调用方发布）
+    数据不完整时抛出 ValueError；会话不存在返回 None
+    成功后会话文件被清理
+    """
+    meta = _load_meta(upload_id)
+    if not meta:
+        return None
+
+    data_path = _data_path(upload_id)
+    try:
+        received = os.path.getsize(data_path)
+    except OSError:
+        received = 0
+    if received != meta['total_size']:
+        raise ValueError(
+            f'upload incomplete: received {received} of {meta["total_size"]} bytes'
+        )
+
+    with open(data_path, 'r', encoding='utf-8') as f:
+        raw = f.read()
+
+    manifest = meta['manifest']
+    message = {
+        'repo': manifest.get('repo', ''),
+        'repo_id': manifest.get('repo_id', ''),
+        'branch': manifest.get('branch', ''),
+        'commit': manifest.get('commit', ''),
+        'ci': manifest.get('ci', {}),
+        'coverage': {
+            'format': manifest.get('format', 'goc'),
+            'raw': raw
+        },
+        'timestamp': manifest.get('timestamp', 0)
+    }
+
+    discard_upload(upload_id)
+    logger.info(f"Completed upload session: {upload_id}, size={received}")
+    return message
+
+
+def discard_upload(upload_id: str) -> None:
+    """删除上传会话的本地文件"""
+    for path in (_data_path(upload_id), _meta_path(upload_id)):
+        try:
+            os.remove(path)
+        except OSError:
+            pass
+
+
+def cleanup_expired_uploads() -> int:
+    """清理过期未完成的会话，返回清理数量"""
+    if not os.path.isdir(UPLOADS_BASE_DIR):
+        return 0
+    now = determinism.now_ms()
+    cleaned = 0
+    for name in os.listdir(UPLOADS_BASE_DIR):
+        if not name.endswith('.json'):
+            continue
+        upload_id = name[:-5]
+        meta = _load_meta(upload_id)
+        if meta and now - meta.get('created_at', 0) > UPLOAD_SESSION_TTL_MS:
+            discard_upload(upload_id)
+            cleaned += 1
+    return cleaned
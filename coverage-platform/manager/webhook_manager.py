@@ -0,0 +1,111 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+出站Webhook业务逻辑管理层
+门禁评估结果主动推送给外部编排系统（Argo Workflows、发布机器人等），
+携带完整决策载荷（规则、指标、结论）并用HMAC-SHA256签名，
+消费方无需轮询、可验证消息未被篡改
+
+签名方式:
+    X-Orbit-Signature: sha256=<HMAC-SHA256(secret, 原始请求体)>
+    消费方用同一secret对收到的原始字节重算HMAC比对即可
+
+环境变量:
+    GATE_WEBHOOK_URLS: 接收地址，分号分隔多个（不设置则不推送）
+    GATE_WEBHOOK_SECRET: HMAC签名密钥（必须与接收方约定一致）
+    GATE_WEBHOOK_TIMEOUT: 单次推送超时秒数（默认10）
+"""
+
+import hashlib
+import hmac
+import json
+import logging
+import os
+from typing import Dict, List
+
+import requests
+
+from manager import determinism
+
+logger = logging.getLogger(__name__)
+
+GATE_WEBHOOK_URLS = [
+    url.strip() for url in os.environ.get('GATE_WEBHOOK_URLS', '').split(';')
+    if url.strip()
+]
+GATE_WEBHOOK_SECRET = os.environ.get('GATE_WEBHOOK_SECRET', '')
+GATE_WEBHOOK_TIMEOUT = int(os.environ.get('GATE_WEBHOOK_TIMEOUT', '10'))
+
+EVENT_GATE_EVALUATED = 'gate.evaluated'
+
+
+def _sign(body: bytes) -> str:
+    """对请求体做HMAC-SHA256签名"""
+    digest = hmac.new(
+        GATE_WEBHOOK_SECRET.encode('utf-8'), body, hashlib.sha256
+    ).hexdigest()
+    return f'sha256={digest}'
+
+
+def notify_gate_evaluated(report_id: int, gate_result: Dict) -> List[Dict]:
+    """
+    推送"门禁已评估"事件到所有配置的接收地址
+
+    载荷:
+        {'event': 'gate.evaluated', 'report_id', 'gate': 完整门禁结果,
+         'sent_at': 毫秒时间戳}
+
+    返回:
+        List[Dict]: 每个地址的推送结果 [{'url', 'ok', 'status'/'error'}]
+        未配置地址时返回空列表；单个地址失败不影响其他地址
+    """
+    if not GATE_WEBHOOK_URLS:
+        return []
+    if not GATE_WEBHOOK_SECRET:
+        logger.warning(
+            "GATE_WEBHOOK_URLS configured but GATE_WEBHOOK_SECRET missing, "
+            "skipping webhook dispatch"
+        )
+        return []
+
+    payload = {
+        'event': EVENT_GATE_EVALUATED,
+        'report_id': report_id,
+        'gate': gate_result,
+        'sent_at': determinism.now_ms()
+    }
+    body = json.dumps(payload, ensure_ascii=False).encode('utf-8')
+    headers = {
+        'Content-Type': 'application/json; charset=utf-8',
+        'X-Orbit-Event': EVENT_GATE_EVALUATED,
+        'X-Orbit-Signature': _sign(body)
+    }
+
+    results = []
+    for url in GATE_WEBHOOK_URLS:
+        try:
+            response = requests.post(
+                url, data=body, headers=headers, timeout=GATE_WEBHOOK_TIMEOUT
+            )
+            ok = 200 <= response.status_code < 300
+            if not ok:
+                logger.warning(
+                    f"Gate webhook to {url} returned {response.status_code}"
+                )
+            results.append({'url': url, 'ok': ok, 'status': response.status_code})
+        except Exception as e:
+            logger.warning(f"Gate webhook to {url} failed: {e}")
+            results.append({'url': url, 'ok': False, 'error': str(e)})
+
+    # 领域事件（回放日志，失败不影响主流程）
+    sent = [r['url'] for r in results if r.get('ok')]
+    if sent:
+        from manager import eventlog_manager
+        eventlog_manager.append_event(
+            eventlog_manager.EVENT_NOTIFICATION_SENT,
+            payload={'channel': 'gate_webhook',
+                     'report_id': report_id, 'urls': sent}
+        )
+
+    return results
@@ -0,0 +1,129 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+代码关键度加权模块
+按配置的路径前缀权重（如支付路径算3倍）计算"加权覆盖率"，
+与原始覆盖率并列输出，供按风险做门禁的团队使用
+
+权重配置存放在 CoverageConfig.criticality_weights，
+格式 "路径前缀=权重;..."，未匹配的路径权重为1，
+多个前缀同时匹配时取最长的前缀
+"""
+
+import logging
+from typing import Dict, List, Optional, Tuple
+
+from models import CoverageFile, CoverageRange, CoverageConfig, get_db_session
+from manager.manager import should_exclude_file
+from manager import precision
+
+logger = logging.getLogger(__name__)
+
+# 未匹配任何前缀时的默认权重
+DEFAULT_WEIGHT = 1.0
+
+
+def parse_weights(raw: str) -> List[Tuple[str, float]]:
+    """
+    解析权重配置，非法项跳过并记录日志
+
+    返回:
+        List[Tuple[str, float]]: [(路径前缀, 权重)]，按前缀长度降序
+    """
+    weights = []
+    for pair in (raw or '').split(';'):
+        pair = pair.strip()
+        if not pair or '=' not in pair:
+            continue
+        prefix, value = pair.split('=', 1)
+        prefix = prefix.strip()
+        try:
+            weight = float(value.strip())
+        except ValueError:
+            logger.warning(f"Invalid criticality weight, skipping: {pair}")
+            continue
+        if not prefix or weight < 0:
+            logger.warning(f"Invalid criticality weight, skipping: {pair}")
+            continue
+        weights.append((prefix, weight))
+
+    # 最长前缀优先匹配
+    weights.sort(key=lambda w: len(w[0]), reverse=True)
+    return weights
+
+
+def weight_for_path(file_path: str, weights: List[Tuple[str, float]]) -> float:
+    """取文件路径匹配的权重（最长前缀），无匹配返回默认权重"""
+    for prefix, weight in weights:
+        if file_path.startswith(prefix):
+            return weight
+    return DEFAULT_WEIGHT
+
+
+def get_weighted_coverage(repo_id: str, branch: str) -> Optional[Dict]:
+    """
+    计算仓库分支的加权覆盖率
+
+    返回:
+        Optional[Dict]: {
+            'repo_id', 'branch',
+            'coverage_rate',           # 原始覆盖率
+            'weighted_coverage_rate',  # 加权覆盖率
+            'weights': [{'prefix', 'weight'}],
+            'files': 参与统计的文件数
+        }
+        仓库未配置时返回 None
+    """
+    db = get_db_session()
+    try:
+        config = db.query(CoverageConfig).filter(
+            CoverageConfig.repo_id == repo_id
+        ).first()
+        if not config:
+            return None
+
+        weights = parse_weights(config.criticality_weights or '')
+
+        files = db.query(CoverageFile).filter(
+            CoverageFile.repo_id == repo_id,
+            CoverageFile.branch == branch
+        ).all()
+
+        total = 0
+        covered = 0
+        weighted_total = 0.0
+        weighted_covered = 0.0
+        file_count = 0
+        for file in files:
+            if should_exclude_file(file.file_path, config.exclude_dirs,
+                                   config.exclude_files):
+                continue
+            file_count += 1
+            weight = weight_for_path(file.file_path, weights)
+
+            ranges = db.query(CoverageRange).filter(
+                CoverageRange.file_id == file.id
+            ).all()
+            for r in ranges:
+                total += r.statements
+                weighted_total += r.statements * weight
+                if r.hit > 0:
+                    covered += r.statements
+                    weighted_covered += r.statements * weight
+
+        rate = (covered / total * 100) if total > 0 else 0.0
+        weighted_rate = (
+            weighted_covered / weighted_total * 100
+        ) if weighted_total > 0 else 0.0
+
+        return {
+            'repo_id': repo_id,
+            'branch': branch,
+            'coverage_rate': precision.apply_policy(rate),
+            'weighted_coverage_rate': precision.apply_policy(weighted_rate),
+            'weights': [{'prefix': p, 'weight': w} for p, w in weights],
+            'files': file_count
+        }
+    finally:
+        db.close()
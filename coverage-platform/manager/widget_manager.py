@@ -0,0 +1,146 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+覆盖率小组件模块
+提供可嵌入内部门户的覆盖率摘要（JSON 数据和自包含的 HTML 片段）
+数据来源：最新报告统计 + 覆盖率快照（趋势/迷你曲线）
+"""
+
+import html
+import logging
+from typing import Dict, List, Optional
+
+from models import CoverageReport, CoverageSnapshot, get_db_session
+from manager.manager import get_report_statistics
+from manager.badge_manager import color_for_rate
+from manager import precision
+
+logger = logging.getLogger(__name__)
+
+# 迷你趋势曲线默认取最近的快照数量
+SPARKLINE_POINTS = 30
+
+
+def get_widget_data(repo_id: str, branch: Optional[str] = None) -> Optional[Dict]:
+    """
+    获取小组件数据
+
+    参数:
+        repo_id: 仓库唯一标识
+        branch: 分支名（可选，默认取最近更新的报告）
+
+    返回:
+        Optional[Dict]: 小组件数据，仓库无数据时返回 None
+    """
+    db = get_db_session()
+    try:
+        query = db.query(CoverageReport).filter(CoverageReport.repo_id == repo_id)
+        if branch:
+            query = query.filter(CoverageReport.branch == branch)
+        report = query.order_by(CoverageReport.updated_at.desc()).first()
+
+        if not report:
+            return None
+
+        stats = get_report_statistics(report)
+
+        # 从快照表取最近的趋势点（按时间正序返回）
+        snapshots = db.query(CoverageSnapshot).filter(
+            CoverageSnapshot.repo_id == report.repo_id,
+            CoverageSnapshot.branch == report.branch
+        ).order_by(CoverageSnapshot.created_at.desc()).limit(SPARKLINE_POINTS).all()
+        snapshots.reverse()
+
+        sparkline = [s.coverage_rate / 100.0 for s in snapshots]
+
+        # 相对上一个快照的变化（百分点）
+        delta = None
+        if len(snapshots) >= 2:
+            delta = (snapshots[-1].coverage_rate - snapshots[-2].coverage_rate) / 100.0
+
+        return {
+            'repo_id': report.repo_id,
+            'repo_name': report.repo_name,
+            'branch': report.branch,
+            'commit': report.commit,
+            'total_statements': stats['total_statements'],
+            'covered_statements': stats['covered_statements'],
+            'coverage_rate': stats['coverage_rate'],
+            'delta': delta,
+            'sparkline': sparkline,
+            'updated_at': report.updated_at
+        }
+    finally:
+        db.close()
+
+
+def _render_sparkline_svg(points: List[float], width: int = 120, height: int = 28) -> str:
+    """
+    将覆盖率序列渲染为内联 SVG 折线
+    少于两个点时返回空字符串
+    """
+    if len(points) < 2:
+        return ''
+
+    min_v = min(points)
+    max_v = max(points)
+    span = max_v - min_v if max_v > min_v else 1.0
+
+    coords = []
+    step = width / (len(points) - 1)
+    for i, v in enumerate(points):
+        x = i * step
+        # 上下各留2px边距
+        y = height - 2 - (v - min_v) / span * (height - 4)
+        coords.append(f'{x:.1f},{y:.1f}')
+
+    return (
+        f'<svg width="{width}" height="{height}" xmlns="http://www.w3.org/2000/svg">'
+        f'<polyline points="{" ".join(coords)}" fill="none" '
+        f'stroke="#4c9aff" stroke-width="1.5"/>'
+        f'</svg>'
+    )
+
+
+def render_widget_html(data: Dict) -> str:
+    """
+    将小组件数据渲染为自包含的 HTML 片段（可直接用于 iframe）
+    不依赖外部样式和脚本
+    """
+    rate = data['coverage_rate']
+    color = color_for_rate(rate)
+
+    delta_html = ''
+    if data.get('delta') is not None:
+        delta = data['delta']
+        delta_color = '#36b37e' if delta >= 0 else '#de350b'
+        delta_html = (
+            f'<span style="color:{delta_color};font-size:12px;margin-left:6px;">'
+            f'{delta:+.2f}pt</span>'
+        )
+
+    sparkline_html = _render_sparkline_svg(data.get('sparkline') or [])
+
+    # 仓库名/分支/commit来自CI上报的数据，嵌入HTML前必须转义
+    # （片段就是给门户iframe用的，不转义等于存储型XSS）
+    repo_name = html.escape(str(data['repo_name']))
+    branch = html.escape(str(data['branch']))
+    commit = html.escape(str(data['commit'])[:10])
+    rate_text = html.escape(precision.format_rate(rate))
+
+    return (
+        '<div style="font-family:-apple-system,Segoe UI,sans-serif;'
+        'border:1px solid #dfe1e6;border-radius:4px;padding:12px 16px;'
+        'display:inline-block;background:#fff;">'
+        f'<div style="font-size:13px;color:#6b778c;margin-bottom:4px;">'
+        f'{repo_name} · {branch}</div>'
+        f'<div style="display:flex;align-items:center;gap:10px;">'
+        f'<span style="font-size:22px;font-weight:600;color:{color};">'
+        f'{rate_text}%</span>{delta_html}{sparkline_html}'
+        f'</div>'
+        f'<div style="font-size:11px;color:#97a0af;margin-top:4px;">'
+        f'{data["covered_statements"]}/{data["total_statements"]} statements · '
+        f'{commit}</div>'
+        '</div>'
+    )
@@ -6,8 +6,11 @@
 提供所有数据库模型和数据库连接管理
 """
 
+import json
 import logging
+import threading
 import time
+from contextlib import contextmanager
 from sqlalchemy import create_engine, Column, Integer, String, Text, BigInteger, Index, text, func
 from sqlalchemy.orm import declarative_base, sessionmaker, scoped_session
 from sqlalchemy.pool import QueuePool
@@ -34,21 +37,52 @@ DB_PORT = os.getenv('DB_PORT', '3306')
 DB_USER = os.getenv('DB_USER', 'coverage')
 DB_PASSWORD = os.getenv('DB_PASSWORD', 'coverage123')
 DB_NAME = os.getenv('DB_NAME', 'coverage_db')
-DATABASE_DSN = f"mysql+pymysql://{DB_USER}:{DB_PASSWORD}@{DB_HOST}:{DB_PORT}/{DB_NAME}?charset=utf8mb4"
+# DATABASE_URL直接指定完整DSN时优先生效（本地单机模式用SQLite，
+# 如 sqlite:///orbit-dev.db），否则按DB_*环境变量拼装MySQL DSN
+DATABASE_DSN = os.getenv('DATABASE_URL') or \
+    f"mysql+pymysql://{DB_USER}:{DB_PASSWORD}@{DB_HOST}:{DB_PORT}/{DB_NAME}?charset=utf8mb4"
+IS_SQLITE = DATABASE_DSN.startswith('sqlite')
+
+
+def _create_engine(pool_size: int, max_overflow: int):
+    """创建数据库引擎（SQLite下连接池参数不适用，放开线程检查即可）"""
+    if IS_SQLITE:
+        return create_engine(
+            DATABASE_DSN,
+            connect_args={'check_same_thread': False},
+            echo=False
+        )
+    return create_engine(
+        DATABASE_DSN,
+        poolclass=QueuePool,
+        pool_size=pool_size,
+        max_overflow=max_overflow,
+        pool_pre_ping=True,
+        echo=False
+    )
+
 
 # 创建数据库引擎
-engine = create_engine(
-    DATABASE_DSN,
-    poolclass=QueuePool,
-    pool_size=10,
-    max_overflow=90,
-    pool_pre_ping=True,
-    echo=False
-)
+engine = _create_engine(pool_size=10, max_overflow=90)
 
 # 创建会话工厂
 SessionLocal = scoped_session(sessionmaker(bind=engine))
 
+# 优先通道连接池：门禁评估、MR评论等阻塞合并的路径走独立的小池
+# 避免看板类重查询把主池占满时延迟合并决策
+PRIORITY_DB_POOL_SIZE = int(os.getenv('PRIORITY_DB_POOL_SIZE', '5'))
+PRIORITY_DB_MAX_OVERFLOW = int(os.getenv('PRIORITY_DB_MAX_OVERFLOW', '10'))
+
+priority_engine = _create_engine(
+    pool_size=PRIORITY_DB_POOL_SIZE,
+    max_overflow=PRIORITY_DB_MAX_OVERFLOW
+)
+
+PrioritySessionLocal = scoped_session(sessionmaker(bind=priority_engine))
+
+# 当前线程是否处于优先通道（get_db_session 据此选择连接池）
+_lane = threading.local()
+
 # 创建基础模型类
 Base = declarative_base()
 
@@ -71,9 +105,11 @@ class CoverageReport(Base):
     coverage_raw = Column(Text)  # API不需要，consumer需要
     status = Column(String(50), nullable=False, default='pending', index=True)
     error_message = Column(Text)
+    # 自定义key/value元数据（JSON文本，metadata是SQLAlchemy保留名，属性名用report_metadata）
+    report_metadata = Column('metadata', Text)
     created_at = Column(BigInteger, nullable=False)
     updated_at = Column(BigInteger, nullable=False)
-    
+
     def to_dict(self, config=None):
         """
         转换为字典，用于API响应
@@ -94,6 +130,7 @@ class CoverageReport(Base):
             'ci_job_id': self.ci_job_id,
             'coverage_format': self.coverage_format,
             'status': self.status,
+            'metadata': json.loads(self.report_metadata) if self.report_metadata else {},
             'created_at': self.created_at,
             'updated_at': self.updated_at
         }
@@ -116,9 +153,11 @@ class CoverageFile(Base):
     repo_id = Column(String(64), nullable=False, index=True)
     branch = Column(String(255), nullable=False, index=True)
     file_path = Column(String(500), nullable=False, index=True)
+    complexity = Column(Integer, nullable=False, default=0)  # 圈复杂度（入库时按源码估算，0表示未计算）
+    downsampled = Column(Integer, nullable=False, default=0)  # 1表示行级数据已降采样删除，只剩文件级摘要
     created_at = Column(BigInteger, nullable=False)
     updated_at = Column(BigInteger, nullable=False)
-    
+
     def to_dict(self):
         """转换为字典，用于API响应"""
         return {
@@ -126,6 +165,8 @@ class CoverageFile(Base):
             'repo_id': self.repo_id,
             'branch': self.branch,
             'file': self.file_path,
+            'complexity': self.complexity,
+            'downsampled': self.downsampled,
             'created_at': self.created_at,
             'updated_at': self.updated_at
         }
@@ -159,6 +200,683 @@ class CoverageRange(Base):
         }
 
 
+# User 用户模型
+# 用户数据由 LDAP/SCIM 目录同步而来，本地不维护密码
+class User(Base):
+    __tablename__ = 'users'
+
+    id = Column(Integer, primary_key=True)
+    username = Column(String(255), nullable=False, unique=True, index=True)
+    display_name = Column(String(255), nullable=False, default='')
+    email = Column(String(255), nullable=False, default='', index=True)
+    role = Column(String(50), nullable=False, default='viewer')  # 角色：admin/maintainer/viewer
+    source = Column(String(50), nullable=False, default='ldap')  # 来源：ldap/scim/manual
+    active = Column(Integer, nullable=False, default=1)  # 是否激活（目录中删除的用户标记为0）
+    created_at = Column(BigInteger, nullable=False)
+    updated_at = Column(BigInteger, nullable=False)
+
+    def to_dict(self):
+        """转换为字典，用于API响应"""
+        return {
+            'id': self.id,
+            'username': self.username,
+            'display_name': self.display_name,
+            'email': self.email,
+            'role': self.role,
+            'source': self.source,
+            'active': bool(self.active),
+            'created_at': self.created_at,
+            'updated_at': self.updated_at
+        }
+
+
+# TeamMembership 团队成员关系模型
+class TeamMembership(Base):
+    __tablename__ = 'team_memberships'
+
+    id = Column(Integer, primary_key=True)
+    team_name = Column(String(255), nullable=False, index=True)
+    username = Column(String(255), nullable=False, index=True)
+    created_at = Column(BigInteger, nullable=False)
+
+    def to_dict(self):
+        """转换为字典，用于API响应"""
+        return {
+            'id': self.id,
+            'team_name': self.team_name,
+            'username': self.username,
+            'created_at': self.created_at
+        }
+
+
+# AuditLog 审计日志模型
+# 记录需要留痕的平台行为（门禁跳过、配置变更等）
+class AuditLog(Base):
+    __tablename__ = 'audit_logs'
+
+    id = Column(Integer, primary_key=True)
+    action = Column(String(100), nullable=False, index=True)  # 行为类型，如 gate_skipped
+    repo_id = Column(String(64), nullable=False, default='', index=True)
+    detail = Column(Text)  # 行为详情
+    created_at = Column(BigInteger, nullable=False, index=True)
+
+    def to_dict(self):
+        """转换为字典，用于API响应"""
+        return {
+            'id': self.id,
+            'action': self.action,
+            'repo_id': self.repo_id,
+            'detail': self.detail or '',
+            'created_at': self.created_at
+        }
+
+
+# ReportNote 报告备注模型
+# 挂在某个commit的覆盖率结果上的人工备注（如"覆盖率下降符合预期：删除了legacy模块"）
+# 在趋势图tooltip和摘要里展示，避免上下文只留在聊天记录里
+class ReportNote(Base):
+    __tablename__ = 'report_notes'
+
+    id = Column(Integer, primary_key=True)
+    repo_id = Column(String(64), nullable=False, index=True)
+    branch = Column(String(255), nullable=False, index=True)
+    commit = Column(String(100), nullable=False, index=True)
+    author = Column(String(255), nullable=False, default='')
+    note = Column(Text)  # 备注内容
+    created_at = Column(BigInteger, nullable=False, index=True)
+
+    def to_dict(self):
+        """转换为字典，用于API响应"""
+        return {
+            'id': self.id,
+            'repo_id': self.repo_id,
+            'branch': self.branch,
+            'commit': self.commit,
+            'author': self.author,
+            'note': self.note or '',
+            'created_at': self.created_at
+        }
+
+
+# CoverageGoal 覆盖率目标模型
+# 仓库级的阶段性目标（如"Q3结束前达到80%"），进度对照快照趋势自动跟踪
+class CoverageGoal(Base):
+    __tablename__ = 'coverage_goals'
+
+    id = Column(Integer, primary_key=True)
+    repo_id = Column(String(64), nullable=False, index=True)
+    branch = Column(String(255), nullable=False, default='master')
+    target_rate = Column(Integer, nullable=False)  # 目标覆盖率*100，整数存储避免浮点误差
+    deadline = Column(BigInteger, nullable=False, index=True)  # 截止时间（毫秒时间戳）
+    description = Column(String(500), nullable=False, default='')  # 目标说明
+    created_by = Column(String(255), nullable=False, default='')
+    baseline_rate = Column(Integer, nullable=False, default=0)  # 创建目标时的覆盖率*100
+    status = Column(String(50), nullable=False, default='active', index=True)  # active/achieved/missed
+    created_at = Column(BigInteger, nullable=False)
+    updated_at = Column(BigInteger, nullable=False)
+
+    def to_dict(self):
+        """转换为字典，用于API响应"""
+        return {
+            'id': self.id,
+            'repo_id': self.repo_id,
+            'branch': self.branch,
+            'target_rate': self.target_rate / 100.0,
+            'deadline': self.deadline,
+            'description': self.description,
+            'created_by': self.created_by,
+            'baseline_rate': self.baseline_rate / 100.0,
+            'status': self.status,
+            'created_at': self.created_at,
+            'updated_at': self.updated_at
+        }
+
+
+# SavedQuery 保存的查询模型
+# 参数化查询定义（仓库集合、分支、路径前缀、时间窗口）保存为命名报表
+# 管理层的周期性报表直接引用查询名，不需要每次写脚本拼参数
+class SavedQuery(Base):
+    __tablename__ = 'saved_queries'
+
+    id = Column(Integer, primary_key=True)
+    name = Column(String(255), nullable=False, unique=True, index=True)  # 查询名称
+    description = Column(String(500), nullable=False, default='')
+    repo_ids = Column(Text)  # 仓库ID列表，逗号分隔
+    branch = Column(String(255), nullable=False, default='')  # 为空时用各仓库的基准分支
+    path_prefix = Column(String(500), nullable=False, default='')  # 路径前缀过滤，为空不过滤
+    days = Column(Integer, nullable=False, default=30)  # 时间窗口（天）
+    created_by = Column(String(255), nullable=False, default='')
+    created_at = Column(BigInteger, nullable=False)
+    updated_at = Column(BigInteger, nullable=False)
+
+    def to_dict(self):
+        """转换为字典，用于API响应"""
+        return {
+            'id': self.id,
+            'name': self.name,
+            'description': self.description,
+            'repo_ids': [r for r in (self.repo_ids or '').split(',') if r],
+            'branch': self.branch,
+            'path_prefix': self.path_prefix,
+            'days': self.days,
+            'created_by': self.created_by,
+            'created_at': self.created_at,
+            'updated_at': self.updated_at
+        }
+
+
+# TestRunResult 测试运行结果模型
+# 来自 JUnit XML 的测试结果摘要，与同 commit 的覆盖率数据关联
+class TestRunResult(Base):
+    __tablename__ = 'test_run_results'
+
+    id = Column(Integer, primary_key=True)
+    repo_id = Column(String(64), nullable=False, index=True)
+    branch = Column(String(255), nullable=False, index=True)
+    commit = Column(String(100), nullable=False, index=True)
+    total_tests = Column(Integer, nullable=False, default=0)
+    passed = Column(Integer, nullable=False, default=0)
+    failed = Column(Integer, nullable=False, default=0)
+    errors = Column(Integer, nullable=False, default=0)
+    skipped = Column(Integer, nullable=False, default=0)
+    duration_ms = Column(BigInteger, nullable=False, default=0)
+    created_at = Column(BigInteger, nullable=False, index=True)
+
+    def to_dict(self):
+        """转换为字典，用于API响应"""
+        return {
+            'id': self.id,
+            'repo_id': self.repo_id,
+            'branch': self.branch,
+            'commit': self.commit,
+            'total_tests': self.total_tests,
+            'passed': self.passed,
+            'failed': self.failed,
+            'errors': self.errors,
+            'skipped': self.skipped,
+            'duration_ms': self.duration_ms,
+            'created_at': self.created_at
+        }
+
+
+# MutationRecord 变异测试结果模型
+# 记录单个变异体的位置与结果，用于与覆盖率数据关联
+class MutationRecord(Base):
+    __tablename__ = 'mutation_records'
+
+    id = Column(Integer, primary_key=True)
+    repo_id = Column(String(64), nullable=False, index=True)
+    branch = Column(String(255), nullable=False, index=True)
+    commit = Column(String(100), nullable=False)
+    file_path = Column(String(500), nullable=False, index=True)
+    line = Column(Integer, nullable=False)
+    mutator = Column(String(255), nullable=False, default='')  # 变异算子名称
+    status = Column(String(50), nullable=False, index=True)  # killed/survived/timeout/skipped
+    created_at = Column(BigInteger, nullable=False)
+
+    def to_dict(self):
+        """转换为字典，用于API响应"""
+        return {
+            'id': self.id,
+            'repo_id': self.repo_id,
+            'branch': self.branch,
+            'commit': self.commit,
+            'file': self.file_path,
+            'line': self.line,
+            'mutator': self.mutator,
+            'status': self.status,
+            'created_at': self.created_at
+        }
+
+
+# ApiKey API密钥模型
+# 密钥只存储SHA256哈希，明文仅在创建时返回一次
+class ApiKey(Base):
+    __tablename__ = 'api_keys'
+
+    id = Column(Integer, primary_key=True)
+    name = Column(String(255), nullable=False, default='')  # 密钥用途说明
+    key_hash = Column(String(64), nullable=False, unique=True, index=True)
+    key_prefix = Column(String(12), nullable=False, default='')  # 明文前缀，用于列表展示识别
+    scopes = Column(Text)  # 授权范围，分号分隔（如 "read:coverage;write:reports"）
+    expires_at = Column(BigInteger, nullable=False, default=0)  # 过期时间（毫秒），0表示永不过期
+    last_used_at = Column(BigInteger, nullable=False, default=0)  # 最后使用时间（毫秒）
+    revoked = Column(Integer, nullable=False, default=0)  # 是否已吊销
+    created_at = Column(BigInteger, nullable=False)
+
+    def to_dict(self):
+        """转换为字典，用于API响应（不包含密钥哈希）"""
+        return {
+            'id': self.id,
+            'name': self.name,
+            'key_prefix': self.key_prefix,
+            'scopes': (self.scopes or '').split(';') if self.scopes else [],
+            'expires_at': self.expires_at,
+            'last_used_at': self.last_used_at,
+            'revoked': bool(self.revoked),
+            'created_at': self.created_at
+        }
+
+
+# CoverageSnapshot 覆盖率快照模型
+# 每次consumer处理完报告后记录一条快照，用于趋势类查询（徽章趋势箭头等）
+# 注意：coverage_reports 按 repo_id+branch 原地更新，历史数据只保留在快照表中
+class CoverageSnapshot(Base):
+    __tablename__ = 'coverage_snapshots'
+
+    id = Column(Integer, primary_key=True)
+    repo_id = Column(String(64), nullable=False, index=True)
+    branch = Column(String(255), nullable=False, index=True)
+    commit = Column(String(100), nullable=False)
+    total_statements = Column(Integer, nullable=False, default=0)
+    covered_statements = Column(Integer, nullable=False, default=0)
+    coverage_rate = Column(Integer, nullable=False, default=0)  # 覆盖率*100，整数存储避免浮点误差
+    created_at = Column(BigInteger, nullable=False, index=True)  # 报告声明的时间（经时钟信任策略修正）
+    received_at = Column(BigInteger, nullable=False, default=0, index=True)  # 平台收到的服务端时间
+    # 自定义key/value元数据（JSON文本，随报告透传，趋势查询可按其过滤）
+    report_metadata = Column('metadata', Text)
+
+    def to_dict(self):
+        """转换为字典，用于API响应"""
+        return {
+            'id': self.id,
+            'repo_id': self.repo_id,
+            'branch': self.branch,
+            'commit': self.commit,
+            'total_statements': self.total_statements,
+            'covered_statements': self.covered_statements,
+            'coverage_rate': self.coverage_rate / 100.0,
+            'metadata': json.loads(self.report_metadata) if self.report_metadata else {},
+            'created_at': self.created_at,
+            'received_at': self.received_at
+        }
+
+
+# CoverageFileSnapshot 文件级覆盖率快照模型
+# 每次报告处理时按文件追加（文件的ranges是原地覆盖的，历史只能从这里查询）
+# 用于回答"某个关键文件是什么时候丢掉测试的"
+class CoverageFileSnapshot(Base):
+    __tablename__ = 'coverage_file_snapshots'
+
+    id = Column(Integer, primary_key=True)
+    repo_id = Column(String(64), nullable=False, index=True)
+    branch = Column(String(255), nullable=False, index=True)
+    commit = Column(String(100), nullable=False)
+    file_path = Column(String(500), nullable=False, index=True)
+    total_statements = Column(Integer, nullable=False, default=0)
+    covered_statements = Column(Integer, nullable=False, default=0)
+    coverage_rate = Column(Integer, nullable=False, default=0)  # 覆盖率*100，整数存储避免浮点误差
+    created_at = Column(BigInteger, nullable=False, index=True)
+
+    def to_dict(self):
+        """转换为字典，用于API响应"""
+        return {
+            'id': self.id,
+            'repo_id': self.repo_id,
+            'branch': self.branch,
+            'commit': self.commit,
+            'file': self.file_path,
+            'total_statements': self.total_statements,
+            'covered_statements': self.covered_statements,
+            'coverage_rate': self.coverage_rate / 100.0,
+            'created_at': self.created_at
+        }
+
+
+# ComponentHeartbeat 组件心跳模型
+# 各接入通道（consumer、HTTP上传等）每次成功/失败处理后更新一条心跳
+# 状态页接口据此回答"平台侧是否正常、上次成功处理是什么时候"
+class ComponentHeartbeat(Base):
+    __tablename__ = 'component_heartbeats'
+
+    id = Column(Integer, primary_key=True)
+    component = Column(String(100), nullable=False, unique=True, index=True)  # 组件名
+    status = Column(String(50), nullable=False, default='ok')  # ok/error
+    detail = Column(String(500), nullable=False, default='')  # 最近一次错误摘要
+    last_success_at = Column(BigInteger, nullable=False, default=0)  # 上次成功处理时间（毫秒）
+    updated_at = Column(BigInteger, nullable=False)
+
+    def to_dict(self):
+        """转换为字典，用于API响应"""
+        return {
+            'component': self.component,
+            'status': self.status,
+            'detail': self.detail,
+            'last_success_at': self.last_success_at,
+            'updated_at': self.updated_at
+        }
+
+
+# RegressionEvent 覆盖率回退事件模型
+# 快照覆盖率相对上一次明显下降时记录一条事件，作为告警与事故关联的原始数据
+class RegressionEvent(Base):
+    __tablename__ = 'regression_events'
+
+    id = Column(Integer, primary_key=True)
+    repo_id = Column(String(64), nullable=False, index=True)
+    branch = Column(String(255), nullable=False)
+    drop_rate = Column(Integer, nullable=False)  # 下降幅度（百分点*100）
+    incident_id = Column(Integer, nullable=False, default=0, index=True)  # 归并到的事故ID，0表示独立事件
+    created_at = Column(BigInteger, nullable=False, index=True)
+
+    def to_dict(self):
+        """转换为字典，用于API响应"""
+        return {
+            'id': self.id,
+            'repo_id': self.repo_id,
+            'branch': self.branch,
+            'drop_rate': self.drop_rate / 100.0,
+            'incident_id': self.incident_id,
+            'created_at': self.created_at
+        }
+
+
+# PlatformIncident 平台事故模型
+# 短时间内多个仓库同时回退（解析bug、broker故障等）时，
+# 把成百条仓库级告警归并成一条平台级事故，避免告警风暴
+class PlatformIncident(Base):
+    __tablename__ = 'platform_incidents'
+
+    id = Column(Integer, primary_key=True)
+    status = Column(String(50), nullable=False, default='active', index=True)  # active/resolved
+    repo_count = Column(Integer, nullable=False, default=0)  # 涉及的仓库数
+    detail = Column(String(500), nullable=False, default='')
+    started_at = Column(BigInteger, nullable=False, index=True)
+    last_event_at = Column(BigInteger, nullable=False)
+
+    def to_dict(self):
+        """转换为字典，用于API响应"""
+        return {
+            'id': self.id,
+            'status': self.status,
+            'repo_count': self.repo_count,
+            'detail': self.detail,
+            'started_at': self.started_at,
+            'last_event_at': self.last_event_at
+        }
+
+
+# StaleBranch 失效分支模型
+# 分支删除webhook到达后记录一条，数据先标记为stale，
+# 到达保留期后由清理任务真正删除，避免仓库视图被大量死分支淹没
+class StaleBranch(Base):
+    __tablename__ = 'stale_branches'
+
+    id = Column(Integer, primary_key=True)
+    repo_id = Column(String(64), nullable=False, index=True)
+    branch = Column(String(255), nullable=False)
+    status = Column(String(50), nullable=False, default='stale', index=True)  # stale/purged
+    deleted_at = Column(BigInteger, nullable=False)  # 分支删除时间（毫秒）
+    purge_after = Column(BigInteger, nullable=False, index=True)  # 允许清理的时间（毫秒）
+    purged_at = Column(BigInteger, nullable=False, default=0)
+
+    def to_dict(self):
+        """转换为字典，用于API响应"""
+        return {
+            'id': self.id,
+            'repo_id': self.repo_id,
+            'branch': self.branch,
+            'status': self.status,
+            'deleted_at': self.deleted_at,
+            'purge_after': self.purge_after,
+            'purged_at': self.purged_at
+        }
+
+
+# ShareLink 只读分享链接模型
+# 面向外部审计、开源贡献者等无账号访问者，按commit粒度限时开放覆盖率视图
+# token只存散列，明文只在创建时返回一次（与API Key同样的处理方式）
+class ShareLink(Base):
+    __tablename__ = 'share_links'
+
+    id = Column(Integer, primary_key=True)
+    token_hash = Column(String(64), nullable=False, unique=True, index=True)
+    repo_id = Column(String(64), nullable=False, index=True)
+    branch = Column(String(255), nullable=False)
+    commit = Column(String(100), nullable=False)
+    created_by = Column(String(100), nullable=False, default='')
+    expires_at = Column(BigInteger, nullable=False, index=True)  # 过期时间（毫秒）
+    created_at = Column(BigInteger, nullable=False)
+
+    def to_dict(self):
+        """转换为字典，用于API响应（不含token散列）"""
+        return {
+            'id': self.id,
+            'repo_id': self.repo_id,
+            'branch': self.branch,
+            'commit': self.commit,
+            'created_by': self.created_by,
+            'expires_at': self.expires_at,
+            'created_at': self.created_at
+        }
+
+
+# ReconciliationCheck 队列-存储对账记录模型
+# 对账任务每轮比较broker侧已确认消息数与库内落盘条数，结果记录一条
+# broker_ack_total保存broker的累计ack计数，下一轮据此算出窗口内增量
+class ReconciliationCheck(Base):
+    __tablename__ = 'reconciliation_checks'
+
+    id = Column(Integer, primary_key=True)
+    status = Column(String(50), nullable=False, default='ok')  # baseline/ok/gap/reset
+    window_start = Column(BigInteger, nullable=False)  # 对账窗口起点（毫秒）
+    window_end = Column(BigInteger, nullable=False)  # 对账窗口终点（毫秒）
+    broker_acked = Column(Integer, nullable=False, default=0)  # 窗口内broker确认的消息数
+    persisted_count = Column(Integer, nullable=False, default=0)  # 窗口内落盘的报告数
+    gap = Column(Integer, nullable=False, default=0)  # 差值（broker_acked - persisted_count）
+    broker_ack_total = Column(BigInteger, nullable=False, default=0)  # broker累计ack计数采样
+    created_at = Column(BigInteger, nullable=False, index=True)
+
+    def to_dict(self):
+        """转换为字典，用于API响应"""
+        return {
+            'id': self.id,
+            'status': self.status,
+            'window_start': self.window_start,
+            'window_end': self.window_end,
+            'broker_acked': self.broker_acked,
+            'persisted_count': self.persisted_count,
+            'gap': self.gap,
+            'created_at': self.created_at
+        }
+
+
+# TrendAnnotation 趋势标注模型
+# 外部事件（发布、故障、测试基础设施中断等）通过webhook进来后落库，
+# 趋势接口按时间窗口叠加返回，覆盖率突变可以直接对上已知事件
+class TrendAnnotation(Base):
+    __tablename__ = 'trend_annotations'
+
+    id = Column(Integer, primary_key=True)
+    repo_id = Column(String(100), index=True)  # 为空表示全局事件（对所有仓库生效）
+    event_type = Column(String(50), nullable=False)  # deploy/incident/outage
+    title = Column(String(500), nullable=False)  # 事件标题
+    detail = Column(Text)  # 事件详情（可选）
+    source = Column(String(200))  # 事件来源系统（可选）
+    occurred_at = Column(BigInteger, nullable=False, index=True)  # 事件发生时间（毫秒）
+    created_at = Column(BigInteger, nullable=False)
+
+    def to_dict(self):
+        """转换为字典，用于API响应"""
+        return {
+            'id': self.id,
+            'repo_id': self.repo_id,
+            'event_type': self.event_type,
+            'title': self.title,
+            'detail': self.detail,
+            'source': self.source,
+            'occurred_at': self.occurred_at,
+            'created_at': self.created_at
+        }
+
+
+# ConsumerLease 消费者租约模型
+# 每个队列同一时刻只有一个消费者持有租约，滚动升级时新消费者
+# 通过handover_requested_by发起交接，旧消费者排空在途消息后释放
+class ConsumerLease(Base):
+    __tablename__ = 'consumer_leases'
+
+    id = Column(Integer, primary_key=True)
+    queue = Column(String(128), unique=True, nullable=False)  # 队列名
+    holder = Column(String(128), nullable=False)  # 持有者标识（主机-进程-随机后缀）
+    version = Column(String(64))  # 持有者版本（便于排查交接双方版本）
+    acquired_at = Column(BigInteger, nullable=False)  # 获取时间（毫秒）
+    heartbeat_at = Column(BigInteger, nullable=False)  # 最近心跳时间（毫秒）
+    handover_requested_by = Column(String(128))  # 请求交接的新消费者标识
+    created_at = Column(BigInteger, nullable=False)
+
+    def to_dict(self):
+        """转换为字典，用于API响应"""
+        return {
+            'id': self.id,
+            'queue': self.queue,
+            'holder': self.holder,
+            'version': self.version,
+            'acquired_at': self.acquired_at,
+            'heartbeat_at': self.heartbeat_at,
+            'handover_requested_by': self.handover_requested_by,
+            'created_at': self.created_at
+        }
+
+
+# IngestTiming 报告处理耗时模型
+# 消费端处理每份报告时记录各阶段耗时，管理端接口据此找出
+# 最慢的仓库/阶段，优化有的放矢而不是靠猜
+class IngestTiming(Base):
+    __tablename__ = 'ingest_timings'
+
+    id = Column(Integer, primary_key=True)
+    repo_id = Column(String(100), nullable=False, index=True)
+    branch = Column(String(500), nullable=False)
+    commit = Column(String(100), nullable=False)
+    total_ms = Column(Integer, nullable=False, default=0)  # 处理总耗时（毫秒）
+    stages = Column(Text)  # 各阶段耗时JSON（{阶段名: 毫秒}）
+    created_at = Column(BigInteger, nullable=False, index=True)
+
+    def to_dict(self):
+        """转换为字典，用于API响应"""
+        return {
+            'id': self.id,
+            'repo_id': self.repo_id,
+            'branch': self.branch,
+            'commit': self.commit,
+            'total_ms': self.total_ms,
+            'stages': self.stages,
+            'created_at': self.created_at
+        }
+
+
+# FileRename 文件重命名模型
+# 入库时通过git的重命名检测记录old_path->new_path的映射，
+# 文件历史查询沿映射链回溯旧名——重命名后的文件不会在趋势里
+# 变成一个历史为空的"新文件"
+class FileRename(Base):
+    __tablename__ = 'file_renames'
+
+    id = Column(Integer, primary_key=True)
+    repo_id = Column(String(100), nullable=False, index=True)
+    branch = Column(String(500), nullable=False)
+    old_path = Column(String(1000), nullable=False)
+    new_path = Column(String(1000), nullable=False)
+    similarity = Column(Integer, nullable=False, default=0)  # git相似度百分比
+    commit = Column(String(100), nullable=False)  # 检测到重命名的commit
+    created_at = Column(BigInteger, nullable=False)
+
+    def to_dict(self):
+        """转换为字典，用于API响应"""
+        return {
+            'id': self.id,
+            'repo_id': self.repo_id,
+            'branch': self.branch,
+            'old_path': self.old_path,
+            'new_path': self.new_path,
+            'similarity': self.similarity,
+            'commit': self.commit,
+            'created_at': self.created_at
+        }
+
+
+# GateException 门禁豁免模型
+# 带截止日期的路径级门禁豁免：生效期内匹配的文件不计入增量门禁，
+# 到期自动失效重新进入门禁——"临时"豁免不会一直活下去
+class GateException(Base):
+    __tablename__ = 'gate_exceptions'
+
+    id = Column(Integer, primary_key=True)
+    repo_id = Column(String(100), nullable=False, index=True)
+    path_pattern = Column(String(1000), nullable=False)  # fnmatch模式或目录前缀（以/结尾）
+    reason = Column(Text, nullable=False)  # 豁免理由（必填，留痕用）
+    created_by = Column(String(200))  # 创建人
+    expires_at = Column(BigInteger, nullable=False)  # 截止时间（毫秒，必填）
+    created_at = Column(BigInteger, nullable=False)
+
+    def to_dict(self):
+        """转换为字典，用于API响应"""
+        return {
+            'id': self.id,
+            'repo_id': self.repo_id,
+            'path_pattern': self.path_pattern,
+            'reason': self.reason,
+            'created_by': self.created_by,
+            'expires_at': self.expires_at,
+            'created_at': self.created_at
+        }
+
+
+# DomainEvent 领域事件模型
+# 只追加的事件表，自增id即序列号：报告入库/覆盖率合并/门禁评估/
+# 通知发出都会落一条事件，下游新功能可以从任意序列号开始回放历史
+class DomainEvent(Base):
+    __tablename__ = 'domain_events'
+
+    id = Column(Integer, primary_key=True)  # 自增序列号（回放游标）
+    event_type = Column(String(100), nullable=False, index=True)
+    repo_id = Column(String(100), index=True)  # 与仓库无关的事件为空
+    payload = Column(Text)  # 事件内容JSON
+    created_at = Column(BigInteger, nullable=False, index=True)
+
+    def to_dict(self):
+        """转换为字典，用于API响应"""
+        return {
+            'id': self.id,
+            'event_type': self.event_type,
+            'repo_id': self.repo_id,
+            'payload': self.payload,
+            'created_at': self.created_at
+        }
+
+
+# TestGapIssue 测试缺口建单模型
+# 定时扫描建单的去重记录：每个仓库+文件最多一条open记录，
+# 覆盖率修复后自动关单——覆盖率缺口变成可跟踪的工作项
+class TestGapIssue(Base):
+    __tablename__ = 'test_gap_issues'
+
+    id = Column(Integer, primary_key=True)
+    repo_id = Column(String(100), nullable=False, index=True)
+    file_path = Column(String(1000), nullable=False)
+    tracker = Column(String(50), nullable=False, default='gitlab')  # 建单的跟踪系统
+    issue_key = Column(String(100), nullable=False)  # issue标识（GitLab为iid）
+    status = Column(String(50), nullable=False, default='open')  # open/closed
+    coverage_rate = Column(Integer, nullable=False, default=0)  # 最近一次扫描到的覆盖率*100
+    created_at = Column(BigInteger, nullable=False)
+    updated_at = Column(BigInteger, nullable=False)
+
+    def to_dict(self):
+        """转换为字典，用于API响应"""
+        return {
+            'id': self.id,
+            'repo_id': self.repo_id,
+            'file_path': self.file_path,
+            'tracker': self.tracker,
+            'issue_key': self.issue_key,
+            'status': self.status,
+            'coverage_rate': self.coverage_rate,
+            'created_at': self.created_at,
+            'updated_at': self.updated_at
+        }
+
+
 # CoverageConfig 配置模型
 class CoverageConfig(Base):
     __tablename__ = 'coverage_config'
@@ -171,6 +889,9 @@ class CoverageConfig(Base):
     base_branch = Column(String(255), nullable=False, default='master')
     exclude_dirs = Column(Text)  # 排除目录，分号分隔
     exclude_files = Column(Text)  # 排除文件后缀，分号分隔
+    gate_threshold = Column(Integer, nullable=False, default=0)  # 增量覆盖率门禁阈值（百分比整数，0表示不启用门禁）
+    criticality_weights = Column(Text)  # 关键路径权重，格式 "路径前缀=权重;..."，如 "payment/=3;internal/tools/=0.5"
+    freshness_slo_hours = Column(Integer, nullable=False, default=0)  # 基准分支覆盖率新鲜度SLO（小时，0表示用全局默认）
     created_at = Column(BigInteger, nullable=False)
     updated_at = Column(BigInteger, nullable=False)
     
@@ -193,19 +914,40 @@ class CoverageConfig(Base):
             'base_branch': self.base_branch,
             'exclude_dirs': self.exclude_dirs or '',
             'exclude_files': self.exclude_files or '',
+            'gate_threshold': self.gate_threshold,
+            'criticality_weights': self.criticality_weights or '',
+            'freshness_slo_hours': self.freshness_slo_hours,
             'created_at': self.created_at,
             'updated_at': self.updated_at
         }
 
 
 def get_db_session():
-    """获取数据库会话"""
+    """获取数据库会话（优先通道内返回优先池的会话）"""
+    if getattr(_lane, 'priority', False):
+        return PrioritySessionLocal()
     return SessionLocal()
 
 
+@contextmanager
+def priority_lane():
+    """
+    把当前线程切入优先通道
+    作用域内所有 get_db_session 调用（含嵌套的manager调用）都走优先池，
+    门禁评估、MR评论等阻塞合并的路径用它包住入口
+    """
+    _lane.priority = True
+    try:
+        yield
+    finally:
+        _lane.priority = False
+        PrioritySessionLocal.remove()
+
+
 def close_db_session():
     """关闭数据库会话（用于Flask teardown）"""
     SessionLocal.remove()
+    PrioritySessionLocal.remove()
 
 
 def init_db():
@@ -0,0 +1,129 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+覆盖率上报 CLI
+在 CI 侧把覆盖率报告发布到消息队列，支持两种模式:
+    raw: 上传完整原始报告（默认，与 examples/publish_example.py 行为一致）
+    aggregate: 本地解析并预聚合为 orbit-agg 行位图格式后上传，
+               大仓库下载荷体积和服务端解析开销都显著降低
+
+用法:
+    python orbit_upload.py --file coverage.out --format goc \\
+        --repo https://gitlab.com/group/repo.git --repo-id <repo_id> \\
+        --branch master --commit <sha> [--mode aggregate]
+"""
+
+import argparse
+import base64
+import json
+import logging
+import sys
+import time
+
+from manager.manager import parse_coverage_payload
+from manager.publish_manager import publish_or_spool
+
+logging.basicConfig(
+    level=logging.INFO,
+    format='%(asctime)s - %(name)s - %(levelname)s - %(message)s'
+)
+logger = logging.getLogger(__name__)
+
+
+def _set_bit(bitmap: bytearray, line: int) -> None:
+    """置位行位图（bit 0 = 第1行）"""
+    byte_idx, bit_idx = (line - 1) // 8, (line - 1) % 8
+    while len(bitmap) <= byte_idx:
+        bitmap.append(0)
+    bitmap[byte_idx] |= (1 << bit_idx)
+
+
+def aggregate_coverage(coverage_format: str, raw: str) -> str:
+    """
+    本地解析原始报告并聚合为 orbit-agg 格式（JSON 字符串）
+    每个文件只保留两张行位图: 可覆盖行（tracked）与已覆盖行（covered）
+    """
+    file_coverage = parse_coverage_payload(coverage_format, raw)
+
+    files = {}
+    for file_path, ranges in file_coverage.items():
+        tracked = bytearray()
+        covered = bytearray()
+        for r in ranges:
+            for line in range(r['start_line'], r['end_line'] + 1):
+                _set_bit(tracked, line)
+                if r['hit'] > 0:
+                    _set_bit(covered, line)
+        files[file_path] = {
+            'tracked': base64.b64encode(bytes(tracked)).decode('ascii'),
+            'covered': base64.b64encode(bytes(covered)).decode('ascii')
+        }
+
+    return json.dumps({'files': files})
+
+
+def main():
+    parser = argparse.ArgumentParser(description='Publish a coverage report to the Orbit platform')
+    parser.add_argument('--file', required=True, help='Coverage report file')
+    parser.add_argument('--format', default='goc', help='Coverage format (default: goc)')
+    parser.add_argument('--repo', required=True, help='Repository URL')
+    parser.add_argument('--repo-id', required=True, help='Orbit repo_id (must exist in coverage_config)')
+    parser.add_argument('--branch', required=True, help='Branch name')
+    parser.add_argument('--commit', required=True, help='Commit hash')
+    parser.add_argument('--mode', default='raw', choices=['raw', 'aggregate'],
+                        help='raw: upload the full report; aggregate: pre-aggregate locally (default: raw)')
+    parser.add_argument('--ci-provider', default='', help='CI provider name')
+    parser.add_argument('--ci-pipeline-id', default='', help='CI pipeline ID')
+    parser.add_argument('--ci-job-id', default='', help='CI job ID')
+    args = parser.parse_args()
+
+    with open(args.file, 'r', encoding='utf-8') as f:
+        raw = f.read()
+
+    if args.mode == 'aggregate':
+        try:
+            payload_raw = aggregate_coverage(args.format, raw)
+        except Exception as e:
+            logger.error(f"Failed to aggregate coverage locally: {e}")
+            sys.exit(1)
+        payload_format = 'orbit-agg'
+        logger.info(
+            f"Pre-aggregated coverage: {len(raw)} -> {len(payload_raw)} bytes"
+        )
+    else:
+        payload_raw = raw
+        payload_format = args.format
+
+    message = {
+        'repo': args.repo,
+        'repo_id': args.repo_id,
+        'branch': args.branch,
+        'commit': args.commit,
+        'ci': {
+            'provider': args.ci_provider,
+            'pipeline_id': args.ci_pipeline_id,
+            'job_id': args.ci_job_id
+        },
+        'coverage': {
+            'format': payload_format,
+            'raw': payload_raw
+        },
+        'timestamp': int(time.time())
+    }
+
+    # broker 不可达时消息会落盘到 spool 目录，下次调用时自动补发
+    if publish_or_spool(message):
+        logger.info(
+            f"Published coverage report: repo_id={args.repo_id}, "
+            f"branch={args.branch}, commit={args.commit}, mode={args.mode}"
+        )
+    else:
+        logger.warning(
+            f"Broker unreachable, report spooled locally: repo_id={args.repo_id}, "
+            f"branch={args.branch}, commit={args.commit}"
+        )
+
+
+if __name__ == '__main__':
+    main()
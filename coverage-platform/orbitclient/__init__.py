@@ -0,0 +1,295 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+覆盖率平台API客户端
+给内部工具用的类型化读客户端：统一的重试/退避、分页迭代器、
+dataclass模型——不用再各自复制HTTP结构体然后跟服务端漂移
+
+只封装读接口；模型字段与服务端响应保持一致，服务端新增字段
+不会导致客户端报错（未知字段收进raw里）
+
+用法示例:
+    from orbitclient import OrbitClient
+    client = OrbitClient('http://coverage.internal:8826', api_key='orbit_...')
+    for report in client.iter_reports(repo='tuna'):
+        print(report.repo_name, report.coverage_rate)
+"""
+
+import logging
+import time
+from dataclasses import dataclass, field
+from typing import Dict, Iterator, List, Optional
+
+import requests
+
+logger = logging.getLogger(__name__)
+
+__all__ = [
+    'OrbitClient',
+    'OrbitAPIError',
+    'Report',
+    'FileSummary',
+    'TrendPoint',
+    'GateResult',
+]
+
+# 重试的HTTP状态码（服务端瞬时故障）
+RETRYABLE_STATUS = (502, 503, 504)
+
+
+class OrbitAPIError(Exception):
+    """API调用失败（重试耗尽或服务端返回错误）"""
+
+    def __init__(self, message: str, status: Optional[int] = None):
+        super().__init__(message)
+        self.status = status
+
+
+def _pick(data: Dict, keys: List[str]) -> Dict:
+    """取已知字段，其余留在raw里（容忍服务端新增字段）"""
+    return {k: data.get(k) for k in keys}
+
+
+@dataclass
+class Report:
+    """覆盖率报告（/api/v1/coverage/reports条目）"""
+    id: int
+    repo_id: str
+    repo_name: str
+    branch: str
+    commit: str
+    status: str
+    coverage_format: Optional[str] = None
+    total_statements: int = 0
+    covered_statements: int = 0
+    coverage_rate: float = 0.0
+    base_branch: Optional[str] = None
+    base_commit: Optional[str] = None
+    created_at: int = 0
+    raw: Dict = field(default_factory=dict)
+
+    @classmethod
+    def from_dict(cls, data: Dict) -> 'Report':
+        known = _pick(data, [
+            'id', 'repo_id', 'repo_name', 'branch', 'commit', 'status',
+            'coverage_format', 'total_statements', 'covered_statements',
+            'coverage_rate', 'base_branch', 'base_commit', 'created_at'
+        ])
+        return cls(**{k: v for k, v in known.items() if v is not None},
+                   raw=data)
+
+
+@dataclass
+class FileSummary:
+    """文件覆盖率摘要（/api/v1/coverage/files条目）"""
+    id: int
+    repo_id: str
+    branch: str
+    file: str
+    total_statements: int = 0
+    covered_statements: int = 0
+    coverage_rate: float = 0.0
+    raw: Dict = field(default_factory=dict)
+
+    @classmethod
+    def from_dict(cls, data: Dict) -> 'FileSummary':
+        known = _pick(data, [
+            'id', 'repo_id', 'branch', 'file',
+            'total_statements', 'covered_statements', 'coverage_rate'
+        ])
+        return cls(**{k: v for k, v in known.items() if v is not None},
+                   raw=data)
+
+
+@dataclass
+class TrendPoint:
+    """趋势点（/api/v1/coverage/trend条目）"""
+    commit: str
+    total_statements: int = 0
+    covered_statements: int = 0
+    coverage_rate: float = 0.0
+    created_at: int = 0
+    raw: Dict = field(default_factory=dict)
+
+    @classmethod
+    def from_dict(cls, data: Dict) -> 'TrendPoint':
+        known = _pick(data, [
+            'commit', 'total_statements', 'covered_statements',
+            'coverage_rate', 'created_at'
+        ])
+        return cls(**{k: v for k, v in known.items() if v is not None},
+                   raw=data)
+
+
+@dataclass
+class GateResult:
+    """
+    门禁评估结果（/api/v1/coverage/reports/<id>/gate）
+    服务端返回verdict（passed/failed/advisory/disabled），
+    passed由verdict推导：只有failed算不通过（advisory/disabled不阻塞）
+    """
+    verdict: str
+    passed: bool
+    raw: Dict = field(default_factory=dict)
+
+    @classmethod
+    def from_dict(cls, data: Dict) -> 'GateResult':
+        verdict = data.get('verdict', '')
+        return cls(verdict=verdict, passed=verdict != 'failed', raw=data)
+
+
+class OrbitClient:
+    """
+    覆盖率平台读客户端
+
+    参数:
+        base_url: 平台地址，如 http://coverage.internal:8826
+        api_key: API key（可选，访问需要鉴权的接口时必填）
+        timeout: 单次请求超时秒数（默认15）
+        max_retries: 瞬时故障重试次数（默认3）
+        backoff_seconds: 重试退避基数，按2^n指数增长（默认0.5）
+    """
+
+    def __init__(self, base_url: str, api_key: Optional[str] = None,
+                 timeout: int = 15, max_retries: int = 3,
+                 backoff_seconds: float = 0.5):
+        self.base_url = base_url.rstrip('/')
+        self.api_key = api_key
+        self.timeout = timeout
+        self.max_retries = max_retries
+        self.backoff_seconds = backoff_seconds
+        self._session = requests.Session()
+
+    def _get(self, path: str, params: Optional[Dict] = None) -> Dict:
+        """GET请求，瞬时故障按指数退避重试"""
+        headers = {}
+        if self.api_key:
+            headers['X-API-Key'] = self.api_key
+
+        url = f'{self.base_url}{path}'
+        last_error = None
+        for attempt in range(self.max_retries + 1):
+            if attempt:
+                time.sleep(self.backoff_seconds * (2 ** (attempt - 1)))
+            try:
+                response = self._session.get(
+                    url, params=params, headers=headers, timeout=self.timeout
+                )
+            except requests.RequestException as e:
+                last_error = str(e)
+                logger.warning(f"Request to {path} failed: {e}")
+                continue
+
+            if response.status_code in RETRYABLE_STATUS:
+                last_error = f'HTTP {response.status_code}'
+                continue
+            if response.status_code != 200:
+                raise OrbitAPIError(
+                    f'{path} returned {response.status_code}: {response.text[:200]}',
+                    status=response.status_code
+                )
+            return response.json()
+
+        raise OrbitAPIError(f'{path} failed after retries: {last_error}')
+
+    # -------- 报告 --------
+
+    def list_reports(self, repo: Optional[str] = None,
+                     branch: Optional[str] = None,
+                     status: Optional[str] = None,
+                     page: int = 1, page_size: int = 20) -> List[Report]:
+        """获取单页报告列表"""
+        params = {'page': page, 'page_size': page_size}
+        if repo:
+            params['repo'] = repo
+        if branch:
+            params['branch'] = branch
+        if status:
+            params['status'] = status
+        data = self._get('/api/v1/coverage/reports', params)
+        return [Report.from_dict(item) for item in data.get('data', [])]
+
+    def iter_reports(self, repo: Optional[str] = None,
+                     branch: Optional[str] = None,
+                     status: Optional[str] = None,
+                     page_size: int = 100) -> Iterator[Report]:
+        """分页迭代全部报告（自动翻页）"""
+        page = 1
+        while True:
+            batch = self.list_reports(
+                repo=repo, branch=branch, status=status,
+                page=page, page_size=page_size
+            )
+            if not batch:
+                return
+            for report in batch:
+                yield report
+            if len(batch) < page_size:
+                return
+            page += 1
+
+    def get_report(self, report_id: int) -> Dict:
+        """获取报告详情（全量覆盖率，结构见服务端接口）"""
+        return self._get(f'/api/v1/coverage/reports/{report_id}')
+
+    def get_report_lineage(self, report_id: int) -> Dict:
+        """获取报告血缘"""
+        return self._get(f'/api/v1/coverage/reports/{report_id}/lineage')
+
+    # -------- 文件 --------
+
+    def list_files(self, repo_id: Optional[str] = None,
+                   branch: Optional[str] = None,
+                   report_id: Optional[int] = None) -> List[FileSummary]:
+        """获取文件覆盖率摘要列表"""
+        params = {}
+        if repo_id:
+            params['repo_id'] = repo_id
+        if branch:
+            params['branch'] = branch
+        if report_id:
+            params['report_id'] = report_id
+        data = self._get('/api/v1/coverage/files', params)
+        return [FileSummary.from_dict(item) for item in data.get('data', [])]
+
+    def get_file(self, file_id: int,
+                 fields: Optional[List[str]] = None) -> Dict:
+        """获取文件详情（含行级ranges，可用fields裁剪）"""
+        params = {}
+        if fields:
+            params['fields'] = ','.join(fields)
+        return self._get(f'/api/v1/coverage/files/{file_id}', params)
+
+    # -------- 趋势与增量 --------
+
+    def get_trend(self, repo_id: str, branch: str, order: str = 'time',
+                  limit: int = 100) -> List[TrendPoint]:
+        """获取覆盖率趋势"""
+        data = self._get('/api/v1/coverage/trend', {
+            'repo_id': repo_id, 'branch': branch,
+            'order': order, 'limit': limit
+        })
+        return [TrendPoint.from_dict(item) for item in data.get('data', [])]
+
+    def get_diff(self, report_id: int,
+                 fields: Optional[List[str]] = None) -> Dict:
+        """获取增量覆盖率（可用fields裁剪files条目）"""
+        params = {}
+        if fields:
+            params['fields'] = ','.join(fields)
+        return self._get(f'/api/v1/coverage/reports/{report_id}/diff', params)
+
+    # -------- 门禁与其他 --------
+
+    def evaluate_gate(self, report_id: int) -> GateResult:
+        """评估门禁（只读评估，不同步GitLab）"""
+        data = self._get(f'/api/v1/coverage/reports/{report_id}/gate')
+        return GateResult.from_dict(data)
+
+    def get_changelog(self, repo_id: str, from_commit: str,
+                      to_commit: str) -> Dict:
+        """获取发布区间的覆盖率变更日志"""
+        return self._get('/api/v1/coverage/changelog', {
+            'repo_id': repo_id, 'from': from_commit, 'to': to_commit
+        })
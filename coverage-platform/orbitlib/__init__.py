@@ -0,0 +1,166 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+嵌入式库入口
+把平台核心流水线（解析、合并、规则评估、增量覆盖率）以稳定API暴露出来，
+机器人等内部工具可以进程内计算覆盖率，不需要部署整套平台
+
+这里只做纯计算，不读写数据库、不连接消息队列；
+底层实现直接复用 manager 与 diff_coverage 的现有函数，
+对外的函数签名视为稳定API，破坏性修改需要先在此层做兼容
+
+用法示例:
+    from orbitlib import parse_coverage, merge_coverage, coverage_summary
+    file_coverage = parse_coverage('goc', raw_text)
+    summary = coverage_summary(file_coverage)
+"""
+
+from typing import Dict, List, Optional
+
+from manager.manager import (
+    SUPPORTED_COVERAGE_FORMATS,
+    parse_coverage_payload,
+    merge_file_coverage,
+    should_exclude_file,
+)
+from diff_coverage import (
+    CoverageBlock,
+    DiffHunk,
+    build_coverage_index,
+    merge_diff_and_coverage,
+    generate_diff_coverage_summary,
+    parse_git_diff,
+)
+
+__all__ = [
+    'SUPPORTED_COVERAGE_FORMATS',
+    'parse_coverage',
+    'merge_coverage',
+    'coverage_summary',
+    'evaluate_threshold',
+    'diff_coverage_summary',
+    'CoverageBlock',
+    'DiffHunk',
+    'parse_git_diff',
+]
+
+
+def parse_coverage(coverage_format: str, raw: str) -> Dict[str, List[Dict]]:
+    """
+    解析单个覆盖率载荷为归一化结构
+
+    返回:
+        Dict[file_path, List[range]]，range 为
+        {'start_line', 'start_col', 'end_line', 'end_col', 'statements', 'hit'}
+
+    异常:
+        ValueError: 格式不支持或数据非法
+    """
+    return parse_coverage_payload(coverage_format, raw)
+
+
+def merge_coverage(payloads: List[Dict[str, List[Dict]]]) -> Dict[str, List[Dict]]:
+    """把多个已解析的覆盖率结构合并为一个视图（monorepo 多载荷场景）"""
+    merged: Dict[str, List[Dict]] = {}
+    for payload in payloads:
+        merge_file_coverage(merged, payload)
+    return merged
+
+
+def coverage_summary(file_coverage: Dict[str, List[Dict]],
+                     exclude_dirs: str = '',
+                     exclude_files: str = '') -> Dict:
+    """
+    计算归一化覆盖率结构的汇总指标
+
+    参数:
+        file_coverage: parse_coverage / merge_coverage 的结果
+        exclude_dirs: 排除目录（分号分隔，同平台配置格式）
+        exclude_files: 排除文件后缀（分号分隔）
+
+    返回:
+        Dict: {'total_files', 'total_statements', 'covered_statements',
+               'coverage_rate'}
+    """
+    total_files = 0
+    total_statements = 0
+    covered_statements = 0
+    for file_path, ranges in file_coverage.items():
+        if should_exclude_file(file_path, exclude_dirs, exclude_files):
+            continue
+        total_files += 1
+        for r in ranges:
+            total_statements += r['statements']
+            if r['hit'] > 0:
+                covered_statements += r['statements']
+
+    rate = (
+        covered_statements / total_statements * 100
+    ) if total_statements > 0 else 0.0
+    return {
+        'total_files': total_files,
+        'total_statements': total_statements,
+        'covered_statements': covered_statements,
+        'coverage_rate': rate
+    }
+
+
+def evaluate_threshold(coverage_rate: Optional[float], threshold: float) -> str:
+    """
+    按平台门禁规则评估覆盖率
+
+    返回:
+        str: 'disabled'（阈值为0）/ 'passed' / 'failed'
+        coverage_rate 为 None（无可覆盖行）时直接 passed
+    """
+    if not threshold:
+        return 'disabled'
+    if coverage_rate is None:
+        return 'passed'
+    return 'passed' if coverage_rate >= threshold else 'failed'
+
+
+def diff_coverage_summary(diff_hunks: List[DiffHunk],
+                          coverage_blocks_by_file: Dict[str, List[CoverageBlock]]) -> Dict:
+    """
+    进程内计算增量覆盖率
+
+    参数:
+        diff_hunks: parse_git_diff 的结果
+        coverage_blocks_by_file: 各文件的 CoverageBlock 列表
+
+    返回:
+        Dict: {'total_new_lines', 'new_covered_lines', 'new_uncovered_lines',
+               'incremental_coverage_rate', 'files'}
+    """
+    file_hunks: Dict[str, List[DiffHunk]] = {}
+    for hunk in diff_hunks:
+        file_hunks.setdefault(hunk.file_path, []).append(hunk)
+
+    file_results = []
+    for file_path, hunks in file_hunks.items():
+        index = build_coverage_index(coverage_blocks_by_file.get(file_path, []))
+        file_results.extend(merge_diff_and_coverage(hunks, index))
+
+    summary = generate_diff_coverage_summary(file_results)
+
+    files = []
+    for f in file_results:
+        measurable = f.new_covered_count + f.new_uncovered_count
+        files.append({
+            'file': f.file_path,
+            'new_covered_lines': f.new_covered_count,
+            'new_uncovered_lines': f.new_uncovered_count,
+            'coverage_rate': (
+                f.new_covered_count / measurable * 100
+            ) if measurable > 0 else None
+        })
+
+    return {
+        'total_new_lines': summary.total_new_lines,
+        'new_covered_lines': summary.new_covered_lines,
+        'new_uncovered_lines': summary.new_uncovered_lines,
+        'incremental_coverage_rate': summary.incremental_coverage_rate,
+        'files': files
+    }
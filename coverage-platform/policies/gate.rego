@@ -0,0 +1,32 @@
+# 覆盖率门禁的示例 Rego 策略
+# 加载到 OPA 后把 OPA_POLICY_PATH 设为 orbit/gate 即可生效
+# 平台提供的输入字段见 manager/opa_manager.py 的 build_gate_input
+#
+# 示例规则：
+#   - 没有新增可覆盖行直接放行
+#   - 带跳过标记的提交放行（平台侧仍会留审计日志）
+#   - 其余情况要求增量覆盖率达到仓库阈值
+
+package orbit.gate
+
+default allow := false
+
+allow if {
+    input.total_new_lines == 0
+}
+
+allow if {
+    input.skip_marker
+}
+
+allow if {
+    input.incremental_coverage_rate >= input.threshold
+}
+
+reason := "no new coverable lines" if {
+    input.total_new_lines == 0
+}
+
+reason := sprintf("diff coverage %.2f%% below threshold %d%%", [input.incremental_coverage_rate, input.threshold]) if {
+    not allow
+}
@@ -0,0 +1,43 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+前置检查工具
+手工验证部署依赖是否就绪：数据库连通性与表结构、broker拓扑权限、
+git host token有效性，输出各项检查结果并以退出码表示整体是否通过
+
+用法:
+    python preflight.py [--skip-broker]
+"""
+
+import argparse
+import json
+import logging
+import sys
+
+import version
+from manager import preflight_manager
+
+logging.basicConfig(
+    level=logging.INFO,
+    format='%(asctime)s - %(name)s - %(levelname)s - %(message)s'
+)
+logger = logging.getLogger(__name__)
+
+
+def main():
+    parser = argparse.ArgumentParser(description='部署依赖前置检查工具')
+    parser.add_argument('--version', action=version.VersionAction)
+    parser.add_argument('--skip-broker', action='store_true',
+                        help='跳过broker检查（纯查询部署）')
+    args = parser.parse_args()
+
+    result = preflight_manager.run_preflight(
+        include_broker=not args.skip_broker
+    )
+    print(json.dumps(result, ensure_ascii=False, indent=2))
+    sys.exit(0 if result['ok'] else 1)
+
+
+if __name__ == '__main__':
+    main()
@@ -0,0 +1,45 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+队列-存储对账任务
+比较RabbitMQ management API的累计ack计数与库内落盘的报告条数，
+差值超阈值时告警（状态页会标记reconciliation组件为error），
+用于自动发现静默丢消息。建议用cron或k8s CronJob周期执行
+
+用法:
+    RABBITMQ_MGMT_URL=http://rabbitmq:15672 python reconcile.py
+"""
+
+import json
+import logging
+import sys
+
+from models import init_db
+from manager import reconciliation_manager
+
+logging.basicConfig(
+    level=logging.INFO,
+    format='%(asctime)s - %(name)s - %(levelname)s - %(message)s'
+)
+logger = logging.getLogger(__name__)
+
+
+def main():
+    if not reconciliation_manager.is_enabled():
+        logger.error("RABBITMQ_MGMT_URL not configured")
+        sys.exit(2)
+
+    init_db()
+
+    result = reconciliation_manager.run_reconciliation()
+    if result is None:
+        logger.error("Reconciliation failed (broker management API unreachable)")
+        sys.exit(1)
+
+    print(json.dumps(result, ensure_ascii=False, indent=2))
+    sys.exit(1 if result['status'] == reconciliation_manager.STATUS_GAP else 0)
+
+
+if __name__ == '__main__':
+    main()
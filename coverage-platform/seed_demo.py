@@ -0,0 +1,198 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+演示数据生成工具
+向一个全新实例写入若干合成仓库、数月的覆盖率趋势和门禁场景，
+让前端开发和产品演示不依赖真实 CI 接入
+
+数据是确定性的（固定随机种子），重复执行前会清掉同 repo_id 的旧数据
+
+用法:
+    python seed_demo.py [--days 90] [--seed 42]
+"""
+
+import argparse
+import hashlib
+import logging
+import random
+import time
+
+from models import (
+    CoverageReport, CoverageFile, CoverageRange, CoverageConfig,
+    CoverageSnapshot, get_db_session, init_db
+)
+
+logging.basicConfig(
+    level=logging.INFO,
+    format='%(asctime)s - %(name)s - %(levelname)s - %(message)s'
+)
+logger = logging.getLogger(__name__)
+
+# 合成仓库定义：(repo_id, repo_name, repo_type, 起始覆盖率, 日均漂移, gate_threshold)
+DEMO_REPOS = [
+    ('demo-gateway', 'demo-gateway', 1, 72.0, 0.08, 80),    # 稳步上升，门禁80%
+    ('demo-billing', 'demo-billing', 1, 88.0, -0.05, 85),   # 缓慢劣化，接近门禁线
+    ('demo-scripts', 'demo-scripts', 2, 45.0, 0.02, 0),     # 低覆盖，未启用门禁
+]
+
+# 每个仓库的合成文件（路径, block数）
+DEMO_FILES = {
+    1: [('cmd/server/main.go', 6), ('internal/handler/handler.go', 14),
+        ('internal/service/service.go', 20), ('pkg/util/util.go', 8)],
+    2: [('scripts/deploy.py', 10), ('scripts/lib/common.py', 12)],
+    3: [('src/main/java/App.java', 16)],
+}
+
+
+def fake_commit(repo_id: str, n: int) -> str:
+    """生成确定性的假commit hash"""
+    return hashlib.sha1(f'{repo_id}-{n}'.encode('utf-8')).hexdigest()
+
+
+def clear_repo_data(db, repo_id: str) -> None:
+    """清掉该repo_id的旧演示数据"""
+    file_ids = [
+        f.id for f in db.query(CoverageFile).filter(
+            CoverageFile.repo_id == repo_id
+        ).all()
+    ]
+    if file_ids:
+        db.query(CoverageRange).filter(
+            CoverageRange.file_id.in_(file_ids)
+        ).delete(synchronize_session=False)
+    db.query(CoverageFile).filter(CoverageFile.repo_id == repo_id).delete()
+    db.query(CoverageReport).filter(CoverageReport.repo_id == repo_id).delete()
+    db.query(CoverageSnapshot).filter(CoverageSnapshot.repo_id == repo_id).delete()
+    db.query(CoverageConfig).filter(CoverageConfig.repo_id == repo_id).delete()
+
+
+def seed_repo(db, rng, repo_id, repo_name, repo_type, base_rate, drift,
+              gate_threshold, days):
+    """写入一个仓库的配置、趋势快照和最新报告"""
+    now = int(time.time() * 1000)
+    day_ms = 24 * 3600 * 1000
+
+    db.add(CoverageConfig(
+        repo_id=repo_id,
+        repo_name=repo_name,
+        repo_url=f'https://gitlab.example.com/demo/{repo_name}.git',
+        repo_type=repo_type,
+        base_branch='master',
+        exclude_dirs='',
+        exclude_files='',
+        gate_threshold=gate_threshold,
+        created_at=now - days * day_ms,
+        updated_at=now
+    ))
+
+    # 数月的趋势快照：带漂移和噪声，周末无构建
+    total_statements = rng.randrange(3000, 8000)
+    rate = base_rate
+    commit_n = 0
+    last_commit = ''
+    for day in range(days, -1, -1):
+        ts = now - day * day_ms
+        # 周末跳过（与真实CI节奏一致）
+        weekday = time.gmtime(ts / 1000).tm_wday
+        if weekday >= 5:
+            continue
+        # 每个工作日1~3次构建
+        for _ in range(rng.randrange(1, 4)):
+            commit_n += 1
+            rate = max(5.0, min(98.0, rate + drift + rng.uniform(-0.4, 0.4)))
+            covered = int(total_statements * rate / 100)
+            last_commit = fake_commit(repo_id, commit_n)
+            db.add(CoverageSnapshot(
+                repo_id=repo_id,
+                branch='master',
+                commit=last_commit,
+                total_statements=total_statements,
+                covered_statements=covered,
+                coverage_rate=int(rate * 100),
+                created_at=ts + rng.randrange(0, day_ms // 2)
+            ))
+
+    # 最新报告 + 文件级数据（与最终覆盖率一致的随机分布）
+    report = CoverageReport(
+        repo_id=repo_id,
+        repo_name=repo_name,
+        branch='master',
+        base_branch='master',
+        commit=last_commit,
+        base_commit='',
+        ci_provider='gitlab',
+        ci_pipeline_id=str(1000 + commit_n),
+        ci_job_id='test',
+        coverage_format='goc' if repo_type == 1 else ('pyca' if repo_type == 2 else 'jacoco'),
+        coverage_raw='',
+        status='completed',
+        created_at=now - days * day_ms,
+        updated_at=now
+    )
+    db.add(report)
+
+    for file_path, blocks in DEMO_FILES[repo_type]:
+        file = CoverageFile(
+            repo_id=repo_id,
+            branch='master',
+            file_path=f'{repo_name}/{file_path}' if repo_type == 1 else file_path,
+            created_at=now,
+            updated_at=now
+        )
+        db.add(file)
+        db.flush()
+
+        line = 10
+        for _ in range(blocks):
+            statements = rng.randrange(1, 6)
+            span = rng.randrange(2, 10)
+            db.add(CoverageRange(
+                file_id=file.id,
+                start_line=line,
+                start_col=2,
+                end_line=line + span,
+                end_col=2,
+                statements=statements,
+                hit=rng.randrange(0, 20) if rng.random() * 100 < rate else 0,
+                created_at=now
+            ))
+            line += span + rng.randrange(2, 8)
+
+    logger.info(
+        f"Seeded {repo_name}: {commit_n} snapshots over {days} days, "
+        f"final coverage {rate:.1f}%"
+    )
+
+
+def main():
+    parser = argparse.ArgumentParser(description='Populate a fresh instance with demo data')
+    parser.add_argument('--days', type=int, default=90,
+                        help='Days of trend history to generate (default: 90)')
+    parser.add_argument('--seed', type=int, default=42,
+                        help='Random seed (default: 42)')
+    args = parser.parse_args()
+
+    init_db()
+    rng = random.Random(args.seed)
+
+    db = get_db_session()
+    try:
+        for repo_id, repo_name, repo_type, base_rate, drift, gate_threshold in DEMO_REPOS:
+            clear_repo_data(db, repo_id)
+            seed_repo(
+                db, rng, repo_id, repo_name, repo_type,
+                base_rate, drift, gate_threshold, args.days
+            )
+        db.commit()
+        logger.info(f"Demo data seeded: {len(DEMO_REPOS)} repos")
+    except Exception as e:
+        db.rollback()
+        logger.error(f"Failed to seed demo data: {e}")
+        raise
+    finally:
+        db.close()
+
+
+if __name__ == '__main__':
+    main()
@@ -0,0 +1,62 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+存储迁移工具
+配合双写模式（MIGRATION_DUAL_WRITE）完成不停机的存储后端迁移：
+回填历史数据到目标库，并对比两边的行数与语句数做置信检查
+
+用法:
+    MIGRATION_TARGET_DSN=postgresql+psycopg2://... python storage_migration.py backfill [--repo-id xxx]
+    MIGRATION_TARGET_DSN=postgresql+psycopg2://... python storage_migration.py verify [--repo-id xxx]
+"""
+
+import argparse
+import json
+import logging
+import sys
+
+import version
+from manager import migration_manager
+
+logging.basicConfig(
+    level=logging.INFO,
+    format='%(asctime)s - %(name)s - %(levelname)s - %(message)s'
+)
+logger = logging.getLogger(__name__)
+
+
+def main():
+    parser = argparse.ArgumentParser(description='存储后端迁移工具')
+    parser.add_argument('--version', action=version.VersionAction)
+    subparsers = parser.add_subparsers(dest='command', required=True)
+
+    backfill_parser = subparsers.add_parser('backfill', help='回填历史数据到目标库')
+    backfill_parser.add_argument('--repo-id', help='只回填指定仓库')
+
+    verify_parser = subparsers.add_parser('verify', help='对比源库与目标库的一致性')
+    verify_parser.add_argument('--repo-id', help='只校验指定仓库')
+
+    args = parser.parse_args()
+
+    if not migration_manager.MIGRATION_TARGET_DSN:
+        logger.error("MIGRATION_TARGET_DSN not configured")
+        sys.exit(2)
+
+    if args.command == 'backfill':
+        result = migration_manager.backfill(repo_id=args.repo_id)
+        print(json.dumps(result, ensure_ascii=False, indent=2))
+        sys.exit(0 if not result['failed'] else 1)
+
+    if args.command == 'verify':
+        result = migration_manager.verify(repo_id=args.repo_id)
+        print(json.dumps(result, ensure_ascii=False, indent=2))
+        if result['mismatched']:
+            logger.error(f"Verification failed: {len(result['mismatched'])} branches mismatched")
+            sys.exit(1)
+        logger.info(f"Verification passed: {result['matched']}/{result['total']} branches matched")
+        sys.exit(0)
+
+
+if __name__ == '__main__':
+    main()
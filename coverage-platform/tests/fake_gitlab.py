@@ -0,0 +1,142 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+内存版假 GitLab 服务
+实现平台用到的 GitLab API 子集（commit status、MR 审批规则、pipeline/artifact），
+数据来自 fixture 目录，写操作只记录在内存里
+
+用于单元测试（create_app 后用 Flask test_client），
+也可以单独启动作为本地开发模式的 git host（无需真实 GitLab 凭据）：
+
+    python tests/fake_gitlab.py --port 9826 --fixtures tests/fixtures/gitlab
+    GITLAB_URL=http://localhost:9826 GITLAB_TOKEN=dev python coverage-api/main.py
+
+fixture 目录结构:
+    pipelines.json          GET /projects/<id>/pipelines 的响应
+    jobs_<pipeline_id>.json GET /projects/<id>/pipelines/<pid>/jobs 的响应
+    artifacts_<job_id>.zip  GET /projects/<id>/jobs/<jid>/artifacts 的响应
+"""
+
+import argparse
+import json
+import logging
+import os
+
+from flask import Flask, jsonify, request, send_file
+
+logger = logging.getLogger(__name__)
+
+
+def create_app(fixtures_dir: str) -> Flask:
+    """创建假 GitLab 应用；写操作记录在 app.state 中供测试断言"""
+    app = Flask(__name__)
+
+    # 测试可直接读取的内存状态
+    app.state = {
+        'commit_statuses': [],   # [{project_id, commit, state, name, description}]
+        'approval_rules': {},    # (project_id, mr_iid) -> [rule]
+        'next_rule_id': 1
+    }
+
+    def _fixture(name):
+        path = os.path.join(fixtures_dir, name)
+        if not os.path.exists(path):
+            return None
+        with open(path, 'r', encoding='utf-8') as f:
+            return json.load(f)
+
+    @app.route('/api/v4/projects/<project_id>/pipelines', methods=['GET'])
+    def list_pipelines(project_id):
+        pipelines = _fixture('pipelines.json') or []
+        # 简化分页：第2页起返回空，行为与真实API的末页一致
+        if int(request.args.get('page', 1)) > 1:
+            return jsonify([])
+        ref = request.args.get('ref')
+        if ref:
+            pipelines = [p for p in pipelines if p.get('ref') == ref]
+        status = request.args.get('status')
+        if status:
+            pipelines = [p for p in pipelines if p.get('status') == status]
+        return jsonify(pipelines)
+
+    @app.route('/api/v4/projects/<project_id>/pipelines/<pipeline_id>/jobs', methods=['GET'])
+    def list_jobs(project_id, pipeline_id):
+        jobs = _fixture(f'jobs_{pipeline_id}.json')
+        if jobs is None:
+            return jsonify([]), 200
+        return jsonify(jobs)
+
+    @app.route('/api/v4/projects/<project_id>/jobs/<job_id>/artifacts', methods=['GET'])
+    def download_artifacts(project_id, job_id):
+        path = os.path.join(fixtures_dir, f'artifacts_{job_id}.zip')
+        if not os.path.exists(path):
+            return jsonify({'message': '404 Not Found'}), 404
+        return send_file(path, mimetype='application/zip')
+
+    @app.route('/api/v4/projects/<project_id>/statuses/<commit>', methods=['POST'])
+    def post_commit_status(project_id, commit):
+        data = request.get_json() or {}
+        record = {
+            'project_id': project_id,
+            'commit': commit,
+            'state': data.get('state', ''),
+            'name': data.get('name', ''),
+            'description': data.get('description', '')
+        }
+        app.state['commit_statuses'].append(record)
+        return jsonify(record), 201
+
+    @app.route('/api/v4/projects/<project_id>/merge_requests/<int:mr_iid>/approval_rules',
+               methods=['GET', 'POST'])
+    def approval_rules(project_id, mr_iid):
+        key = (project_id, mr_iid)
+        rules = app.state['approval_rules'].setdefault(key, [])
+        if request.method == 'GET':
+            return jsonify(rules)
+
+        data = request.get_json() or {}
+        rule = {
+            'id': app.state['next_rule_id'],
+            'name': data.get('name', ''),
+            'approvals_required': data.get('approvals_required', 0)
+        }
+        app.state['next_rule_id'] += 1
+        rules.append(rule)
+        return jsonify(rule), 201
+
+    @app.route('/api/v4/projects/<project_id>/merge_requests/<int:mr_iid>/approval_rules/<int:rule_id>',
+               methods=['PUT'])
+    def update_approval_rule(project_id, mr_iid, rule_id):
+        key = (project_id, mr_iid)
+        for rule in app.state['approval_rules'].get(key, []):
+            if rule['id'] == rule_id:
+                data = request.get_json() or {}
+                rule['name'] = data.get('name', rule['name'])
+                rule['approvals_required'] = data.get(
+                    'approvals_required', rule['approvals_required']
+                )
+                return jsonify(rule), 200
+        return jsonify({'message': '404 Not Found'}), 404
+
+    return app
+
+
+def main():
+    parser = argparse.ArgumentParser(description='Run a fake GitLab server for local development')
+    parser.add_argument('--port', type=int, default=9826, help='Listen port (default: 9826)')
+    parser.add_argument('--fixtures', default=os.path.join(
+        os.path.dirname(os.path.abspath(__file__)), 'fixtures', 'gitlab'
+    ), help='Fixture directory')
+    args = parser.parse_args()
+
+    logging.basicConfig(
+        level=logging.INFO,
+        format='%(asctime)s - %(name)s - %(levelname)s - %(message)s'
+    )
+    logger.info(f"Starting fake GitLab on port {args.port}, fixtures={args.fixtures}")
+    create_app(args.fixtures).run(host='0.0.0.0', port=args.port)
+
+
+if __name__ == '__main__':
+    main()
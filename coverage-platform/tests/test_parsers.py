@@ -0,0 +1,99 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+解析器 corpus 测试与模糊测试
+corpus 目录下每个 <name>.input 对应一个 <name>.golden.json（期望的解析结果），
+新增格式或修复解析 bug 时在 corpus 里补样本即可
+
+模糊测试用固定随机种子对合法输入做随机变异，
+断言解析器对任意畸形输入都不会抛出非预期异常
+"""
+
+import json
+import os
+import random
+import sys
+
+import pytest
+
+sys.path.insert(0, os.path.dirname(os.path.dirname(os.path.abspath(__file__))))
+
+from manager.manager import parse_coverage_payload
+
+CORPUS_DIR = os.path.join(os.path.dirname(os.path.abspath(__file__)), 'corpus')
+
+FUZZ_SEED = 20260827
+FUZZ_ITERATIONS = 500
+
+
+def _corpus_cases():
+    cases = []
+    for name in sorted(os.listdir(CORPUS_DIR)):
+        if not name.endswith('.input'):
+            continue
+        base = name[:-len('.input')]
+        golden = os.path.join(CORPUS_DIR, f'{base}.golden.json')
+        if os.path.exists(golden):
+            cases.append(base)
+    return cases
+
+
+def _read(path):
+    with open(path, 'r', encoding='utf-8') as f:
+        return f.read()
+
+
+@pytest.mark.parametrize('case', _corpus_cases())
+def test_corpus_golden(case):
+    """corpus 中每个样本的解析结果必须与 golden 文件一致"""
+    raw = _read(os.path.join(CORPUS_DIR, f'{case}.input'))
+    golden = json.loads(_read(os.path.join(CORPUS_DIR, f'{case}.golden.json')))
+
+    result = parse_coverage_payload(golden['format'], raw)
+    assert result == golden['files']
+
+
+def test_unsupported_format_raises():
+    with pytest.raises(ValueError):
+        parse_coverage_payload('lcov', 'TN:\nSF:a.c\nend_of_record\n')
+
+
+def _mutate(rng, raw):
+    """对输入做一次随机变异（截断/插入/替换/重复）"""
+    if not raw:
+        return chr(rng.randrange(32, 127))
+    op = rng.randrange(4)
+    pos = rng.randrange(len(raw))
+    if op == 0:  # 截断
+        return raw[:pos]
+    if op == 1:  # 插入随机字符
+        return raw[:pos] + chr(rng.randrange(1, 127)) + raw[pos:]
+    if op == 2:  # 替换随机字符
+        return raw[:pos] + chr(rng.randrange(1, 127)) + raw[pos + 1:]
+    # 重复一段
+    end = min(len(raw), pos + rng.randrange(1, 20))
+    return raw[:end] + raw[pos:end] + raw[end:]
+
+
+@pytest.mark.parametrize('coverage_format,seed_case', [
+    ('goc', 'goc_multifile'),
+    ('pyca', 'pyca_windows_path'),
+    ('orbit-agg', 'orbit_agg_basic'),
+])
+def test_fuzz_parser_robustness(coverage_format, seed_case):
+    """
+    随机变异合法输入后解析不应崩溃
+    行级格式（goc/pyca）对畸形行的策略是跳过，不允许抛异常；
+    JSON 格式（orbit-agg）允许抛 ValueError（调用方按解析失败处理）
+    """
+    rng = random.Random(FUZZ_SEED)
+    raw = _read(os.path.join(CORPUS_DIR, f'{seed_case}.input'))
+
+    for _ in range(FUZZ_ITERATIONS):
+        mutated = _mutate(rng, raw)
+        try:
+            parse_coverage_payload(coverage_format, mutated)
+        except ValueError:
+            if coverage_format != 'orbit-agg':
+                raise
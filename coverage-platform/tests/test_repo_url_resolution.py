@@ -0,0 +1,154 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+报告→仓库URL解析回归测试
+CoverageReport 上没有存仓库URL，四个依赖报告定位仓库的入口
+（门禁下发、函数级对比、导出API清单、评审人建议）都必须
+从 CoverageConfig 按 repo_id 解析 repo_url——此前它们读的是
+不存在的 report.repo 属性，每次调用都 AttributeError
+
+用独立的SQLite库跑（不依赖外部MySQL），git层在边界处打桩，
+只验证解析链路走通且拿到的URL来自配置表
+"""
+
+import os
+import sys
+import tempfile
+
+import pytest
+
+sys.path.insert(0, os.path.dirname(os.path.dirname(os.path.abspath(__file__))))
+
+# 必须在导入models之前指定SQLite库
+_DB_FD, _DB_PATH = tempfile.mkstemp(suffix='.db', prefix='orbit-test-')
+os.environ.setdefault('DATABASE_URL', f'sqlite:///{_DB_PATH}')
+
+import models
+
+pytestmark = pytest.mark.skipif(
+    not models.IS_SQLITE,
+    reason='models already bound to a non-sqlite database'
+)
+
+REPO_ID = 'rt-1001'
+REPO_URL = 'https://example.invalid/group/resolution-test.git'
+BRANCH = 'feature/resolution'
+
+
+@pytest.fixture(scope='module')
+def seeded_report_id():
+    """建表并写入一条配置+一条completed报告+一个Go文件"""
+    models.init_db()
+    db = models.get_db_session()
+    try:
+        now = 1700000000000
+        db.add(models.CoverageConfig(
+            repo_id=REPO_ID,
+            repo_name='resolution-test',
+            repo_url=REPO_URL,
+            repo_type=1,
+            base_branch='master',
+            created_at=now,
+            updated_at=now
+        ))
+        report = models.CoverageReport(
+            repo_id=REPO_ID,
+            repo_name='resolution-test',
+            branch=BRANCH,
+            base_branch='master',
+            commit='a' * 40,
+            status='completed',
+            created_at=now,
+            updated_at=now
+        )
+        db.add(report)
+        db.add(models.CoverageFile(
+            repo_id=REPO_ID,
+            branch=BRANCH,
+            file_path='pkg/service.go',
+            created_at=now,
+            updated_at=now
+        ))
+        db.commit()
+        yield report.id
+    finally:
+        db.close()
+
+
+def test_function_deltas_resolves_repo_url(seeded_report_id, monkeypatch):
+    from manager import function_manager
+
+    seen = {}
+
+    def fake_bare_repo_path(repo_url):
+        seen['repo_url'] = repo_url
+        return None
+
+    monkeypatch.setattr(
+        function_manager, 'get_bare_repo_path', fake_bare_repo_path
+    )
+    # bare仓库不存在时按既有语义返回None，但URL解析必须已经完成
+    assert function_manager.calculate_function_deltas(seeded_report_id) is None
+    assert seen['repo_url'] == REPO_URL
+
+
+def test_reviewer_suggestions_resolve_repo_url(seeded_report_id, monkeypatch):
+    from manager import reviewer_manager
+
+    seen = {}
+
+    def fake_bare_repo_path(repo_url):
+        seen['repo_url'] = repo_url
+        return None
+
+    monkeypatch.setattr(
+        reviewer_manager, 'get_bare_repo_path', fake_bare_repo_path
+    )
+    assert reviewer_manager.suggest_reviewers(seeded_report_id) is None
+    assert seen['repo_url'] == REPO_URL
+
+
+def test_api_surface_resolves_repo_url(seeded_report_id, monkeypatch):
+    from manager import api_surface_manager
+
+    seen = {}
+
+    def fake_file_content(repo_url, commit, file_path):
+        seen['repo_url'] = repo_url
+        return None
+
+    monkeypatch.setattr(
+        api_surface_manager, 'get_file_content_at_commit', fake_file_content
+    )
+    result = api_surface_manager.get_untested_api_report(REPO_ID, BRANCH)
+    assert result is not None
+    assert result['untested'] == []
+    assert seen['repo_url'] == REPO_URL
+
+
+def test_gate_enforce_endpoint_resolves_repo_url(seeded_report_id, monkeypatch):
+    sys.path.insert(0, os.path.join(
+        os.path.dirname(os.path.dirname(os.path.abspath(__file__))),
+        'coverage-api'
+    ))
+    import main
+
+    monkeypatch.setattr(
+        main.gate_manager, 'evaluate_gate',
+        lambda report_id: {'verdict': 'passed', 'threshold': 60}
+    )
+    statuses = []
+    monkeypatch.setattr(
+        main.gitlab_manager, 'publish_commit_status',
+        lambda project_id, commit, gate_result: statuses.append(commit) or {}
+    )
+
+    client = main.app.test_client()
+    response = client.post(
+        f'/api/v1/coverage/reports/{seeded_report_id}/gate/enforce',
+        json={'project_id': '123'}
+    )
+    # 修复前这里因 report.repo 的 AttributeError 变成 500
+    assert response.status_code == 200, response.get_data(as_text=True)
+    assert statuses == ['a' * 40]
@@ -0,0 +1,110 @@
+#!/usr/bin/env python3
+# -*- coding: utf-8 -*-
+
+"""
+构建版本信息模块
+版本号/commit/构建时间由打包流水线通过环境变量注入（源码运行时为dev），
+API通过 GET /api/v1/version 暴露，CLI通过 --version 打印并可选
+对比服务端版本提示升级——支持排障时总能确认用户跑的是哪个构建
+
+环境变量:
+    ORBIT_VERSION: 版本号（打包时注入，默认dev）
+    ORBIT_BUILD_COMMIT: 构建commit（打包时注入）
+    ORBIT_BUILD_DATE: 构建时间（打包时注入，ISO格式）
+    ORBIT_SERVER_URL: CLI --version 时用于检查更新的服务端地址（可选）
+"""
+
+import argparse
+import logging
+import os
+import platform
+from typing import Dict, Optional
+
+logger = logging.getLogger(__name__)
+
+VERSION = os.environ.get('ORBIT_VERSION', 'dev')
+BUILD_COMMIT = os.environ.get('ORBIT_BUILD_COMMIT', '')
+BUILD_DATE = os.environ.get('ORBIT_BUILD_DATE', '')
+
+
+def get_version_info() -> Dict:
+    """获取版本元数据（API响应和CLI打印共用）"""
+    return {
+        'version': VERSION,
+        'commit': BUILD_COMMIT,
+        'build_date': BUILD_DATE,
+        'python': platform.python_version()
+    }
+
+
+def version_string() -> str:
+    """人类可读的一行版本描述"""
+    parts = [f'orbit {VERSION}']
+    if BUILD_COMMIT:
+        parts.append(f'commit {BUILD_COMMIT[:12]}')
+    if BUILD_DATE:
+        parts.append(f'built {BUILD_DATE}')
+    return ', '.join(parts)
+
+
+def _version_tuple(version: str):
+    """把点分版本号转成可比较的整数元组（解析失败返回None）"""
+    try:
+        return tuple(int(p) for p in version.lstrip('v').split('.'))
+    except ValueError:
+        return None
+
+
+def check_for_update(server_url: str, timeout: int = 5) -> Optional[Dict]:
+    """
+    对比服务端版本判断是否有更新
+
+    返回:
+        Optional[Dict]: {'current', 'server', 'update_available'}，
+        服务端不可达或版本不可比较时返回 None
+    """
+    import requests
+
+    try:
+        resp = requests.get(
+            f"{server_url.rstrip('/')}/api/v1/version", timeout=timeout
+        )
+        resp.raise_for_status()
+        server_version = resp.json().get('version', '')
+    except Exception as e:
+        logger.warning(f"Failed to check server version: {e}")
+        return None
+
+    current = _version_tuple(VERSION)
+    server = _version_tuple(server_version)
+    if current is None or server is None:
+        return None
+    return {
+        'current': VERSION,
+        'server': server_version,
+        'update_available': server > current
+    }
+
+
+class VersionAction(argparse.Action):
+    """
+    CLI的--version动作：打印版本信息后退出
+    设置了ORBIT_SERVER_URL时顺带对比服务端版本提示升级
+    """
+
+    def __init__(self, option_strings, dest, **kwargs):
+        kwargs.setdefault('nargs', 0)
+        kwargs.setdefault('help', '打印版本信息后退出')
+        super().__init__(option_strings, dest, **kwargs)
+
+    def __call__(self, parser, namespace, values, option_string=None):
+        print(version_string())
+        server_url = os.environ.get('ORBIT_SERVER_URL')
+        if server_url:
+            result = check_for_update(server_url)
+            if result and result['update_available']:
+                print(f"update available: {result['server']} "
+                      f"(current {result['current']})")
+            elif result:
+                print('up to date')
+        parser.exit(0)
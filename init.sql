@@ -51,6 +51,7 @@ CREATE TABLE IF NOT EXISTS `coverage_reports` (
     `coverage_raw` TEXT DEFAULT NULL COMMENT '原始覆盖率数据',
     `status` VARCHAR(50) NOT NULL DEFAULT 'pending' COMMENT '状态',
     `error_message` TEXT DEFAULT NULL COMMENT '错误信息',
+    `metadata` TEXT DEFAULT NULL COMMENT '自定义key/value元数据（JSON文本）',
     `created_at` BIGINT NOT NULL COMMENT '创建时间戳（毫秒）',
     `updated_at` BIGINT NOT NULL COMMENT '更新时间戳（毫秒）',
     PRIMARY KEY (`id`),
@@ -67,6 +68,8 @@ CREATE TABLE IF NOT EXISTS `coverage_files` (
     `repo_id` VARCHAR(64) NOT NULL COMMENT '仓库唯一标识',
     `branch` VARCHAR(255) NOT NULL COMMENT '分支名称',
     `file_path` VARCHAR(500) NOT NULL COMMENT '文件路径',
+    `complexity` INT NOT NULL DEFAULT 0 COMMENT '圈复杂度（入库时按源码估算，0表示未计算）',
+    `downsampled` INT NOT NULL DEFAULT 0 COMMENT '1表示行级数据已降采样删除，只剩文件级摘要',
     `created_at` BIGINT NOT NULL COMMENT '创建时间戳（毫秒）',
     `updated_at` BIGINT NOT NULL COMMENT '更新时间戳（毫秒）',
     PRIMARY KEY (`id`),
@@ -101,6 +104,9 @@ CREATE TABLE IF NOT EXISTS `coverage_config` (
     `base_branch` VARCHAR(255) NOT NULL DEFAULT 'master' COMMENT '基准分支',
     `exclude_dirs` TEXT DEFAULT NULL COMMENT '排除目录（分号分隔）',
     `exclude_files` TEXT DEFAULT NULL COMMENT '排除文件后缀（分号分隔）',
+    `gate_threshold` INT NOT NULL DEFAULT 0 COMMENT '增量覆盖率门禁阈值（百分比整数，0表示不启用门禁）',
+    `criticality_weights` TEXT DEFAULT NULL COMMENT '关键路径权重（格式：路径前缀=权重;...）',
+    `freshness_slo_hours` INT NOT NULL DEFAULT 0 COMMENT '基准分支覆盖率新鲜度SLO（小时，0表示用全局默认）',
     `created_at` BIGINT NOT NULL COMMENT '创建时间戳（毫秒）',
     `updated_at` BIGINT NOT NULL COMMENT '更新时间戳（毫秒）',
     PRIMARY KEY (`id`),
@@ -0,0 +1,23 @@
+-- ============================================
+-- 001: coverage_config 增加 gate_threshold 列
+-- ============================================
+-- 增量覆盖率门禁阈值（百分比整数，0表示不启用门禁）
+-- MySQL 8.0 不支持 ADD COLUMN IF NOT EXISTS，
+-- 这里用 information_schema 判断后再执行，脚本可重复运行
+
+USE `coverage_db`;
+
+SET @col_exists = (
+    SELECT COUNT(*) FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = 'coverage_db'
+      AND TABLE_NAME = 'coverage_config'
+      AND COLUMN_NAME = 'gate_threshold'
+);
+
+SET @ddl = IF(@col_exists = 0,
+    'ALTER TABLE `coverage_config` ADD COLUMN `gate_threshold` INT NOT NULL DEFAULT 0 COMMENT ''增量覆盖率门禁阈值（百分比整数，0表示不启用门禁）'' AFTER `exclude_files`',
+    'SELECT ''column gate_threshold already exists, skipping'' AS message');
+
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
@@ -0,0 +1,22 @@
+-- ============================================
+-- 002: coverage_reports 增加 metadata 列
+-- ============================================
+-- 自定义key/value元数据（JSON文本），对应模型属性 report_metadata
+-- （metadata 是 SQLAlchemy 保留名，数据库列名仍为 metadata）
+
+USE `coverage_db`;
+
+SET @col_exists = (
+    SELECT COUNT(*) FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = 'coverage_db'
+      AND TABLE_NAME = 'coverage_reports'
+      AND COLUMN_NAME = 'metadata'
+);
+
+SET @ddl = IF(@col_exists = 0,
+    'ALTER TABLE `coverage_reports` ADD COLUMN `metadata` TEXT DEFAULT NULL COMMENT ''自定义key/value元数据（JSON文本）'' AFTER `error_message`',
+    'SELECT ''column metadata already exists, skipping'' AS message');
+
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
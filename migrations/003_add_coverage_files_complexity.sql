@@ -0,0 +1,21 @@
+-- ============================================
+-- 003: coverage_files 增加 complexity 列
+-- ============================================
+-- 圈复杂度（入库时按源码估算，0表示未计算）
+
+USE `coverage_db`;
+
+SET @col_exists = (
+    SELECT COUNT(*) FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = 'coverage_db'
+      AND TABLE_NAME = 'coverage_files'
+      AND COLUMN_NAME = 'complexity'
+);
+
+SET @ddl = IF(@col_exists = 0,
+    'ALTER TABLE `coverage_files` ADD COLUMN `complexity` INT NOT NULL DEFAULT 0 COMMENT ''圈复杂度（入库时按源码估算，0表示未计算）'' AFTER `file_path`',
+    'SELECT ''column complexity already exists, skipping'' AS message');
+
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
@@ -0,0 +1,21 @@
+-- ============================================
+-- 004: coverage_files 增加 downsampled 列
+-- ============================================
+-- 1表示行级数据已降采样删除，只剩文件级摘要
+
+USE `coverage_db`;
+
+SET @col_exists = (
+    SELECT COUNT(*) FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = 'coverage_db'
+      AND TABLE_NAME = 'coverage_files'
+      AND COLUMN_NAME = 'downsampled'
+);
+
+SET @ddl = IF(@col_exists = 0,
+    'ALTER TABLE `coverage_files` ADD COLUMN `downsampled` INT NOT NULL DEFAULT 0 COMMENT ''1表示行级数据已降采样删除，只剩文件级摘要'' AFTER `complexity`',
+    'SELECT ''column downsampled already exists, skipping'' AS message');
+
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
@@ -0,0 +1,21 @@
+-- ============================================
+-- 005: coverage_config 增加 criticality_weights 列
+-- ============================================
+-- 关键路径权重，格式 "路径前缀=权重;..."，如 "payment/=3;internal/tools/=0.5"
+
+USE `coverage_db`;
+
+SET @col_exists = (
+    SELECT COUNT(*) FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = 'coverage_db'
+      AND TABLE_NAME = 'coverage_config'
+      AND COLUMN_NAME = 'criticality_weights'
+);
+
+SET @ddl = IF(@col_exists = 0,
+    'ALTER TABLE `coverage_config` ADD COLUMN `criticality_weights` TEXT DEFAULT NULL COMMENT ''关键路径权重（格式：路径前缀=权重;...）'' AFTER `gate_threshold`',
+    'SELECT ''column criticality_weights already exists, skipping'' AS message');
+
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
@@ -0,0 +1,21 @@
+-- ============================================
+-- 006: coverage_config 增加 freshness_slo_hours 列
+-- ============================================
+-- 基准分支覆盖率新鲜度SLO（小时，0表示用全局默认）
+
+USE `coverage_db`;
+
+SET @col_exists = (
+    SELECT COUNT(*) FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = 'coverage_db'
+      AND TABLE_NAME = 'coverage_config'
+      AND COLUMN_NAME = 'freshness_slo_hours'
+);
+
+SET @ddl = IF(@col_exists = 0,
+    'ALTER TABLE `coverage_config` ADD COLUMN `freshness_slo_hours` INT NOT NULL DEFAULT 0 COMMENT ''基准分支覆盖率新鲜度SLO（小时，0表示用全局默认）'' AFTER `criticality_weights`',
+    'SELECT ''column freshness_slo_hours already exists, skipping'' AS message');
+
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;